@@ -0,0 +1,53 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/signature"
+)
+
+func main() {
+	cmd := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+	key := cmd.String("key", os.Getenv("HYPERPIC_SIGNATURE_KEY"), "The signature key, defaults to $HYPERPIC_SIGNATURE_KEY")
+	ttl := cmd.Duration("ttl", 0, "The lifetime of the url, no expiry if 0")
+	mount := cmd.String("mount", "", "The name of the mount with a host serving the url")
+
+	cmd.Usage = func() {
+		fmt.Fprintf(cmd.Output(), "Usage: %s [flags] url...\n", os.Args[0])
+
+		cmd.PrintDefaults()
+	}
+
+	_ = cmd.Parse(os.Args[1:])
+
+	if cmd.NArg() == 0 {
+		cmd.Usage()
+
+		os.Exit(2)
+	}
+
+	var expires time.Time
+
+	if *ttl > 0 {
+		expires = time.Now().Add(*ttl)
+	}
+
+	for _, rawurl := range cmd.Args() {
+		signed, err := signature.SignScopedURL(*key, *mount, rawurl, expires)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			os.Exit(1)
+		}
+
+		fmt.Println(signed)
+	}
+}
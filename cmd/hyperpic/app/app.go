@@ -5,6 +5,7 @@
 package app
 
 import (
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
@@ -12,6 +13,7 @@ import (
 	server "github.com/euskadi31/go-server"
 	service "github.com/euskadi31/go-service"
 	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/container"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/mount"
 	"github.com/rs/zerolog/log"
 )
 
@@ -39,5 +41,19 @@ func Run() (err error) {
 
 	log.Info().Msg("Shutdown")
 
-	return router.Shutdown()
+	if err := router.Shutdown(); err != nil {
+		return err
+	}
+
+	// the queued eager transforms are stored before exiting
+	service.Get(container.MountTableKey).(*mount.Table).Close()
+
+	// the audit log is closed once the last requests are served
+	if sink, ok := service.Get(container.AuditSinkKey).(io.Closer); ok {
+		if err := sink.Close(); err != nil {
+			log.Error().Err(err).Msg("Audit log close failed")
+		}
+	}
+
+	return nil
 }
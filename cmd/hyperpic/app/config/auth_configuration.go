@@ -4,7 +4,12 @@
 
 package config
 
+import "github.com/hyperscale/hyperpic/pkg/hyperpic/auth"
+
 // AuthConfiguration struct
 type AuthConfiguration struct {
-	Secret string
+	Secret  string
+	Keys    []auth.Key
+	KeyFile string `mapstructure:"key_file"`
+	JWT     *auth.JWTConfiguration
 }
@@ -5,18 +5,35 @@
 package config
 
 import (
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/audit"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/auth"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/eager"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/logger"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/azure"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/filesystem"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/gcs"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/layered"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/origin"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/s3"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/sftp"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/webdav"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/ratelimit"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/server"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/signature"
 )
 
 // Configuration struct
 type Configuration struct {
-	Logger *logger.Configuration
-	Server *server.Configuration
-	Image  *ImageConfiguration
-	Auth   *AuthConfiguration
-	Doc    *DocConfiguration
+	Logger    *logger.Configuration
+	Server    *server.Configuration
+	Image     *ImageConfiguration
+	Auth      *AuthConfiguration
+	Signature *signature.Configuration
+	RateLimit *ratelimit.Configuration `mapstructure:"rate_limit"`
+	Audit     *audit.Configuration
+	Doc       *DocConfiguration
+	Mounts    []*MountConfiguration
 }
 
 // NewConfiguration constructor
@@ -26,14 +43,34 @@ func NewConfiguration() *Configuration {
 		Logger: &logger.Configuration{},
 		Image: &ImageConfiguration{
 			Source: &ImageSourceConfiguration{
-				FS: &filesystem.SourceConfiguration{},
+				FS:      &filesystem.SourceConfiguration{},
+				S3:      &s3.SourceConfiguration{},
+				Azure:   &azure.SourceConfiguration{},
+				GCS:     &gcs.SourceConfiguration{},
+				HTTP:    &origin.SourceConfiguration{},
+				SFTP:    &sftp.SourceConfiguration{},
+				WebDAV:  &webdav.SourceConfiguration{},
+				Layered: &layered.SourceConfiguration{},
 			},
 			Cache: &ImageCacheConfiguration{
 				FS: &filesystem.CacheConfiguration{},
 			},
-			Support: &ImageSupportConfiguration{},
+			Support:    &ImageSupportConfiguration{},
+			Derivative: &ImageDerivativeConfiguration{},
+			Limits:     &image.LimitsConfiguration{},
+			Options:    &image.OptionsConfiguration{},
+			Presets:    &image.PresetsConfiguration{},
+			Lockdown:   &ImageLockdownConfiguration{},
+			Hotlink:    &ImageHotlinkConfiguration{},
+			Upload:     &image.UploadConfiguration{},
+			Eager:      &eager.Configuration{},
 		},
-		Auth: &AuthConfiguration{},
-		Doc:  &DocConfiguration{},
+		Auth: &AuthConfiguration{
+			JWT: &auth.JWTConfiguration{},
+		},
+		Signature: &signature.Configuration{},
+		RateLimit: &ratelimit.Configuration{},
+		Audit:     &audit.Configuration{},
+		Doc:       &DocConfiguration{},
 	}
 }
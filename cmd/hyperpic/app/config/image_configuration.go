@@ -4,9 +4,22 @@
 
 package config
 
+import (
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/eager"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+)
+
 // ImageConfiguration struct
 type ImageConfiguration struct {
-	Source  *ImageSourceConfiguration
-	Cache   *ImageCacheConfiguration
-	Support *ImageSupportConfiguration
+	Source     *ImageSourceConfiguration
+	Cache      *ImageCacheConfiguration
+	Support    *ImageSupportConfiguration
+	Derivative *ImageDerivativeConfiguration
+	Limits     *image.LimitsConfiguration
+	Options    *image.OptionsConfiguration
+	Presets    *image.PresetsConfiguration
+	Lockdown   *ImageLockdownConfiguration
+	Hotlink    *ImageHotlinkConfiguration
+	Upload     *image.UploadConfiguration
+	Eager      *eager.Configuration
 }
@@ -0,0 +1,36 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import "github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+
+// ImageDerivativeConfiguration struct
+type ImageDerivativeConfiguration struct {
+	Enable        bool
+	MinQuality    int `mapstructure:"min_quality"`
+	MaxGeneration int `mapstructure:"max_generation"`
+}
+
+// Criteria returns the criteria a cached derivative must match to be used as source
+// for options, or nil if the options need the original image.
+func (c ImageDerivativeConfiguration) Criteria(o *image.Options) *image.DerivativeCriteria {
+	if !c.Enable || o == nil || !o.IsDerivable() {
+		return nil
+	}
+
+	width, height := o.OutputSize()
+
+	quality := o.EffectiveQuality()
+	if quality < c.MinQuality {
+		quality = c.MinQuality
+	}
+
+	return &image.DerivativeCriteria{
+		Width:         width,
+		Height:        height,
+		MinQuality:    quality,
+		MaxGeneration: c.MaxGeneration,
+	}
+}
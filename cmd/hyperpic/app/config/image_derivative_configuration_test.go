@@ -0,0 +1,43 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/h2non/bimg"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageDerivativeConfigurationCriteria(t *testing.T) {
+	cfg := ImageDerivativeConfiguration{
+		Enable:        true,
+		MinQuality:    85,
+		MaxGeneration: 1,
+	}
+
+	assert.Equal(t, &image.DerivativeCriteria{
+		Width:         800,
+		Height:        0,
+		MinQuality:    85,
+		MaxGeneration: 1,
+	}, cfg.Criteria(&image.Options{Width: 400, DPR: 2}))
+
+	assert.Equal(t, &image.DerivativeCriteria{
+		Width:         200,
+		Height:        200,
+		MinQuality:    90,
+		MaxGeneration: 1,
+	}, cfg.Criteria(&image.Options{Width: 200, Height: 200, Quality: 90, Fit: image.FitCropCenter}))
+
+	assert.Nil(t, cfg.Criteria(&image.Options{Format: bimg.WEBP}))
+	assert.Nil(t, cfg.Criteria(&image.Options{Width: 200, Crop: image.CropType{Width: 10, Height: 10}}))
+	assert.Nil(t, cfg.Criteria(&image.Options{Width: 200, Operations: image.Operations{{Type: image.OperationFlip}}}))
+
+	cfg.Enable = false
+
+	assert.Nil(t, cfg.Criteria(&image.Options{Width: 200}))
+}
@@ -0,0 +1,59 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"path"
+	"strings"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/fsutil"
+)
+
+// ImageHotlinkConfiguration struct
+type ImageHotlinkConfiguration struct {
+	Enable bool
+	Rules  []ImageHotlinkRule
+	// Placeholder is the image file served to the blocked requests instead of a 403
+	Placeholder string
+}
+
+// ImageHotlinkRule lists the hosts allowed to embed the images under the path prefix
+type ImageHotlinkRule struct {
+	Prefix string
+	// Hosts of the Referer or Origin, with wildcards: *.your-domain.tld
+	Hosts []string
+	// AllowEmpty accepts the requests without Referer and Origin
+	AllowEmpty bool `mapstructure:"allow_empty"`
+}
+
+// Rule returns the rule of the longest prefix matching the path, or nil
+func (c ImageHotlinkConfiguration) Rule(p string) *ImageHotlinkRule {
+	var rule *ImageHotlinkRule
+
+	for i, r := range c.Rules {
+		if !fsutil.HasPathPrefix(p, r.Prefix) {
+			continue
+		}
+
+		if rule == nil || len(r.Prefix) > len(rule.Prefix) {
+			rule = &c.Rules[i]
+		}
+	}
+
+	return rule
+}
+
+// AllowHost returns true if the host matches one of the hosts of the rule
+func (r ImageHotlinkRule) AllowHost(host string) bool {
+	host = strings.ToLower(host)
+
+	for _, pattern := range r.Hosts {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+
+	return false
+}
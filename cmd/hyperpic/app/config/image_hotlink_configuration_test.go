@@ -0,0 +1,40 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageHotlinkConfigurationRule(t *testing.T) {
+	cfg := ImageHotlinkConfiguration{
+		Rules: []ImageHotlinkRule{
+			{Prefix: "/", Hosts: []string{"your-domain.tld"}},
+			{Prefix: "/products/", Hosts: []string{"shop.tld"}},
+			{Prefix: "/users", Hosts: []string{"users.tld"}},
+		},
+	}
+
+	assert.Equal(t, "/products/", cfg.Rule("/products/foo.jpg").Prefix)
+	assert.Equal(t, "/", cfg.Rule("/foo.jpg").Prefix)
+	assert.Equal(t, "/users", cfg.Rule("/users/foo.jpg").Prefix)
+	assert.Equal(t, "/", cfg.Rule("/users-private/foo.jpg").Prefix)
+	assert.Nil(t, ImageHotlinkConfiguration{}.Rule("/foo.jpg"))
+}
+
+func TestImageHotlinkRuleAllowHost(t *testing.T) {
+	rule := ImageHotlinkRule{
+		Hosts: []string{"your-domain.tld", "*.Your-Domain.tld"},
+	}
+
+	assert.True(t, rule.AllowHost("your-domain.tld"))
+	assert.True(t, rule.AllowHost("www.your-domain.tld"))
+	assert.True(t, rule.AllowHost("CDN.static.your-domain.tld"))
+	assert.False(t, rule.AllowHost("evil-your-domain.tld"))
+	assert.False(t, rule.AllowHost("your-domain.tld.evil.tld"))
+	assert.True(t, ImageHotlinkRule{Hosts: []string{"*"}}.AllowHost("evil.tld"))
+}
@@ -0,0 +1,21 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+// ImageLockdownConfiguration struct
+type ImageLockdownConfiguration struct {
+	Enable      bool
+	Widths      []int
+	Heights     []int
+	Qualities   []int
+	DPRs        []float64 `mapstructure:"dprs"`
+	AllowSigned bool      `mapstructure:"allow_signed"`
+}
+
+// HasAllowlist returns true if free parameters are accepted from the allowlists,
+// without allowlist only presets are accepted.
+func (c ImageLockdownConfiguration) HasAllowlist() bool {
+	return len(c.Widths) > 0 || len(c.Heights) > 0 || len(c.Qualities) > 0
+}
@@ -4,11 +4,28 @@
 
 package config
 
-import "github.com/hyperscale/hyperpic/pkg/hyperpic/provider/filesystem"
+import (
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/azure"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/filesystem"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/gcs"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/layered"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/origin"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/s3"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/sftp"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/webdav"
+)
 
 // ImageSourceConfiguration struct
 type ImageSourceConfiguration struct {
-	MaxSize  int64 `mapstructure:"max_size"`
-	Provider string
-	FS       *filesystem.SourceConfiguration
+	MaxSize       int64 `mapstructure:"max_size"`
+	StreamMinSize int64 `mapstructure:"stream_min_size"`
+	Provider      string
+	FS            *filesystem.SourceConfiguration
+	S3            *s3.SourceConfiguration
+	Azure         *azure.SourceConfiguration
+	GCS           *gcs.SourceConfiguration
+	HTTP          *origin.SourceConfiguration
+	SFTP          *sftp.SourceConfiguration
+	WebDAV        *webdav.SourceConfiguration
+	Layered       *layered.SourceConfiguration
 }
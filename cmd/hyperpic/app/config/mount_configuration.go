@@ -0,0 +1,50 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+)
+
+// DefaultCacheNamespace is the cache namespace of the requests matching no mount,
+// it is reserved
+const DefaultCacheNamespace = "_default"
+
+// MountConfiguration is a tenant selected by the Host header or by the path prefix of the request.
+// The source settings override the global source settings, the global auth and presets are used
+// when the mount has none.
+type MountConfiguration struct {
+	Name      string
+	Host      string
+	Prefix    string
+	Namespace string
+	Source    *ImageSourceConfiguration
+	Auth      *AuthConfiguration
+	Presets   *image.PresetsConfiguration
+	Options   map[string]string
+}
+
+// CacheNamespace returns the namespace of the mount in the cache, the name by default
+func (c MountConfiguration) CacheNamespace() string {
+	if c.Namespace != "" {
+		return strings.Trim(c.Namespace, "/")
+	}
+
+	return strings.Trim(c.Name, "/")
+}
+
+// DefaultOptions returns the default options of the images as query parameters
+func (c MountConfiguration) DefaultOptions() url.Values {
+	values := url.Values{}
+
+	for name, value := range c.Options {
+		values.Set(name, value)
+	}
+
+	return values
+}
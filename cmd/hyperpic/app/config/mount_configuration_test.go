@@ -0,0 +1,27 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountConfigurationCacheNamespace(t *testing.T) {
+	assert.Equal(t, "brand-a", MountConfiguration{Name: "brand-a"}.CacheNamespace())
+	assert.Equal(t, "a", MountConfiguration{Name: "brand-a", Namespace: "a"}.CacheNamespace())
+	assert.Equal(t, "a", MountConfiguration{Name: "brand-a", Namespace: "/a/"}.CacheNamespace())
+}
+
+func TestMountConfigurationDefaultOptions(t *testing.T) {
+	assert.Equal(t, url.Values{}, MountConfiguration{}.DefaultOptions())
+
+	assert.Equal(t, url.Values{
+		"q":  {"80"},
+		"fm": {"webp"},
+	}, MountConfiguration{Options: map[string]string{"q": "80", "fm": "webp"}}.DefaultOptions())
+}
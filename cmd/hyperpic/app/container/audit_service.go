@@ -0,0 +1,34 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package container
+
+import (
+	service "github.com/euskadi31/go-service"
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/audit"
+	"github.com/rs/zerolog/log"
+)
+
+// Services keys
+const (
+	AuditSinkKey = "service.audit.sink"
+)
+
+func init() {
+	service.Set(AuditSinkKey, func(c service.Container) interface{} {
+		cfg := c.Get(ConfigKey).(*config.Configuration)
+
+		if !cfg.Audit.Enable {
+			return nil
+		}
+
+		sink, err := audit.NewFileSink(cfg.Audit.File)
+		if err != nil {
+			log.Fatal().Err(err).Msg(AuditSinkKey)
+		}
+
+		return sink // audit.Sink
+	})
+}
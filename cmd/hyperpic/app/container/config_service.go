@@ -6,12 +6,17 @@ package container
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"strings"
 	"time"
 
 	service "github.com/euskadi31/go-service"
+	"github.com/fsnotify/fsnotify"
 	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/auth"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/mitchellh/mapstructure"
 	"github.com/pbnjay/memory"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -53,8 +58,29 @@ func init() {
 		options.SetDefault("server.read_header_timeout", 10*time.Millisecond)
 		options.SetDefault("auth.secret", "")
 		options.SetDefault("image.source.max_size", 10<<20)
+		options.SetDefault("image.source.stream_min_size", 10<<20)
 		options.SetDefault("image.source.provider", "fs")
 		options.SetDefault("image.source.fs.path", "/var/lib/"+name+"/source")
+		options.SetDefault("image.source.s3.region", "us-east-1")
+		options.SetDefault("image.source.s3.path_style", false)
+		options.SetDefault("image.source.s3.part_size", 8<<20)
+		options.SetDefault("image.source.s3.timeout", 30*time.Second)
+		options.SetDefault("image.source.azure.block_size", 4<<20)
+		options.SetDefault("image.source.azure.timeout", 30*time.Second)
+		options.SetDefault("image.source.gcs.chunk_size", 8<<20)
+		options.SetDefault("image.source.gcs.timeout", 30*time.Second)
+		options.SetDefault("image.source.http.max_size", 10<<20)
+		options.SetDefault("image.source.http.max_redirects", 3)
+		options.SetDefault("image.source.http.timeout", 10*time.Second)
+		options.SetDefault("image.source.http.cache_size", 64<<20)
+		options.SetDefault("image.source.http.user_agent", name)
+		options.SetDefault("image.source.sftp.pool_size", 4)
+		options.SetDefault("image.source.sftp.timeout", 10*time.Second)
+		options.SetDefault("image.source.sftp.operation_timeout", time.Minute)
+		options.SetDefault("image.source.webdav.pool_size", 4)
+		options.SetDefault("image.source.webdav.timeout", 30*time.Second)
+		options.SetDefault("image.source.layered.read", "first-hit")
+		options.SetDefault("image.source.layered.write", "primary")
 		options.SetDefault("image.cache.provider", "fs")
 		options.SetDefault("image.cache.fs.path", "/var/lib/"+name+"/cache")
 		options.SetDefault("image.cache.fs.life_time", "24h")
@@ -69,6 +95,48 @@ func init() {
 			"png":  true,
 			"tiff": true,
 		})
+		options.SetDefault("image.derivative.enable", false)
+		options.SetDefault("image.derivative.min_quality", 85)
+		options.SetDefault("image.derivative.max_generation", 1)
+		options.SetDefault("image.limits.max_source_pixels", 268402689)
+		options.SetDefault("image.limits.max_output_pixels", 0)
+		options.SetDefault("image.limits.max_dpr", 0.0)
+		options.SetDefault("image.limits.max_upscale", 0.0)
+		options.SetDefault("image.options.strict", false)
+		options.SetDefault("image.presets.path", "")
+		options.SetDefault("image.presets.reload_interval", 10*time.Second)
+		options.SetDefault("image.lockdown.enable", false)
+		options.SetDefault("image.lockdown.dprs", []float64{1, 2, 3})
+		options.SetDefault("image.lockdown.allow_signed", true)
+		options.SetDefault("image.upload.formats", []string{"jpeg", "png", "webp", "gif", "tiff"})
+		options.SetDefault("image.upload.max_pixels", 268402689)
+		options.SetDefault("image.upload.strip_metadata", false)
+		options.SetDefault("image.upload.normalize.enable", false)
+		options.SetDefault("image.upload.normalize.format", "jpeg")
+		options.SetDefault("image.upload.normalize.quality", 90)
+		options.SetDefault("image.hotlink.enable", false)
+		options.SetDefault("image.hotlink.placeholder", "")
+		options.SetDefault("image.eager.enable", false)
+		options.SetDefault("image.eager.workers", 2)
+		options.SetDefault("image.eager.queue_size", 100)
+		options.SetDefault("auth.jwt.enable", false)
+		options.SetDefault("auth.jwt.leeway", 30*time.Second)
+		options.SetDefault("auth.jwt.claims.paths", "paths")
+		options.SetDefault("auth.jwt.claims.max_size", "max_size")
+		options.SetDefault("auth.jwt.claims.methods", "methods")
+		options.SetDefault("signature.enable", false)
+		options.SetDefault("signature.keys", []string{})
+		options.SetDefault("signature.ttl", 0)
+		options.SetDefault("signature.upload_ttl", "15m")
+		options.SetDefault("audit.enable", false)
+		options.SetDefault("audit.file", "/var/log/"+name+"/audit.log")
+		options.SetDefault("rate_limit.enable", false)
+		options.SetDefault("rate_limit.trusted_proxies", []string{})
+		options.SetDefault("rate_limit.requests.rate", 50)
+		options.SetDefault("rate_limit.requests.burst", 100)
+		options.SetDefault("rate_limit.processing.rate", 2)
+		options.SetDefault("rate_limit.processing.burst", 20)
+		options.SetDefault("rate_limit.concurrency", 4)
 		options.SetDefault("doc.enable", true)
 
 		options.SetConfigName("config") // name of config file (without extension)
@@ -92,12 +160,150 @@ func init() {
 		// If a config file is found, read it in.
 		if err := options.ReadInConfig(); err == nil {
 			log.Info().Msgf("Using config file: %s", options.ConfigFileUsed())
+
+			// hot reload of the presets defined in the config file
+			options.OnConfigChange(func(e fsnotify.Event) {
+				definitions := map[string]map[string]interface{}{}
+
+				if err := options.UnmarshalKey("image.presets.definitions", &definitions); err != nil {
+					log.Error().Err(err).Msg("Presets reload failed")
+
+					return
+				}
+
+				if err := c.Get(ImagePresetsKey).(*image.Presets).SetDefinitions(definitions); err != nil {
+					log.Error().Err(err).Msg("Presets reload failed, the previous presets are kept")
+
+					return
+				}
+
+				log.Info().Msg("Presets reloaded")
+			})
+			options.WatchConfig()
 		}
 
-		if err := options.Unmarshal(cfg); err != nil {
+		hook := viper.DecodeHook(decodeHook)
+
+		if err := options.Unmarshal(cfg, hook); err != nil {
+			log.Fatal().Err(err).Msg(ConfigKey)
+		}
+
+		if cfg.Auth.KeyFile != "" {
+			keys, err := auth.LoadKeyFile(cfg.Auth.KeyFile)
+			if err != nil {
+				log.Fatal().Err(err).Msg(ConfigKey)
+			}
+
+			cfg.Auth.Keys = append(cfg.Auth.Keys, keys...)
+		}
+
+		if cfg.Auth.JWT.Enable {
+			if _, err := auth.NewJWTVerifier(cfg.Auth.JWT); err != nil {
+				log.Fatal().Err(err).Msg(ConfigKey)
+			}
+		}
+
+		if err := cfg.Image.Upload.Validate(); err != nil {
+			log.Fatal().Err(err).Msg(ConfigKey)
+		}
+
+		if err := decodeMounts(options, cfg); err != nil {
 			log.Fatal().Err(err).Msg(ConfigKey)
 		}
 
 		return cfg // *config.Configuration
 	})
 }
+
+// the expiry dates of the keys are decoded from RFC 3339 strings
+var decodeHook = mapstructure.ComposeDecodeHookFunc(
+	mapstructure.StringToTimeDurationHookFunc(),
+	mapstructure.StringToSliceHookFunc(","),
+	mapstructure.StringToTimeHookFunc(time.RFC3339),
+)
+
+// decode the settings over the values of the output
+func decode(input map[string]interface{}, output interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       decodeHook,
+		WeaklyTypedInput: true,
+		Result:           output,
+	})
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(input)
+}
+
+// decodeMounts decodes the source settings of the mounts over the global source settings,
+// the auth and the presets of a mount only inherit the defaults
+func decodeMounts(options *viper.Viper, cfg *config.Configuration) error {
+	raw := []struct {
+		Source  map[string]interface{}
+		Auth    map[string]interface{}
+		Presets map[string]interface{}
+	}{}
+
+	if err := options.UnmarshalKey("mounts", &raw); err != nil {
+		return err
+	}
+
+	for i, m := range cfg.Mounts {
+		base := config.NewConfiguration()
+
+		if err := options.Unmarshal(base, viper.DecodeHook(decodeHook)); err != nil {
+			return err
+		}
+
+		m.Source = base.Image.Source
+
+		if err := decode(raw[i].Source, m.Source); err != nil {
+			return fmt.Errorf("mount %s: %w", m.Name, err)
+		}
+
+		m.Auth = nil
+
+		if raw[i].Auth != nil {
+			m.Auth = &config.AuthConfiguration{
+				JWT: &auth.JWTConfiguration{
+					Leeway: base.Auth.JWT.Leeway,
+					Claims: base.Auth.JWT.Claims,
+				},
+			}
+
+			if err := decode(raw[i].Auth, m.Auth); err != nil {
+				return fmt.Errorf("mount %s: %w", m.Name, err)
+			}
+
+			if m.Auth.KeyFile != "" {
+				keys, err := auth.LoadKeyFile(m.Auth.KeyFile)
+				if err != nil {
+					return fmt.Errorf("mount %s: %w", m.Name, err)
+				}
+
+				m.Auth.Keys = append(m.Auth.Keys, keys...)
+			}
+
+			if m.Auth.JWT.Enable {
+				if _, err := auth.NewJWTVerifier(m.Auth.JWT); err != nil {
+					return fmt.Errorf("mount %s: %w", m.Name, err)
+				}
+			}
+		}
+
+		m.Presets = nil
+
+		if raw[i].Presets != nil {
+			m.Presets = &image.PresetsConfiguration{
+				ReloadInterval: base.Image.Presets.ReloadInterval,
+			}
+
+			if err := decode(raw[i].Presets, m.Presets); err != nil {
+				return fmt.Errorf("mount %s: %w", m.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
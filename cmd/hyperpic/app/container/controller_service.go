@@ -8,14 +8,19 @@ import (
 	service "github.com/euskadi31/go-service"
 	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
 	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/controller"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/audit"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/eager"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/mount"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/ratelimit"
 )
 
 // Services keys
 const (
 	DocControllerKey   = "service.controller.doc"
 	ImageControllerKey = "service.controller.image"
+	AuditControllerKey = "service.controller.audit"
 )
 
 func init() {
@@ -29,6 +34,11 @@ func init() {
 		imageProcessor := c.Get(ImageProcessorKey).(image.Processor)
 		sourceProvider := c.Get(SourceProviderKey).(provider.SourceProvider)
 		cacheProvider := c.Get(CacheProviderKey).(provider.CacheProvider)
+		eagerPool := c.Get(ImageEagerPoolKey).(*eager.Pool)
+		quotas := c.Get(RateLimitQuotasKey).(*ratelimit.Quotas)
+		// the sink is nil when the audit log is disabled
+		auditSink, _ := c.Get(AuditSinkKey).(audit.Sink)
+		mounts := c.Get(MountTableKey).(*mount.Table)
 
 		return controller.NewImageController(
 			cfg,
@@ -36,6 +46,18 @@ func init() {
 			imageProcessor,
 			sourceProvider,
 			cacheProvider,
+			eagerPool,
+			quotas,
+			auditSink,
+			mounts,
 		)
 	})
+
+	service.Set(AuditControllerKey, func(c service.Container) interface{} {
+		cfg := c.Get(ConfigKey).(*config.Configuration)
+		auditSink, _ := c.Get(AuditSinkKey).(audit.Sink)
+		quotas := c.Get(RateLimitQuotasKey).(*ratelimit.Quotas)
+
+		return controller.NewAuditController(cfg, auditSink, quotas)
+	})
 }
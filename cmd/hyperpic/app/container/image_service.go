@@ -6,21 +6,63 @@ package container
 
 import (
 	service "github.com/euskadi31/go-service"
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/eager"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+	"github.com/rs/zerolog/log"
 )
 
 // Services keys
 const (
 	ImageOptionParserKey = "service.image.options.parser"
 	ImageProcessorKey    = "service.image.processor"
+	ImagePresetsKey      = "service.image.presets"
+	ImageEagerPoolKey    = "service.image.eager.pool"
 )
 
 func init() {
 	service.Set(ImageOptionParserKey, func(c service.Container) interface{} {
-		return image.NewOptionParser()
+		cfg := c.Get(ConfigKey).(*config.Configuration)
+
+		parser := image.NewOptionParser(cfg.Image.Options)
+
+		parser.SetPresets(c.Get(ImagePresetsKey).(*image.Presets))
+
+		return parser
+	})
+
+	service.Set(ImagePresetsKey, func(c service.Container) interface{} {
+		cfg := c.Get(ConfigKey).(*config.Configuration)
+
+		presets, err := image.NewPresets(cfg.Image.Presets)
+		if err != nil {
+			log.Fatal().Err(err).Msg(ImagePresetsKey)
+		}
+
+		presets.Run()
+
+		return presets
 	})
 
 	service.Set(ImageProcessorKey, func(c service.Container) interface{} {
-		return image.NewProcessor()
+		cfg := c.Get(ConfigKey).(*config.Configuration)
+
+		return image.NewProcessor(cfg.Image.Limits)
+	})
+
+	service.Set(ImageEagerPoolKey, func(c service.Container) interface{} {
+		cfg := c.Get(ConfigKey).(*config.Configuration)
+
+		if !cfg.Image.Eager.Enable {
+			return (*eager.Pool)(nil)
+		}
+
+		return eager.NewPool(
+			cfg.Image.Eager,
+			c.Get(ImageOptionParserKey).(*image.OptionParser),
+			c.Get(ImageProcessorKey).(image.Processor),
+			c.Get(CacheProviderKey).(provider.CacheProvider),
+		)
 	})
 }
@@ -0,0 +1,113 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package container
+
+import (
+	"fmt"
+
+	service "github.com/euskadi31/go-service"
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/eager"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/mount"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/namespace"
+	"github.com/rs/zerolog/log"
+)
+
+// Services keys
+const (
+	MountTableKey = "service.mount.table"
+)
+
+func init() {
+	service.Set(MountTableKey, func(c service.Container) interface{} {
+		cfg := c.Get(ConfigKey).(*config.Configuration)
+		cache := c.Get(CacheProviderKey).(provider.CacheProvider)
+		processor := c.Get(ImageProcessorKey).(image.Processor)
+
+		fallback := &mount.Mount{
+			Source:       c.Get(SourceProviderKey).(provider.SourceProvider),
+			Cache:        cache,
+			OptionParser: c.Get(ImageOptionParserKey).(*image.OptionParser),
+			Eager:        c.Get(ImageEagerPoolKey).(*eager.Pool),
+		}
+
+		// with mounts the default cache has its own namespace, a mount namespace
+		// would collide with the paths of the default source
+		if len(cfg.Mounts) > 0 {
+			fallback.Cache = namespace.NewCacheProvider(config.DefaultCacheNamespace, cache)
+		}
+
+		mounts := make([]*mount.Mount, 0, len(cfg.Mounts))
+		namespaces := map[string]bool{
+			config.DefaultCacheNamespace: true,
+		}
+
+		for _, mc := range cfg.Mounts {
+			ns := mc.CacheNamespace()
+			if namespaces[ns] {
+				log.Fatal().Err(fmt.Errorf("The cache namespace %s of the mount %s is already used or reserved", ns, mc.Name)).Msg(MountTableKey)
+			}
+
+			namespaces[ns] = true
+
+			m, err := newMount(c, mc, namespace.NewCacheProvider(ns, cache), processor)
+			if err != nil {
+				log.Fatal().Err(err).Msgf("Mount %s", mc.Name)
+			}
+
+			mounts = append(mounts, m)
+		}
+
+		table, err := mount.NewTable(fallback, mounts...)
+		if err != nil {
+			log.Fatal().Err(err).Msg(MountTableKey)
+		}
+
+		return table // *mount.Table
+	})
+}
+
+// newMount builds the source provider and the option parser of the mount
+func newMount(c service.Container, mc *config.MountConfiguration, cache provider.CacheProvider, processor image.Processor) (*mount.Mount, error) {
+	cfg := c.Get(ConfigKey).(*config.Configuration)
+
+	source, err := newImageSourceProvider(mc.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	presets := c.Get(ImagePresetsKey).(*image.Presets)
+
+	if mc.Presets != nil {
+		presets, err = image.NewPresets(mc.Presets)
+		if err != nil {
+			return nil, err
+		}
+
+		presets.Run()
+	}
+
+	parser := image.NewOptionParser(cfg.Image.Options)
+
+	parser.SetPresets(presets)
+	parser.SetDefaults(mc.DefaultOptions())
+
+	m := &mount.Mount{
+		Name:         mc.Name,
+		Host:         mc.Host,
+		Prefix:       mc.Prefix,
+		Source:       source,
+		Cache:        cache,
+		OptionParser: parser,
+	}
+
+	if cfg.Image.Eager.Enable {
+		m.Eager = eager.NewPool(cfg.Image.Eager, parser, processor, cache)
+	}
+
+	return m, nil
+}
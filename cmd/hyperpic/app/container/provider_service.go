@@ -10,7 +10,14 @@ import (
 	service "github.com/euskadi31/go-service"
 	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/azure"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/filesystem"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/gcs"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/layered"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/origin"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/s3"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/sftp"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/webdav"
 	"github.com/rs/zerolog/log"
 )
 
@@ -39,15 +46,57 @@ func init() {
 	service.Set(SourceProviderKey, func(c service.Container) interface{} {
 		cfg := c.Get(ConfigKey).(*config.Configuration)
 
-		var source provider.SourceProvider
-
-		switch cfg.Image.Source.Provider {
-		case "fs":
-			source = filesystem.NewSourceProvider(cfg.Image.Source.FS)
-		default:
-			log.Fatal().Err(fmt.Errorf("The source %s provider is not supported", cfg.Image.Source.Provider)).Msg("Source Provider")
+		source, err := newImageSourceProvider(cfg.Image.Source)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Source Provider")
 		}
 
 		return source
 	})
 }
+
+// newImageSourceProvider builds the source provider of the configuration
+func newImageSourceProvider(cfg *config.ImageSourceConfiguration) (provider.SourceProvider, error) {
+	if cfg.Provider != "layered" {
+		return newSourceProvider(cfg.Provider, cfg)
+	}
+
+	layers := make([]provider.SourceProvider, 0, len(cfg.Layered.Layers))
+
+	for _, name := range cfg.Layered.Layers {
+		if name == "layered" {
+			return nil, fmt.Errorf("The layered source provider cannot be nested")
+		}
+
+		layer, err := newSourceProvider(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		layers = append(layers, layer)
+	}
+
+	return layered.NewSourceProvider(cfg.Layered, layers...)
+}
+
+// newSourceProvider builds the source provider by name
+func newSourceProvider(name string, cfg *config.ImageSourceConfiguration) (provider.SourceProvider, error) {
+	switch name {
+	case "fs":
+		return filesystem.NewSourceProvider(cfg.FS), nil
+	case "s3":
+		return s3.NewSourceProvider(cfg.S3), nil
+	case "azure":
+		return azure.NewSourceProvider(cfg.Azure)
+	case "gcs":
+		return gcs.NewSourceProvider(cfg.GCS)
+	case "http":
+		return origin.NewSourceProvider(cfg.HTTP)
+	case "sftp":
+		return sftp.NewSourceProvider(cfg.SFTP)
+	case "webdav":
+		return webdav.NewSourceProvider(cfg.WebDAV)
+	default:
+		return nil, fmt.Errorf("The source %s provider is not supported", name)
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package container
+
+import (
+	service "github.com/euskadi31/go-service"
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/ratelimit"
+	"github.com/rs/zerolog/log"
+)
+
+// Services keys
+const (
+	RateLimitQuotasKey = "service.rate_limit.quotas"
+)
+
+func init() {
+	service.Set(RateLimitQuotasKey, func(c service.Container) interface{} {
+		cfg := c.Get(ConfigKey).(*config.Configuration)
+
+		quotas, err := ratelimit.NewQuotas(cfg.RateLimit)
+		if err != nil {
+			log.Fatal().Err(err).Msg(RateLimitQuotasKey)
+		}
+
+		return quotas // *ratelimit.Quotas
+	})
+}
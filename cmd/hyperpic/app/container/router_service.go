@@ -28,6 +28,7 @@ func init() {
 		logger := c.Get(LoggerKey).(zerolog.Logger)
 		docController := c.Get(DocControllerKey).(server.Controller)
 		imageController := c.Get(ImageControllerKey).(server.Controller)
+		auditController := c.Get(AuditControllerKey).(server.Controller)
 
 		router := server.New(cfg.Server.ToConfig())
 
@@ -54,6 +55,11 @@ func init() {
 			router.AddController(docController)
 		}
 
+		// mounted before the image routes matching all the paths
+		if cfg.Audit.Enable {
+			router.AddController(auditController)
+		}
+
 		router.AddController(imageController)
 
 		return router // *server.Server
@@ -0,0 +1,123 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	server "github.com/euskadi31/go-server"
+	"github.com/euskadi31/go-server/response"
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/metrics"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/audit"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/middlewares"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/ratelimit"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog/hlog"
+)
+
+// Limits of the entries returned by the audit endpoint
+const (
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+)
+
+var errAuditNotReadable = errors.New("the audit log cannot be read back")
+
+type auditController struct {
+	cfg    *config.Configuration
+	sink   audit.Sink
+	quotas *ratelimit.Quotas
+}
+
+// NewAuditController func
+func NewAuditController(cfg *config.Configuration, sink audit.Sink, quotas *ratelimit.Quotas) server.Controller {
+	return &auditController{
+		cfg:    cfg,
+		sink:   sink,
+		quotas: quotas,
+	}
+}
+
+// Mount endpoints
+func (c auditController) Mount(r *server.Router) {
+	// reading the log needs a key with the admin scope and is recorded
+	chain := alice.New(
+		middlewares.NewAuditHandler(c.sink, c.quotas, audit.ActionQuery),
+		middlewares.NewAdminAuthHandler(c.cfg.Auth),
+		middlewares.NewRateLimitHandler(c.quotas, metrics.RateLimited),
+	)
+
+	r.AddRoute("/_audit", chain.ThenFunc(c.getHandler)).Methods(http.MethodGet)
+}
+
+// parseAuditFilter reads the filter from the query: path prefix, from and to in RFC3339 and limit
+func parseAuditFilter(r *http.Request) (*audit.Filter, error) {
+	query := r.URL.Query()
+
+	filter := &audit.Filter{
+		Path:  query.Get("path"),
+		Limit: auditDefaultLimit,
+	}
+
+	var err error
+
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return nil, err
+		}
+	}
+
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return nil, err
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			return nil, errors.New("limit must be a positive integer")
+		}
+	}
+
+	if filter.Limit > auditMaxLimit {
+		filter.Limit = auditMaxLimit
+	}
+
+	return filter, nil
+}
+
+// GET /_audit
+func (c auditController) getHandler(w http.ResponseWriter, r *http.Request) {
+	reader, ok := c.sink.(audit.Reader)
+	if !ok {
+		response.FailureFromError(w, http.StatusNotImplemented, errAuditNotReadable)
+
+		return
+	}
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		response.FailureFromError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	entries, err := reader.Query(filter)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Audit log query failed")
+
+		response.FailureFromError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	response.Encode(w, r, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+	})
+}
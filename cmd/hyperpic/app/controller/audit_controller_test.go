@@ -0,0 +1,158 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	server "github.com/euskadi31/go-server"
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/audit"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/auth"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuditControllerGetEntries(t *testing.T) {
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	sink, err := audit.NewFileSink(filepath.Join(t.TempDir(), "audit.log"))
+	assert.NoError(t, err)
+
+	defer sink.Close()
+
+	cfg := &config.Configuration{
+		Auth: &config.AuthConfiguration{
+			Secret: "admin",
+			Keys: []auth.Key{
+				{Name: "catalog", Hash: auth.HashKey("foo"), Scopes: []string{auth.ScopeUpload, auth.ScopeDelete}, Paths: []string{"/products/"}},
+			},
+			JWT: &auth.JWTConfiguration{
+				Enable: true,
+				Keys: []auth.JWTKey{
+					{Algorithm: auth.AlgorithmHS256, Secret: "secret"},
+				},
+			},
+		},
+		Image: &config.ImageConfiguration{
+			Source: &config.ImageSourceConfiguration{
+				MaxSize: 10 << 20,
+			},
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg": true,
+				},
+			},
+		},
+	}
+
+	sourceProvider := &provider.MockSourceProvider{}
+	sourceProvider.On("Set", mock.Anything).Return(nil)
+	sourceProvider.On("Get", mock.Anything).Return(&image.Resource{Body: data}, nil)
+	sourceProvider.On("Del", mock.Anything).Return(nil)
+
+	cacheProvider := &provider.MockCacheProvider{}
+	cacheProvider.On("Del", mock.Anything).Return(nil)
+
+	router := server.NewRouter()
+
+	router.AddController(NewAuditController(cfg, sink, nil))
+	router.AddController(NewImageController(cfg, image.NewOptionParser(nil), &image.MockProcessor{}, sourceProvider, cacheProvider, nil, nil, sink, nil))
+
+	request := func(method string, url string, token string, body []byte) *http.Response {
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		return w.Result()
+	}
+
+	assert.Equal(t, http.StatusCreated, request(http.MethodPost, "/products/kayaks.jpg", "foo", data).StatusCode)
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/users/kayaks.jpg", "foo", data).StatusCode)
+	assert.Equal(t, http.StatusOK, request(http.MethodDelete, "/products/kayaks.jpg?from=source", "foo", nil).StatusCode)
+
+	// the audit log can only be read with the admin scope
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/_audit", "foo", nil).StatusCode)
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/_audit", "eyJhbGciOiJIUzI1NiJ9.e30.c2ln", nil).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/_audit?from=yesterday", "admin", nil).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/_audit?limit=-1", "admin", nil).StatusCode)
+
+	resp := request(http.MethodGet, "/_audit?path=/products/", "admin", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	result := struct {
+		Entries []*audit.Entry `json:"entries"`
+	}{}
+
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Len(t, result.Entries, 2)
+
+	hash := "8138fdd61f7d8b3ac0d0f11cd2fe994fe37f8657cb93f6e8f818606294c7079e"
+
+	upload := result.Entries[0]
+	assert.Equal(t, audit.ActionUpload, upload.Action)
+	assert.Equal(t, "catalog", upload.Actor)
+	assert.Equal(t, hash, upload.Hash)
+	assert.Equal(t, len(data), upload.Size)
+	assert.Equal(t, http.StatusCreated, upload.Status)
+	assert.Equal(t, audit.OutcomeSuccess, upload.Outcome)
+
+	del := result.Entries[1]
+	assert.Equal(t, audit.ActionDelete, del.Action)
+	assert.Equal(t, hash, del.Hash)
+
+	resp = request(http.MethodGet, "/_audit?limit=2", "admin", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Len(t, result.Entries, 2)
+
+	// the queries are recorded, the invalid ones included
+	assert.Equal(t, "/_audit", result.Entries[0].Path)
+	assert.Equal(t, audit.ActionQuery, result.Entries[0].Action)
+	assert.Equal(t, audit.OutcomeFailure, result.Entries[0].Outcome)
+	assert.Equal(t, audit.OutcomeSuccess, result.Entries[1].Outcome)
+	assert.Equal(t, "secret", result.Entries[1].Actor)
+
+	resp = request(http.MethodGet, "/_audit?path=/users/", "admin", nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Len(t, result.Entries, 1)
+	assert.Equal(t, audit.OutcomeDenied, result.Entries[0].Outcome)
+}
+
+func TestAuditControllerWithWriteOnlySink(t *testing.T) {
+	cfg := &config.Configuration{
+		Auth: &config.AuthConfiguration{
+			Secret: "admin",
+		},
+	}
+
+	sink := &audit.MockSink{}
+	sink.On("Write", mock.Anything).Return(nil)
+
+	router := server.NewRouter()
+
+	router.AddController(NewAuditController(cfg, sink, nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/_audit", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Result().StatusCode)
+}
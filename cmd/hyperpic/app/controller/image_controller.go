@@ -5,33 +5,56 @@
 package controller
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"time"
 
 	server "github.com/euskadi31/go-server"
 	"github.com/euskadi31/go-server/response"
+	"github.com/h2non/bimg"
 	"github.com/h2non/filetype"
 	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
 	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/metrics"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/audit"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/eager"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/httputil"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/middlewares"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/mount"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/ratelimit"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/signature"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/srcset"
 	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
 )
 
+// signUploadPrefix is the prefix of the path to sign an upload url for
+const signUploadPrefix = "/_sign-upload"
+
+// defaultUploadTTL is the expiry of the signed upload urls when signature.upload_ttl is not set
+const defaultUploadTTL = 15 * time.Minute
+
+var errContentTypeNotAllowed = errors.New("content type not allowed by the signed upload url")
+
 type imageController struct {
 	cfg            *config.Configuration
-	optionParser   *image.OptionParser
 	imageProcessor image.Processor
-	sourceProvider provider.SourceProvider
-	cacheProvider  provider.CacheProvider
+	quotas         *ratelimit.Quotas
+	auditSink      audit.Sink
+	signer         *signature.Signer
+	mounts         *mount.Table
+	auths          map[string]*config.AuthConfiguration
 }
 
 // NewImageController func
@@ -41,36 +64,133 @@ func NewImageController(
 	imageProcessor image.Processor,
 	sourceProvider provider.SourceProvider,
 	cacheProvider provider.CacheProvider,
+	eagerPool *eager.Pool,
+	quotas *ratelimit.Quotas,
+	auditSink audit.Sink,
+	mounts *mount.Table,
 ) server.Controller {
-	return &imageController{
+	c := &imageController{
 		cfg:            cfg,
-		optionParser:   optionParser,
 		imageProcessor: imageProcessor,
-		sourceProvider: sourceProvider,
-		cacheProvider:  cacheProvider,
+		quotas:         quotas,
+		auditSink:      auditSink,
+		mounts:         mounts,
+		auths:          map[string]*config.AuthConfiguration{},
+	}
+
+	// the requests are served by the default mount without mount table
+	if c.mounts == nil {
+		c.mounts, _ = mount.NewTable(&mount.Mount{
+			Source:       sourceProvider,
+			Cache:        cacheProvider,
+			OptionParser: optionParser,
+			Eager:        eagerPool,
+		})
 	}
+
+	for _, m := range cfg.Mounts {
+		if m.Auth != nil {
+			c.auths[m.Name] = m.Auth
+		}
+	}
+
+	// the srcset urls are signed when the server has a key
+	if cfg.Signature != nil && len(cfg.Signature.Keys) > 0 {
+		c.signer = signature.NewSigner(cfg.Signature)
+	}
+
+	return c
 }
 
-// Mount endpoints
+// Mount endpoints, each mount has its own middlewares
 func (c imageController) Mount(r *server.Router) {
-	chain := alice.New(
-		middlewares.NewPathHandler(),
-		middlewares.NewImageExtensionFilterHandler(c.cfg),
-	)
+	sign := map[string]http.Handler{}
+	get := map[string]http.Handler{}
+	post := map[string]http.Handler{}
+	del := map[string]http.Handler{}
+
+	for _, m := range c.mounts.All() {
+		auth := c.authConfiguration(m)
+
+		chain := alice.New(
+			middlewares.NewPathHandler(),
+			middlewares.NewImageExtensionFilterHandler(c.cfg),
+		)
+
+		public := chain.Append(
+			middlewares.NewHotlinkHandler(c.cfg.Image.Hotlink),
+			middlewares.NewSignatureHandler(c.signatureConfiguration(), m.SignatureScope()),
+			middlewares.NewRateLimitHandler(c.quotas, metrics.RateLimited),
+			middlewares.NewOptionsHandler(m.OptionParser),
+			middlewares.NewContentTypeHandler(),
+			middlewares.NewClientHintsHandler(c.cfg.Image.Lockdown),
+			middlewares.NewLockdownHandler(c.cfg.Image.Lockdown, m.OptionParser),
+		)
+
+		private := chain.Append(
+			middlewares.NewAuditHandler(c.auditSink, c.quotas, ""),
+			middlewares.NewSignedUploadHandler(c.signatureConfiguration(), m.Name),
+			middlewares.NewAuthHandler(auth),
+			middlewares.NewRateLimitHandler(c.quotas, metrics.RateLimited),
+		)
+
+		// the key must be allowed to upload to the path to sign
+		signer := alice.New(
+			middlewares.NewPathHandler(),
+			middlewares.NewAuditHandler(c.auditSink, c.quotas, audit.ActionSignUpload),
+			middlewares.NewAuthHandler(auth),
+			middlewares.NewRateLimitHandler(c.quotas, metrics.RateLimited),
+		)
+
+		sign[m.Name] = signer.ThenFunc(c.signUploadHandler)
+		get[m.Name] = public.ThenFunc(c.getHandler)
+		post[m.Name] = private.ThenFunc(c.postHandler)
+		del[m.Name] = private.ThenFunc(c.deleteHandler)
+	}
 
-	public := chain.Append(
-		middlewares.NewOptionsHandler(c.optionParser),
-		middlewares.NewContentTypeHandler(),
-		middlewares.NewClientHintsHandler(),
-	)
+	r.AddPrefixRoute(signUploadPrefix+"/", http.StripPrefix(signUploadPrefix, c.mountHandler(sign))).Methods(http.MethodPost)
+	r.AddPrefixRoute("/", c.mountHandler(get)).Methods(http.MethodGet)
+	r.AddPrefixRoute("/", c.mountHandler(post)).Methods(http.MethodPost)
+	r.AddPrefixRoute("/", c.mountHandler(del)).Methods(http.MethodDelete)
+}
 
-	private := chain.Append(
-		middlewares.NewAuthHandler(c.cfg.Auth),
-	)
+// mountHandler resolves the mount of the request and serves it with the handler of the mount
+func (c imageController) mountHandler(handlers map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := c.mounts.Resolve(r)
+
+		hlog.FromRequest(r).UpdateContext(func(ctx zerolog.Context) zerolog.Context {
+			return ctx.Str("mount", m.Name)
+		})
 
-	r.AddPrefixRoute("/", public.ThenFunc(c.getHandler)).Methods(http.MethodGet)
-	r.AddPrefixRoute("/", private.ThenFunc(c.postHandler)).Methods(http.MethodPost)
-	r.AddPrefixRoute("/", private.ThenFunc(c.deleteHandler)).Methods(http.MethodDelete)
+		handlers[m.Name].ServeHTTP(w, r.WithContext(mount.NewContext(r.Context(), m)))
+	})
+}
+
+// mount returns the mount serving the request
+func (c imageController) mount(r *http.Request) *mount.Mount {
+	if m, ok := mount.FromContext(r.Context()); ok {
+		return m
+	}
+
+	return c.mounts.Fallback()
+}
+
+// authConfiguration returns the auth of the mount, the global auth is used when the mount has none
+func (c imageController) authConfiguration(m *mount.Mount) *config.AuthConfiguration {
+	if auth, ok := c.auths[m.Name]; ok {
+		return auth
+	}
+
+	return c.cfg.Auth
+}
+
+func (c imageController) signatureConfiguration() *signature.Configuration {
+	if c.cfg.Signature == nil {
+		return &signature.Configuration{}
+	}
+
+	return c.cfg.Signature
 }
 
 // GET /:file
@@ -89,15 +209,23 @@ func (c imageController) getHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	// xlog.Infof("options: %#v", options)
 
+	m := c.mount(r)
+
 	resource := &image.Resource{
-		Path:    r.URL.Path,
+		Path:    m.Path(r.URL.Path),
 		Options: options,
 	}
 
 	// w.Header().Set("Link", `</worker/client-hints.js>; rel="serviceworker"`)
 
+	if query := r.URL.Query(); query.Get("srcset") != "" {
+		c.srcsetHandler(w, r, m, resource, query)
+
+		return
+	}
+
 	// fetch from cache
-	if resource, err := c.cacheProvider.Get(resource); err == nil {
+	if resource, err := m.Cache.Get(resource); err == nil {
 		w.Header().Set("X-Image-From", "cache")
 
 		httputil.ServeImage(w, r, resource)
@@ -108,26 +236,62 @@ func (c imageController) getHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resource, err = c.sourceProvider.Get(resource)
-	if err != nil {
-		if os.IsNotExist(err) {
-			msg := fmt.Sprintf("File %s not found", r.URL.Path)
+	// cache misses have their own budget
+	release, budget, retryAfter := c.quotas.AcquireProcessing(middlewares.RateLimitFromContext(ctx))
+	if release == nil {
+		log.Info().Str("budget", budget).Msg("Processing quota exceeded")
 
-			log.Info().Msg(msg)
+		metrics.RateLimited.With(map[string]string{"budget": budget}).Add(1)
 
-			http.Error(w, msg, http.StatusNotFound)
+		middlewares.TooManyRequests(w, retryAfter)
 
-			return
-		}
+		return
+	}
+	defer release()
+
+	from := "source"
 
-		log.Error().Err(err).Msg("Source Provider")
+	var stream io.ReadCloser
 
-		http.Error(w, err.Error(), http.StatusNotFound)
+	// use a larger cached derivative instead of decoding the original
+	if derivative := c.findDerivative(m, resource); derivative != nil {
+		log.Debug().Msgf("Using derivative %dx%d", derivative.Derivative.Width, derivative.Derivative.Height)
+
+		from = "derivative"
+		resource = derivative
+
+		metrics.DerivativeHit.With(map[string]string{}).Add(1)
+	} else {
+		resource, stream, err = c.openSource(m, resource)
+	}
+
+	if err != nil {
+		c.sourceError(w, r, err)
 
 		return
 	}
 
-	if err := c.imageProcessor.ProcessImage(resource); err != nil {
+	if stream != nil {
+		defer stream.Close()
+
+		err = c.imageProcessor.ProcessStream(stream, resource)
+	} else {
+		err = c.imageProcessor.ProcessImage(resource)
+	}
+
+	if err != nil {
+		var limitErr *image.LimitError
+
+		if errors.As(err, &limitErr) {
+			log.Info().Err(err).Msg("Image limit exceeded")
+
+			metrics.ImageLimitExceeded.With(map[string]string{"limit": limitErr.Limit}).Add(1)
+
+			response.FailureFromError(w, limitErr.StatusCode(), err)
+
+			return
+		}
+
 		log.Error().Err(err).Msg("Error while processing the image")
 
 		http.Error(w, "Error while processing the image", http.StatusInternalServerError)
@@ -135,13 +299,13 @@ func (c imageController) getHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("X-Image-From", "source")
+	w.Header().Set("X-Image-From", from)
 
 	httputil.ServeImage(w, r, resource)
 
 	// save resource in cache
 	go func(r *image.Resource) {
-		if err := c.cacheProvider.Set(r); err != nil {
+		if err := m.Cache.Set(r); err != nil {
 			log.Error().Err(err).Msg("Cache Provider")
 		}
 	}(resource)
@@ -150,6 +314,195 @@ func (c imageController) getHandler(w http.ResponseWriter, r *http.Request) {
 	metrics.ImageDeliveredBytes.With(map[string]string{}).Add(float64(resource.Size))
 }
 
+// sourceError writes the error returned by the source provider
+func (c imageController) sourceError(w http.ResponseWriter, r *http.Request, err error) {
+	log := hlog.FromRequest(r)
+
+	if os.IsNotExist(err) {
+		msg := fmt.Sprintf("File %s not found", r.URL.Path)
+
+		log.Info().Msg(msg)
+
+		http.Error(w, msg, http.StatusNotFound)
+
+		return
+	}
+
+	log.Error().Err(err).Msg("Source Provider")
+
+	http.Error(w, err.Error(), http.StatusNotFound)
+}
+
+// GET /:file?srcset=320,640,1280&sizes=100vw&fmt=html|json
+func (c imageController) srcsetHandler(w http.ResponseWriter, r *http.Request, m *mount.Mount, resource *image.Resource, query url.Values) {
+	widths, err := srcset.ParseWidths(query.Get("srcset"))
+	if err != nil {
+		response.FailureFromError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	format := query.Get("fmt")
+	if format == "" {
+		format = "html"
+	}
+
+	if format != "html" && format != "json" {
+		response.FailureFromError(w, http.StatusBadRequest, fmt.Errorf("srcset: unsupported fmt %s", format))
+
+		return
+	}
+
+	stream, err := c.openHeader(m, resource)
+	if err != nil {
+		c.sourceError(w, r, err)
+
+		return
+	}
+
+	defer stream.Close()
+
+	// only the header is read to get the dimensions
+	width, height, imageType, err := image.Header(stream)
+	if err != nil {
+		response.FailureFromError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	// the variants are signed only for a signed request, the server does not
+	// sign the options of an anonymous client
+	var signer srcset.Signer
+	if middlewares.IsSignedFromContext(r.Context()) {
+		signer = c.mountSigner(m)
+	}
+
+	set, err := srcset.New(
+		r.URL.Path,
+		query,
+		resource.Options,
+		width,
+		height,
+		widths,
+		srcsetFormats(query, imageType),
+		signer,
+		func(q url.Values) error {
+			return middlewares.CheckLockdown(c.cfg.Image.Lockdown, m.OptionParser, q)
+		},
+	)
+	if err != nil {
+		var lockdownErr *middlewares.LockdownError
+		if errors.As(err, &lockdownErr) {
+			middlewares.LockdownFailure(w, lockdownErr.Fields)
+
+			return
+		}
+
+		response.FailureFromError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if format == "json" {
+		response.Encode(w, r, http.StatusOK, set)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	_, _ = io.WriteString(w, set.HTML())
+}
+
+// openHeader returns a reader of the source, without loading it when the provider can stream it
+func (c imageController) openHeader(m *mount.Mount, resource *image.Resource) (io.ReadCloser, error) {
+	if sp, ok := m.Source.(provider.StreamSourceProvider); ok {
+		_, stream, err := sp.Open(resource)
+
+		return stream, err
+	}
+
+	source, err := m.Source.Get(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(source.Body)), nil
+}
+
+// srcsetFormats returns the formats of the picture sources, the last one is the fallback
+func srcsetFormats(query url.Values, imageType bimg.ImageType) []string {
+	if fm := query.Get("fm"); fm != "" {
+		return []string{fm}
+	}
+
+	formats := []string{}
+
+	if bimg.IsTypeSupportedSave(bimg.AVIF) {
+		formats = append(formats, "avif")
+	}
+
+	formats = append(formats, "webp")
+
+	if imageType == bimg.PNG {
+		return append(formats, "png")
+	}
+
+	return append(formats, "jpg")
+}
+
+// findDerivative returns a cached derivative usable as source for the resource, or nil
+func (c imageController) findDerivative(m *mount.Mount, resource *image.Resource) *image.Resource {
+	if c.cfg.Image.Derivative == nil {
+		return nil
+	}
+
+	criteria := c.cfg.Image.Derivative.Criteria(resource.Options)
+	if criteria == nil {
+		return nil
+	}
+
+	derivative, err := m.Cache.FindDerivative(resource, criteria)
+	if err != nil {
+		return nil
+	}
+
+	return derivative
+}
+
+// openSource returns the source resource, large sources which can be thumbnailed
+// are returned with a reader instead of being loaded in memory
+func (c imageController) openSource(m *mount.Mount, resource *image.Resource) (*image.Resource, io.ReadCloser, error) {
+	sp, ok := m.Source.(provider.StreamSourceProvider)
+	if !ok || c.cfg.Image.Source.StreamMinSize <= 0 || !resource.Options.IsThumbnail() {
+		resource, err := m.Source.Get(resource)
+
+		return resource, nil, err
+	}
+
+	source, stream, err := sp.Open(resource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if int64(source.Size) >= c.cfg.Image.Source.StreamMinSize {
+		return source, stream, nil
+	}
+
+	defer stream.Close()
+
+	body, err := io.ReadAll(stream)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source.Body = body
+	source.Size = len(body)
+
+	return source, nil, nil
+}
+
 func (c imageController) parseImageFileFromRequest(w http.ResponseWriter, r *http.Request) ([]byte, error) {
 	if r.Body == nil {
 		return nil, errors.New("missing form body")
@@ -192,8 +545,10 @@ func (c imageController) parseImageFileFromRequest(w http.ResponseWriter, r *htt
 func (c imageController) postHandler(w http.ResponseWriter, r *http.Request) {
 	log := hlog.FromRequest(r)
 
+	m := c.mount(r)
+
 	resource := &image.Resource{
-		Path: r.URL.Path,
+		Path: m.Path(r.URL.Path),
 	}
 
 	body, err := c.parseImageFileFromRequest(w, r)
@@ -211,21 +566,92 @@ func (c imageController) postHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if policy, ok := middlewares.UploadPolicyFromContext(r.Context()); ok && !policy.AllowContentType(detectContentType(body)) {
+		log.Info().Msg("Content type not allowed by the signed upload url")
+
+		response.FailureFromError(w, http.StatusUnsupportedMediaType, errContentTypeNotAllowed)
+
+		return
+	}
+
+	if c.cfg.Image.Upload != nil {
+		body, err = c.cfg.Image.Upload.Process(body)
+		if err != nil {
+			c.uploadError(w, r, err)
+
+			return
+		}
+	}
+
 	resource.Body = body
 
-	if err := c.sourceProvider.Set(resource); err != nil {
+	if err := m.Source.Set(resource); err != nil {
 		response.FailureFromError(w, http.StatusInternalServerError, err)
 
 		return
 	}
 
-	// delete cache from source file
-	go func() {
-		if err := c.cacheProvider.Del(resource); err != nil {
+	transforms := c.eagerTransforms(r, m)
+
+	if len(transforms) > 0 {
+		// the stale cache must be deleted before the eager derivatives are stored
+		if err := m.Cache.Del(resource); err != nil {
 			log.Error().Err(err).Msg("CacheProvider.Del failed")
 		}
-	}()
+	} else {
+		// delete cache from source file
+		go func() {
+			if err := m.Cache.Del(resource); err != nil {
+				log.Error().Err(err).Msg("CacheProvider.Del failed")
+			}
+		}()
+	}
+
+	mimeType := detectContentType(body)
+
+	h := sha256.New()
+	length, _ := h.Write(body)
+
+	hash := fmt.Sprintf("%x", h.Sum(nil))
+
+	if entry, ok := middlewares.AuditEntryFromContext(r.Context()); ok {
+		entry.Hash = hash
+		entry.Size = length
+	}
+
+	result := map[string]interface{}{
+		"file": r.URL.Path,
+		"size": length,
+		"type": mimeType,
+		"hash": hash,
+	}
+
+	if len(transforms) > 0 {
+		resource.Name = path.Base(resource.Path)
+		resource.Size = len(body)
+		resource.ModifiedAt = time.Now()
+
+		tasks := m.Eager.Submit(resource, transforms)
+
+		for i, task := range tasks {
+			// the urls of the tasks are served under the prefix of the mount
+			if task.URL != "" {
+				tasks[i].URL = c.signURL(m, m.Prefix+task.URL)
+			}
 
+			metrics.ImageEager.With(map[string]string{"status": task.Status}).Add(1)
+		}
+
+		result["eager"] = tasks
+	}
+
+	response.Encode(w, r, http.StatusCreated, result)
+
+	metrics.ImageReceivedBytes.With(map[string]string{}).Add(float64(length))
+}
+
+// detectContentType returns the mime type of the image
+func detectContentType(body []byte) string {
 	mimeType := http.DetectContentType(body)
 
 	// If cannot infer the type, infer it via magic numbers
@@ -236,23 +662,104 @@ func (c imageController) postHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	h := sha256.New()
-	length, _ := h.Write(body)
+	return mimeType
+}
 
-	response.Encode(w, r, http.StatusCreated, map[string]interface{}{
-		"file": r.URL.Path,
-		"size": length,
-		"type": mimeType,
-		"hash": fmt.Sprintf("%x", h.Sum(nil)),
+// signUploadRequest is the optional body of the sign upload request
+type signUploadRequest struct {
+	MaxSize      int64    `json:"max_size"`
+	ContentTypes []string `json:"content_types"`
+	ExpiresIn    int64    `json:"expires_in"`
+}
+
+// POST /_sign-upload/:file
+func (c imageController) signUploadHandler(w http.ResponseWriter, r *http.Request) {
+	req := signUploadRequest{}
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil && err != io.EOF {
+			response.FailureFromError(w, http.StatusBadRequest, err)
+
+			return
+		}
+	}
+
+	// the body size is bounded by the size accepted by the server
+	maxSize := c.cfg.Image.Source.MaxSize
+	if req.MaxSize > 0 && req.MaxSize < maxSize {
+		maxSize = req.MaxSize
+	}
+
+	ttl := c.signatureConfiguration().UploadTTL
+	if ttl <= 0 {
+		ttl = defaultUploadTTL
+	}
+
+	if expiresIn := time.Duration(req.ExpiresIn) * time.Second; expiresIn > 0 && expiresIn < ttl {
+		ttl = expiresIn
+	}
+
+	policy := signature.UploadPolicy{
+		Path:         r.URL.Path,
+		MaxSize:      maxSize,
+		ContentTypes: req.ContentTypes,
+		Expires:      time.Now().Add(ttl).Truncate(time.Second),
+		Mount:        c.mount(r).Name,
+	}
+
+	query, err := signature.NewSigner(c.signatureConfiguration()).SignUpload(policy)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Upload url signing failed")
+
+		response.FailureFromError(w, http.StatusServiceUnavailable, err)
+
+		return
+	}
+
+	response.Encode(w, r, http.StatusOK, map[string]interface{}{
+		"url":           policy.Path + "?" + query.Encode(),
+		"path":          policy.Path,
+		"max_size":      policy.MaxSize,
+		"content_types": policy.ContentTypes,
+		"expires_at":    policy.Expires,
 	})
+}
 
-	metrics.ImageReceivedBytes.With(map[string]string{}).Add(float64(length))
+// uploadError writes the rule of the upload policy rejecting the image
+func (c imageController) uploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var uploadErr *image.UploadError
+
+	if !errors.As(err, &uploadErr) {
+		hlog.FromRequest(r).Error().Err(err).Msg("Upload processing failed")
+
+		response.FailureFromError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	hlog.FromRequest(r).Info().Err(err).Msg("Upload rejected")
+
+	metrics.UploadRejected.With(map[string]string{"rule": uploadErr.Rule}).Add(1)
+
+	httputil.Failure(w, http.StatusBadRequest, httputil.ErrorMessage{
+		Code:    http.StatusBadRequest,
+		Message: "Upload rejected",
+		Fields: []httputil.ErrorField{
+			{
+				Name:    uploadErr.Rule,
+				Value:   uploadErr.Value,
+				Message: uploadErr.Message,
+			},
+		},
+	})
 }
 
 // DELETE /:file
 func (c imageController) deleteHandler(w http.ResponseWriter, r *http.Request) {
+	m := c.mount(r)
+
 	resource := &image.Resource{
-		Path: r.URL.Path,
+		Path: m.Path(r.URL.Path),
 	}
 
 	resp := map[string]bool{
@@ -263,12 +770,82 @@ func (c imageController) deleteHandler(w http.ResponseWriter, r *http.Request) {
 	if from := r.URL.Query().Get("from"); from != "" {
 		switch from {
 		case "source":
-			resp["cache"] = (c.cacheProvider.Del(resource) == nil)
-			resp["source"] = (c.sourceProvider.Del(resource) == nil)
+			c.auditSource(r, m, resource)
+
+			resp["cache"] = (m.Cache.Del(resource) == nil)
+			resp["source"] = (m.Source.Del(resource) == nil)
 		default:
-			resp["cache"] = (c.cacheProvider.Del(resource) == nil)
+			resp["cache"] = (m.Cache.Del(resource) == nil)
 		}
 	}
 
 	response.Encode(w, r, http.StatusOK, resp)
 }
+
+// auditSource records the hash and the size of the source deleted in the audit entry
+func (c imageController) auditSource(r *http.Request, m *mount.Mount, resource *image.Resource) {
+	entry, ok := middlewares.AuditEntryFromContext(r.Context())
+	if !ok {
+		return
+	}
+
+	source, err := m.Source.Get(resource)
+	if err != nil {
+		return
+	}
+
+	entry.Hash = fmt.Sprintf("%x", sha256.Sum256(source.Body))
+	entry.Size = len(source.Body)
+}
+
+// mountSigner returns the signer of the urls of the mount, nil when the server has no key
+func (c imageController) mountSigner(m *mount.Mount) srcset.Signer {
+	if c.signer == nil {
+		return nil
+	}
+
+	return c.signer.WithScope(m.SignatureScope())
+}
+
+// signURL signs the url of the mount with the key of the server, like the srcset urls,
+// the url refused by the lockdown is not signed
+func (c imageController) signURL(m *mount.Mount, rawurl string) string {
+	signer := c.mountSigner(m)
+	if signer == nil {
+		return rawurl
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+
+	if err := middlewares.CheckLockdown(c.cfg.Image.Lockdown, m.OptionParser, u.Query()); err != nil {
+		return rawurl
+	}
+
+	return u.Path + "?" + signer.Sign(u.Path, u.Query()).Encode()
+}
+
+// eagerTransforms returns the transforms of the eager rules matching the path
+// and of the eager form fields of the upload, the policy of a signed upload url
+// does not sign the eager fields so they are ignored
+func (c imageController) eagerTransforms(r *http.Request, m *mount.Mount) []string {
+	if m.Eager == nil {
+		return nil
+	}
+
+	transforms := c.cfg.Image.Eager.Transforms(r.URL.Path)
+
+	if _, ok := middlewares.UploadPolicyFromContext(r.Context()); ok {
+		return transforms
+	}
+
+	transforms = append(transforms, r.URL.Query()["eager"]...)
+
+	if r.MultipartForm != nil {
+		transforms = append(transforms, r.MultipartForm.Value["eager"]...)
+	}
+
+	return transforms
+}
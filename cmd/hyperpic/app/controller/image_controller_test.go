@@ -6,22 +6,34 @@ package controller
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 	"time"
 
 	server "github.com/euskadi31/go-server"
+	"github.com/h2non/bimg"
 	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/auth"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/eager"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/mount"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/filesystem"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/memory"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/namespace"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/ratelimit"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/signature"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/srcset"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -46,7 +58,7 @@ func TestImageControllerGetNotFoundImage(t *testing.T) {
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
+	optionsParser := image.NewOptionParser(nil)
 	sourceProvider := filesystem.NewSourceProvider(cfg.Image.Source.FS)
 	cacheProvider := &provider.MockCacheProvider{}
 
@@ -60,7 +72,7 @@ func TestImageControllerGetNotFoundImage(t *testing.T) {
 
 	imageProcessor := &image.MockProcessor{}
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
@@ -91,7 +103,7 @@ func TestImageControllerGetImageWithSourceProviderError(t *testing.T) {
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
+	optionsParser := image.NewOptionParser(nil)
 
 	sourceProvider := &provider.MockSourceProvider{}
 
@@ -115,7 +127,7 @@ func TestImageControllerGetImageWithSourceProviderError(t *testing.T) {
 
 	imageProcessor := &image.MockProcessor{}
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
@@ -146,7 +158,7 @@ func TestImageControllerGetImageWithProccessImageError(t *testing.T) {
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
+	optionsParser := image.NewOptionParser(nil)
 
 	sourceProvider := &provider.MockSourceProvider{}
 
@@ -183,7 +195,7 @@ func TestImageControllerGetImageWithProccessImageError(t *testing.T) {
 		return true
 	})).Return(errors.New("foo"))
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
@@ -200,6 +212,70 @@ func TestImageControllerGetImageWithProccessImageError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 }
 
+func TestImageControllerGetImageWithProcessingQuota(t *testing.T) {
+	cfg := &config.Configuration{
+		Image: &config.ImageConfiguration{
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+		},
+	}
+
+	quotas, err := ratelimit.NewQuotas(&ratelimit.Configuration{
+		Enable:     true,
+		Processing: ratelimit.BucketConfiguration{Rate: 0.1, Burst: 1},
+	})
+	assert.NoError(t, err)
+
+	optionsParser := image.NewOptionParser(nil)
+
+	sourceProvider := &provider.MockSourceProvider{}
+
+	sourceProvider.On("Get", mock.AnythingOfType("*image.Resource")).Return(&image.Resource{
+		Path:       "/kayaks.jpg",
+		ModifiedAt: time.Now(),
+	}, nil).Once()
+
+	cacheProvider := &provider.MockCacheProvider{}
+
+	cacheProvider.On("Get", mock.AnythingOfType("*image.Resource")).Return(nil, errors.New("not exist"))
+
+	imageProcessor := &image.MockProcessor{}
+
+	imageProcessor.On("ProcessImage", mock.AnythingOfType("*image.Resource")).Return(errors.New("foo")).Once()
+
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, quotas, nil, nil)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?w=40&h=40&q=85&fm=webp", nil)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "10", resp.Header.Get("Retry-After"))
+
+	sourceProvider.AssertExpectations(t)
+	imageProcessor.AssertExpectations(t)
+}
+
 func TestImageControllerGetImageWithFormatInQueryString(t *testing.T) {
 	cfg := &config.Configuration{
 		Image: &config.ImageConfiguration{
@@ -219,7 +295,7 @@ func TestImageControllerGetImageWithFormatInQueryString(t *testing.T) {
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
+	optionsParser := image.NewOptionParser(nil)
 	sourceProvider := filesystem.NewSourceProvider(cfg.Image.Source.FS)
 	cacheProvider := &provider.MockCacheProvider{}
 
@@ -247,9 +323,9 @@ func TestImageControllerGetImageWithFormatInQueryString(t *testing.T) {
 		return true
 	})).Return(errors.New("fail"))
 
-	imageProcessor := image.NewProcessor()
+	imageProcessor := image.NewProcessor(nil)
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
@@ -288,7 +364,7 @@ func TestImageControllerGetImageInCache(t *testing.T) {
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
+	optionsParser := image.NewOptionParser(nil)
 	sourceProvider := filesystem.NewSourceProvider(cfg.Image.Source.FS)
 	cacheProvider := &provider.MockCacheProvider{}
 
@@ -317,7 +393,7 @@ func TestImageControllerGetImageInCache(t *testing.T) {
 
 	imageProcessor := &image.MockProcessor{}
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
@@ -367,7 +443,7 @@ func TestImageControllerDeleteImageCache(t *testing.T) {
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
+	optionsParser := image.NewOptionParser(nil)
 	cacheProvider := &provider.MockCacheProvider{}
 
 	cacheProvider.On("Del", mock.MatchedBy(func(res *image.Resource) bool {
@@ -380,7 +456,7 @@ func TestImageControllerDeleteImageCache(t *testing.T) {
 
 	imageProcessor := &image.MockProcessor{}
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, nil, cacheProvider)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, nil, cacheProvider, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
@@ -420,7 +496,7 @@ func TestImageControllerDeleteImageSource(t *testing.T) {
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
+	optionsParser := image.NewOptionParser(nil)
 
 	sourceProvider := &provider.MockSourceProvider{}
 
@@ -444,7 +520,7 @@ func TestImageControllerDeleteImageSource(t *testing.T) {
 
 	imageProcessor := &image.MockProcessor{}
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
@@ -493,11 +569,11 @@ func TestImageControllerPostImageWithBadBody(t *testing.T) {
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
+	optionsParser := image.NewOptionParser(nil)
 
 	imageProcessor := &image.MockProcessor{}
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, nil, nil)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, nil, nil, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
@@ -677,7 +753,7 @@ func TestImageControllerPostImageWithFailOnSourceProviderSet(t *testing.T) {
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
+	optionsParser := image.NewOptionParser(nil)
 
 	sourceProvider := &provider.MockSourceProvider{}
 
@@ -697,7 +773,7 @@ func TestImageControllerPostImageWithFailOnSourceProviderSet(t *testing.T) {
 
 	imageProcessor := &image.MockProcessor{}
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
@@ -738,11 +814,11 @@ func TestImageControllerPostImageWithTooLargeError(t *testing.T) {
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
+	optionsParser := image.NewOptionParser(nil)
 
 	imageProcessor := &image.MockProcessor{}
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, nil, nil)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, nil, nil, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
@@ -783,7 +859,7 @@ func TestImageControllerPostImage(t *testing.T) {
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
+	optionsParser := image.NewOptionParser(nil)
 
 	sourceProvider := &provider.MockSourceProvider{}
 
@@ -811,7 +887,7 @@ func TestImageControllerPostImage(t *testing.T) {
 
 	imageProcessor := &image.MockProcessor{}
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
@@ -837,102 +913,175 @@ func TestImageControllerPostImage(t *testing.T) {
 	sourceProvider.AssertExpectations(t)
 }
 
-func BenchmarkProcessImageNoCache(b *testing.B) {
-	zerolog.SetGlobalLevel(zerolog.Disabled)
-
-	time.Sleep(100 * time.Millisecond)
+func TestImageControllerPostImageRejectedByUploadPolicy(t *testing.T) {
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
 
 	cfg := &config.Configuration{
+		Auth: &config.AuthConfiguration{
+			Secret: "foo",
+		},
 		Image: &config.ImageConfiguration{
 			Source: &config.ImageSourceConfiguration{
-				FS: &filesystem.SourceConfiguration{
-					Path: "../../../../_resources/demo",
-				},
+				MaxSize: 10 << 20,
 			},
 			Support: &config.ImageSupportConfiguration{
 				Extensions: map[string]interface{}{
-					"jpg":  true,
-					"jpeg": true,
-					"png":  true,
-					"webp": true,
+					"jpg": true,
 				},
 			},
+			Upload: &image.UploadConfiguration{
+				Formats:  []string{"jpeg"},
+				MaxWidth: 100,
+			},
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
-	sourceProvider := filesystem.NewSourceProvider(cfg.Image.Source.FS)
+	sourceProvider := &provider.MockSourceProvider{}
 	cacheProvider := &provider.MockCacheProvider{}
+	imageProcessor := &image.MockProcessor{}
 
-	cacheProvider.On("Get", mock.MatchedBy(func(res *image.Resource) bool {
-		if res.Path != "/kayaks.jpg" {
-			return false
-		}
+	controller := NewImageController(cfg, image.NewOptionParser(nil), imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
 
-		return true
-	})).Return(nil, errors.New("not exist"))
+	router := server.NewRouter()
 
-	cacheProvider.On("Set", mock.MatchedBy(func(res *image.Resource) bool {
-		if res.Path != "/kayaks.jpg" {
-			return false
-		}
+	router.AddController(controller)
 
-		if res.Name != "kayaks.jpg" {
-			return false
-		}
+	req := httptest.NewRequest(http.MethodPost, "/kayaks.jpg", bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer foo")
 
-		if res.MimeType != "image/webp" {
-			return false
-		}
+	w := httptest.NewRecorder()
 
-		return true
-	})).Return(errors.New("fail"))
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+	actuel, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, string(actuel), `"message":"Upload rejected"`)
+	assert.Contains(t, string(actuel), `"name":"max_width"`)
+
+	sourceProvider.AssertNotCalled(t, "Set", mock.Anything)
+}
+
+func TestImageControllerPostImageWithSignedUpload(t *testing.T) {
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	cfg := &config.Configuration{
+		Auth: &config.AuthConfiguration{
+			Keys: []auth.Key{
+				{Name: "catalog", Hash: auth.HashKey("foo"), Scopes: []string{auth.ScopeUpload}, Paths: []string{"/products/"}},
+			},
+		},
+		Signature: &signature.Configuration{
+			Keys:      []string{"secret"},
+			UploadTTL: time.Hour,
+		},
+		Image: &config.ImageConfiguration{
+			Source: &config.ImageSourceConfiguration{
+				MaxSize: 10 << 20,
+			},
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg": true,
+				},
+			},
+		},
+	}
+
+	sourceProvider := &provider.MockSourceProvider{}
+
+	sourceProvider.On("Set", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Path == "/products/kayaks.jpg"
+	})).Return(nil).Once()
+
+	cacheProvider := &provider.MockCacheProvider{}
 
-	imageProcessor := image.NewProcessor()
+	cacheProvider.On("Del", mock.Anything).Return(nil).Maybe()
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider)
+	controller := NewImageController(cfg, image.NewOptionParser(nil), &image.MockProcessor{}, sourceProvider, cacheProvider, nil, nil, nil, nil)
 
 	router := server.NewRouter()
 
 	router.AddController(controller)
 
-	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?w=40&h=40&q=85&fm=webp", nil)
-	req.Header.Set("Accept", "*/*")
+	sign := func(path string, token string, body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/_sign-upload"+path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+token)
 
-	for n := 0; n < b.N; n++ {
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
+
+		return w.Result()
 	}
 
-	time.Sleep(100 * time.Millisecond)
+	upload := func(url string, body []byte) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(body))
 
-	zerolog.SetGlobalLevel(zerolog.DebugLevel)
-}
+		w := httptest.NewRecorder()
 
-func BenchmarkProcessImageWithFSCache(b *testing.B) {
-	zerolog.SetGlobalLevel(zerolog.Disabled)
+		router.ServeHTTP(w, req)
 
-	time.Sleep(100 * time.Millisecond)
+		return w.Result()
+	}
 
-	dir, err := ioutil.TempDir("", "cache-provider-bench")
-	assert.NoError(b, err)
+	assert.Equal(t, http.StatusForbidden, sign("/users/", "foo", "").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, sign("/products/", "bar", "").StatusCode)
+	assert.Equal(t, http.StatusBadRequest, sign("/products/", "foo", "{").StatusCode)
 
-	defer os.RemoveAll(dir)
+	resp := sign("/products/", "foo", `{"max_size": 2048, "content_types": ["image/png"], "expires_in": 600}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	signed := struct {
+		URL          string    `json:"url"`
+		Path         string    `json:"path"`
+		MaxSize      int64     `json:"max_size"`
+		ContentTypes []string  `json:"content_types"`
+		ExpiresAt    time.Time `json:"expires_at"`
+	}{}
+
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&signed))
+	assert.Equal(t, "/products/", signed.Path)
+	assert.Equal(t, int64(2048), signed.MaxSize)
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), signed.ExpiresAt, 2*time.Second)
+
+	u, err := url.Parse(signed.URL)
+	assert.NoError(t, err)
+
+	// the image is a jpeg and its size exceeds the signed maximum size
+	assert.Equal(t, http.StatusUnsupportedMediaType, upload("/products/kayaks.jpg?"+u.RawQuery, data[:1024]).StatusCode)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, upload("/products/kayaks.jpg?"+u.RawQuery, data).StatusCode)
+	assert.Equal(t, http.StatusForbidden, upload("/users/kayaks.jpg?"+u.RawQuery, data).StatusCode)
+
+	resp = sign("/products/", "foo", `{"content_types": ["image/jpeg"]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&signed))
+
+	u, err = url.Parse(signed.URL)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusCreated, upload("/products/kayaks.jpg?"+u.RawQuery, data).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, upload("/products/kayaks.jpg", data).StatusCode)
+
+	sourceProvider.AssertExpectations(t)
+}
+
+func TestImageControllerPostImageWithEager(t *testing.T) {
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
 
 	cfg := &config.Configuration{
+		Auth: &config.AuthConfiguration{
+			Secret: "foo",
+		},
 		Image: &config.ImageConfiguration{
 			Source: &config.ImageSourceConfiguration{
-				FS: &filesystem.SourceConfiguration{
-					Path: "../../../../_resources/demo",
-				},
-			},
-			Cache: &config.ImageCacheConfiguration{
-				FS: &filesystem.CacheConfiguration{
-					Path:          dir,
-					LifeTime:      1 * time.Minute,
-					CleanInterval: 1 * time.Minute,
-				},
+				MaxSize: 10 << 20,
 			},
 			Support: &config.ImageSupportConfiguration{
 				Extensions: map[string]interface{}{
@@ -942,87 +1091,1174 @@ func BenchmarkProcessImageWithFSCache(b *testing.B) {
 					"webp": true,
 				},
 			},
+			Eager: &eager.Configuration{
+				Enable:    true,
+				Workers:   1,
+				QueueSize: 10,
+				Rules: []eager.Rule{
+					{Prefix: "/products/", Transforms: []string{"w=200"}},
+				},
+			},
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
-	sourceProvider := filesystem.NewSourceProvider(cfg.Image.Source.FS)
-	cacheProvider := filesystem.NewCacheProvider(cfg.Image.Cache.FS)
+	optionsParser := image.NewOptionParser(nil)
+
+	sourceProvider := &provider.MockSourceProvider{}
+
+	sourceProvider.On("Set", mock.AnythingOfType("*image.Resource")).Return(nil)
+
+	cacheProvider := &provider.MockCacheProvider{}
+
+	cacheProvider.On("Del", mock.AnythingOfType("*image.Resource")).Return(nil).Once()
+
+	cacheProvider.On("Set", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Path == "/products/kayaks.jpg"
+	})).Return(nil).Twice()
+
+	imageProcessor := &image.MockProcessor{}
+
+	imageProcessor.On("ProcessImage", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Options.Width == 200 || res.Options.Format == bimg.WEBP
+	})).Return(nil).Twice()
 
-	imageProcessor := image.NewProcessor()
+	eagerPool := eager.NewPool(cfg.Image.Eager, optionsParser, imageProcessor, cacheProvider)
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider)
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, eagerPool, nil, nil, nil)
 
 	router := server.NewRouter()
 
 	router.AddController(controller)
 
-	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?w=40&h=40&q=85&fm=webp", nil)
-	req.Header.Set("Accept", "*/*")
+	req := httptest.NewRequest(http.MethodPost, "/products/kayaks.jpg?eager=w%3D400%26fm%3Dwebp&eager=p%3Dfoo", bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer foo")
 
-	for n := 0; n < b.N; n++ {
-		w := httptest.NewRecorder()
+	w := httptest.NewRecorder()
 
-		router.ServeHTTP(w, req)
-	}
+	router.ServeHTTP(w, req)
 
-	time.Sleep(100 * time.Millisecond)
+	eagerPool.Close()
 
-	zerolog.SetGlobalLevel(zerolog.DebugLevel)
-}
+	resp := w.Result()
+	actuel, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
 
-func BenchmarkProcessImageWithMemoryCache(b *testing.B) {
-	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer resp.Body.Close()
 
-	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.JSONEq(t, `{
+		"file": "/products/kayaks.jpg",
+		"size": 256355,
+		"type": "image/jpeg",
+		"hash": "8138fdd61f7d8b3ac0d0f11cd2fe994fe37f8657cb93f6e8f818606294c7079e",
+		"eager": [
+			{"transform": "w=200", "url": "/products/kayaks.jpg?fm=jpeg&w=200", "status": "queued"},
+			{"transform": "w=400&fm=webp", "url": "/products/kayaks.jpg?fm=webp&w=400", "status": "queued"},
+			{"transform": "p=foo", "status": "invalid", "error": "invalid options: p: unknown preset"}
+		]
+	}`, string(actuel))
+
+	cacheProvider.AssertExpectations(t)
+	imageProcessor.AssertExpectations(t)
+}
+
+func TestImageControllerPostImageWithSignedEager(t *testing.T) {
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
 
 	cfg := &config.Configuration{
+		Auth: &config.AuthConfiguration{
+			Secret: "foo",
+		},
 		Image: &config.ImageConfiguration{
 			Source: &config.ImageSourceConfiguration{
-				FS: &filesystem.SourceConfiguration{
-					Path: "../../../../_resources/demo",
-				},
-			},
-			Cache: &config.ImageCacheConfiguration{
-				Memory: &memory.CacheConfiguration{
-					LifeTime:      1 * time.Minute,
-					CleanInterval: 1 * time.Minute,
-					MemoryLimit:   10 << 20,
-				},
+				MaxSize: 10 << 20,
 			},
 			Support: &config.ImageSupportConfiguration{
 				Extensions: map[string]interface{}{
 					"jpg":  true,
 					"jpeg": true,
-					"png":  true,
-					"webp": true,
 				},
 			},
+			Eager: &eager.Configuration{
+				Enable:    true,
+				Workers:   1,
+				QueueSize: 10,
+			},
+			Lockdown: &config.ImageLockdownConfiguration{
+				Enable: true,
+				Widths: []int{400},
+			},
+		},
+		Signature: &signature.Configuration{
+			Enable: true,
+			Keys:   []string{"secret"},
 		},
 	}
 
-	optionsParser := image.NewOptionParser()
-	sourceProvider := filesystem.NewSourceProvider(cfg.Image.Source.FS)
-	cacheProvider := memory.NewCacheProvider(cfg.Image.Cache.Memory)
+	optionsParser := image.NewOptionParser(nil)
+
+	sourceProvider := &provider.MockSourceProvider{}
 
-	imageProcessor := image.NewProcessor()
+	sourceProvider.On("Set", mock.AnythingOfType("*image.Resource")).Return(nil)
 
-	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider)
+	cacheProvider := &provider.MockCacheProvider{}
 
-	router := server.NewRouter()
+	cacheProvider.On("Del", mock.AnythingOfType("*image.Resource")).Return(nil)
+	cacheProvider.On("Set", mock.AnythingOfType("*image.Resource")).Return(nil)
 
-	router.AddController(controller)
+	imageProcessor := &image.MockProcessor{}
 
-	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?w=40&h=40&q=85&fm=webp", nil)
-	req.Header.Set("Accept", "*/*")
+	imageProcessor.On("ProcessImage", mock.AnythingOfType("*image.Resource")).Return(nil)
 
-	for n := 0; n < b.N; n++ {
-		w := httptest.NewRecorder()
+	eagerPool := eager.NewPool(cfg.Image.Eager, optionsParser, imageProcessor, cacheProvider)
 
-		router.ServeHTTP(w, req)
-	}
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, eagerPool, nil, nil, nil)
 
-	time.Sleep(100 * time.Millisecond)
+	router := server.NewRouter()
 
-	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	router.AddController(controller)
+
+	req := httptest.NewRequest(http.MethodPost, "/kayaks.jpg?eager=w%3D400&eager=w%3D500", bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer foo")
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	result := struct {
+		Eager []eager.Task `json:"eager"`
+	}{}
+
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Len(t, result.Eager, 2)
+
+	// the url of the task is served with the signature required
+	u, err := url.Parse(result.Eager[0].URL)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "400", u.Query().Get("w"))
+	assert.NoError(t, signature.NewSigner(cfg.Signature).Verify(u.Path, u.Query()))
+
+	// the url refused by the lockdown is not signed
+	u, err = url.Parse(result.Eager[1].URL)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "500", u.Query().Get("w"))
+	assert.Empty(t, u.Query().Get(signature.ParamSignature))
+
+	// the eager fields are not signed by the policy of a signed upload url
+	query := signature.SignUpload("secret", signature.UploadPolicy{
+		Path:    "/kayaks.jpg",
+		Expires: time.Now().Add(time.Hour),
+		Mount:   mount.DefaultName,
+	})
+
+	req = httptest.NewRequest(http.MethodPost, "/kayaks.jpg?"+query.Encode()+"&eager=w%3D400", bytes.NewReader(data))
+
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	eagerPool.Close()
+
+	resp = w.Result()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	result.Eager = nil
+
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Empty(t, result.Eager)
+}
+
+func TestImageControllerGetImageFromDerivative(t *testing.T) {
+	cfg := &config.Configuration{
+		Image: &config.ImageConfiguration{
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+			Derivative: &config.ImageDerivativeConfiguration{
+				Enable:        true,
+				MinQuality:    85,
+				MaxGeneration: 1,
+			},
+		},
+	}
+
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	optionsParser := image.NewOptionParser(nil)
+	sourceProvider := &provider.MockSourceProvider{}
+	cacheProvider := &provider.MockCacheProvider{}
+
+	cacheProvider.On("Get", mock.Anything).Return(nil, errors.New("not exist"))
+
+	cacheProvider.On("FindDerivative", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Path == "/kayaks.jpg"
+	}), &image.DerivativeCriteria{
+		Width:         80,
+		Height:        80,
+		MinQuality:    85,
+		MaxGeneration: 1,
+	}).Return(&image.Resource{
+		Path:       "/kayaks.jpg",
+		Name:       "kayaks.jpg",
+		Body:       data,
+		Size:       len(data),
+		ModifiedAt: time.Now(),
+		Derivative: &image.Derivative{
+			Width:   800,
+			Height:  600,
+			Quality: 90,
+		},
+	}, nil)
+
+	cacheProvider.On("Set", mock.Anything).Return(nil).Maybe()
+
+	imageProcessor := &image.MockProcessor{}
+
+	imageProcessor.On("ProcessImage", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Derivative != nil && res.Derivative.Width == 800
+	})).Return(nil)
+
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?w=40&h=40&dpr=2&fm=webp", nil)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "derivative", resp.Header.Get("X-Image-From"))
+
+	sourceProvider.AssertNotCalled(t, "Get", mock.Anything)
+}
+
+func TestImageControllerGetImageFromStream(t *testing.T) {
+	cfg := &config.Configuration{
+		Image: &config.ImageConfiguration{
+			Source: &config.ImageSourceConfiguration{
+				StreamMinSize: 1,
+			},
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+		},
+	}
+
+	optionsParser := image.NewOptionParser(nil)
+	sourceProvider := filesystem.NewSourceProvider(&filesystem.SourceConfiguration{
+		Path: "../../../../_resources/demo",
+	})
+	cacheProvider := &provider.MockCacheProvider{}
+
+	cacheProvider.On("Get", mock.Anything).Return(nil, errors.New("not exist"))
+	cacheProvider.On("Set", mock.Anything).Return(nil).Maybe()
+
+	imageProcessor := &image.MockProcessor{}
+
+	imageProcessor.On("ProcessStream", mock.AnythingOfType("*os.File"), mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Path == "/kayaks.jpg" && res.Body == nil && res.Size > 0
+	})).Return(nil)
+
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?w=40&h=40", nil)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "source", resp.Header.Get("X-Image-From"))
+
+	imageProcessor.AssertNotCalled(t, "ProcessImage", mock.Anything)
+
+	// options which cannot be thumbnailed load the source in memory
+	imageProcessor.On("ProcessImage", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Path == "/kayaks.jpg" && len(res.Body) == res.Size
+	})).Return(nil)
+
+	req = httptest.NewRequest(http.MethodGet, "/kayaks.jpg?w=40&h=40&blur=2", nil)
+
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	imageProcessor.AssertNumberOfCalls(t, "ProcessStream", 1)
+	imageProcessor.AssertNumberOfCalls(t, "ProcessImage", 1)
+}
+
+func TestImageControllerGetImageWithLimitError(t *testing.T) {
+	cfg := &config.Configuration{
+		Image: &config.ImageConfiguration{
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+		},
+	}
+
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	optionsParser := image.NewOptionParser(nil)
+	sourceProvider := &provider.MockSourceProvider{}
+	cacheProvider := &provider.MockCacheProvider{}
+
+	cacheProvider.On("Get", mock.Anything).Return(nil, errors.New("not exist"))
+
+	sourceProvider.On("Get", mock.Anything).Return(&image.Resource{
+		Path: "/kayaks.jpg",
+		Name: "kayaks.jpg",
+		Body: data,
+		Size: len(data),
+	}, nil)
+
+	imageProcessor := &image.MockProcessor{}
+
+	imageProcessor.On("ProcessImage", mock.Anything).Return(&image.LimitError{
+		Limit: image.LimitSourcePixels,
+		Value: 25000000,
+		Max:   16000000,
+	})
+
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?w=40", nil)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.JSONEq(t, `{"error":{"code":413,"message":"source_pixels 2.5e+07 exceeds the limit of 1.6e+07"}}`, string(body))
+
+	cacheProvider.AssertNotCalled(t, "Set", mock.Anything)
+}
+
+func TestImageControllerGetSrcset(t *testing.T) {
+	cfg := &config.Configuration{
+		Image: &config.ImageConfiguration{
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+		},
+	}
+
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	size, err := bimg.Size(data)
+	assert.NoError(t, err)
+
+	optionsParser := image.NewOptionParser(nil)
+	sourceProvider := &provider.MockSourceProvider{}
+	cacheProvider := &provider.MockCacheProvider{}
+	imageProcessor := &image.MockProcessor{}
+
+	sourceProvider.On("Get", mock.Anything).Return(&image.Resource{
+		Path: "/kayaks.jpg",
+		Name: "kayaks.jpg",
+		Body: data,
+		Size: len(data),
+	}, nil)
+
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?srcset=320&fm=webp&fmt=json", nil)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	height := int(math.Round(320 * float64(size.Height) / float64(size.Width)))
+
+	assert.JSONEq(t, fmt.Sprintf(`{
+		"src": "/kayaks.jpg?fm=webp&w=320",
+		"width": 320,
+		"height": %d,
+		"sources": [
+			{
+				"format": "webp",
+				"type": "image/webp",
+				"variants": [{"url": "/kayaks.jpg?fm=webp&w=320", "width": 320, "height": %d}]
+			}
+		]
+	}`, height, height), string(body))
+
+	req = httptest.NewRequest(http.MethodGet, "/kayaks.jpg?srcset=320,640&sizes=50vw", nil)
+
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp = w.Result()
+
+	body, err = io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), `<source type="image/webp" srcset="/kayaks.jpg?fm=webp&amp;w=320 320w, /kayaks.jpg?fm=webp&amp;w=640 640w" sizes="50vw">`)
+	assert.Contains(t, string(body), `<img src="/kayaks.jpg?fm=jpg&amp;w=640"`)
+
+	req = httptest.NewRequest(http.MethodGet, "/kayaks.jpg?srcset=abc", nil)
+
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	req = httptest.NewRequest(http.MethodGet, "/kayaks.jpg?srcset=320&fmt=xml", nil)
+
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	imageProcessor.AssertNotCalled(t, "ProcessImage", mock.Anything)
+	cacheProvider.AssertNotCalled(t, "Get", mock.Anything)
+}
+
+func TestImageControllerGetSrcsetFromStream(t *testing.T) {
+	cfg := &config.Configuration{
+		Image: &config.ImageConfiguration{
+			Source: &config.ImageSourceConfiguration{
+				FS: &filesystem.SourceConfiguration{
+					Path: "../../../../_resources/demo",
+				},
+			},
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+		},
+	}
+
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	size, err := bimg.Size(data)
+	assert.NoError(t, err)
+
+	optionsParser := image.NewOptionParser(nil)
+	sourceProvider := filesystem.NewSourceProvider(cfg.Image.Source.FS)
+	cacheProvider := &provider.MockCacheProvider{}
+	imageProcessor := &image.MockProcessor{}
+
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?srcset=320&fmt=json", nil)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	set := srcset.Set{}
+
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&set))
+
+	assert.Equal(t, "/kayaks.jpg?fm=jpg&w=320", set.Src)
+	assert.Equal(t, int(math.Round(320*float64(size.Height)/float64(size.Width))), set.Height)
+
+	req = httptest.NewRequest(http.MethodGet, "/not-found.jpg?srcset=320", nil)
+
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
+func TestImageControllerGetSignedSrcset(t *testing.T) {
+	cfg := &config.Configuration{
+		Image: &config.ImageConfiguration{
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+		},
+		Signature: &signature.Configuration{
+			Enable: true,
+			Keys:   []string{"secret"},
+		},
+	}
+
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	optionsParser := image.NewOptionParser(nil)
+	sourceProvider := &provider.MockSourceProvider{}
+	cacheProvider := &provider.MockCacheProvider{}
+	imageProcessor := &image.MockProcessor{}
+
+	sourceProvider.On("Get", mock.Anything).Return(&image.Resource{
+		Path: "/kayaks.jpg",
+		Name: "kayaks.jpg",
+		Body: data,
+		Size: len(data),
+	}, nil)
+
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?srcset=320&fm=webp&fmt=json", nil)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+
+	signed, err := signature.SignURL("secret", "/kayaks.jpg?srcset=320&fm=webp&fmt=json", time.Time{})
+	assert.NoError(t, err)
+
+	req = httptest.NewRequest(http.MethodGet, signed, nil)
+
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	set := srcset.Set{}
+
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&set))
+
+	u, err := url.Parse(set.Src)
+	assert.NoError(t, err)
+
+	assert.NotEmpty(t, u.Query().Get("s"))
+	assert.NoError(t, signature.NewSigner(cfg.Signature).Verify(u.Path, u.Query()))
+}
+
+func TestImageControllerGetSrcsetWithLockdown(t *testing.T) {
+	cfg := &config.Configuration{
+		Image: &config.ImageConfiguration{
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+			Lockdown: &config.ImageLockdownConfiguration{
+				Enable:      true,
+				AllowSigned: true,
+				Widths:      []int{320},
+				Qualities:   []int{80},
+			},
+		},
+		Signature: &signature.Configuration{
+			Keys: []string{"secret"},
+		},
+	}
+
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	optionsParser := image.NewOptionParser(nil)
+	sourceProvider := &provider.MockSourceProvider{}
+	cacheProvider := &provider.MockCacheProvider{}
+	imageProcessor := &image.MockProcessor{}
+
+	sourceProvider.On("Get", mock.Anything).Return(&image.Resource{
+		Path: "/kayaks.jpg",
+		Name: "kayaks.jpg",
+		Body: data,
+		Size: len(data),
+	}, nil)
+
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	// the variants of an unsigned request are not signed
+	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?srcset=320&q=80&fm=webp&fmt=json", nil)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	set := srcset.Set{}
+
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&set))
+	assert.Equal(t, "/kayaks.jpg?fm=webp&q=80&w=320", set.Src)
+
+	// the variants refused by the lockdown are rejected
+	for _, rawurl := range []string{
+		"/kayaks.jpg?srcset=320,640&fmt=json",
+		"/kayaks.jpg?srcset=320&q=90&fmt=json",
+	} {
+		signed, err := signature.SignURL("secret", rawurl, time.Time{})
+		assert.NoError(t, err)
+
+		for _, target := range []string{rawurl, signed} {
+			req = httptest.NewRequest(http.MethodGet, target, nil)
+
+			w = httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, target)
+		}
+	}
+
+	// the variants of a signed request are signed
+	signed, err := signature.SignURL("secret", "/kayaks.jpg?srcset=320&q=80&fm=webp&fmt=json", time.Time{})
+	assert.NoError(t, err)
+
+	req = httptest.NewRequest(http.MethodGet, signed, nil)
+
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp = w.Result()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	set = srcset.Set{}
+
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&set))
+
+	u, err := url.Parse(set.Src)
+	assert.NoError(t, err)
+
+	assert.NotEmpty(t, u.Query().Get("s"))
+	assert.NoError(t, signature.NewSigner(cfg.Signature).Verify(u.Path, u.Query()))
+}
+
+func BenchmarkProcessImageNoCache(b *testing.B) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	time.Sleep(100 * time.Millisecond)
+
+	cfg := &config.Configuration{
+		Image: &config.ImageConfiguration{
+			Source: &config.ImageSourceConfiguration{
+				FS: &filesystem.SourceConfiguration{
+					Path: "../../../../_resources/demo",
+				},
+			},
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+		},
+	}
+
+	optionsParser := image.NewOptionParser(nil)
+	sourceProvider := filesystem.NewSourceProvider(cfg.Image.Source.FS)
+	cacheProvider := &provider.MockCacheProvider{}
+
+	cacheProvider.On("Get", mock.MatchedBy(func(res *image.Resource) bool {
+		if res.Path != "/kayaks.jpg" {
+			return false
+		}
+
+		return true
+	})).Return(nil, errors.New("not exist"))
+
+	cacheProvider.On("Set", mock.MatchedBy(func(res *image.Resource) bool {
+		if res.Path != "/kayaks.jpg" {
+			return false
+		}
+
+		if res.Name != "kayaks.jpg" {
+			return false
+		}
+
+		if res.MimeType != "image/webp" {
+			return false
+		}
+
+		return true
+	})).Return(errors.New("fail"))
+
+	imageProcessor := image.NewProcessor(nil)
+
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?w=40&h=40&q=85&fm=webp", nil)
+	req.Header.Set("Accept", "*/*")
+
+	for n := 0; n < b.N; n++ {
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+}
+
+func BenchmarkProcessImageWithFSCache(b *testing.B) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	time.Sleep(100 * time.Millisecond)
+
+	dir, err := ioutil.TempDir("", "cache-provider-bench")
+	assert.NoError(b, err)
+
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Configuration{
+		Image: &config.ImageConfiguration{
+			Source: &config.ImageSourceConfiguration{
+				FS: &filesystem.SourceConfiguration{
+					Path: "../../../../_resources/demo",
+				},
+			},
+			Cache: &config.ImageCacheConfiguration{
+				FS: &filesystem.CacheConfiguration{
+					Path:          dir,
+					LifeTime:      1 * time.Minute,
+					CleanInterval: 1 * time.Minute,
+				},
+			},
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+		},
+	}
+
+	optionsParser := image.NewOptionParser(nil)
+	sourceProvider := filesystem.NewSourceProvider(cfg.Image.Source.FS)
+	cacheProvider := filesystem.NewCacheProvider(cfg.Image.Cache.FS)
+
+	imageProcessor := image.NewProcessor(nil)
+
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?w=40&h=40&q=85&fm=webp", nil)
+	req.Header.Set("Accept", "*/*")
+
+	for n := 0; n < b.N; n++ {
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+}
+
+func BenchmarkProcessImageWithMemoryCache(b *testing.B) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	time.Sleep(100 * time.Millisecond)
+
+	cfg := &config.Configuration{
+		Image: &config.ImageConfiguration{
+			Source: &config.ImageSourceConfiguration{
+				FS: &filesystem.SourceConfiguration{
+					Path: "../../../../_resources/demo",
+				},
+			},
+			Cache: &config.ImageCacheConfiguration{
+				Memory: &memory.CacheConfiguration{
+					LifeTime:      1 * time.Minute,
+					CleanInterval: 1 * time.Minute,
+					MemoryLimit:   10 << 20,
+				},
+			},
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+		},
+	}
+
+	optionsParser := image.NewOptionParser(nil)
+	sourceProvider := filesystem.NewSourceProvider(cfg.Image.Source.FS)
+	cacheProvider := memory.NewCacheProvider(cfg.Image.Cache.Memory)
+
+	imageProcessor := image.NewProcessor(nil)
+
+	controller := NewImageController(cfg, optionsParser, imageProcessor, sourceProvider, cacheProvider, nil, nil, nil, nil)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/kayaks.jpg?w=40&h=40&q=85&fm=webp", nil)
+	req.Header.Set("Accept", "*/*")
+
+	for n := 0; n < b.N; n++ {
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+}
+
+func TestImageControllerWithMounts(t *testing.T) {
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	cfg := &config.Configuration{
+		Auth: &config.AuthConfiguration{
+			Secret: "foo",
+		},
+		Image: &config.ImageConfiguration{
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg":  true,
+					"jpeg": true,
+					"png":  true,
+					"webp": true,
+				},
+			},
+		},
+		Mounts: []*config.MountConfiguration{
+			{
+				Name: "brand-b",
+				Auth: &config.AuthConfiguration{
+					Keys: []auth.Key{
+						{Name: "brand-b", Hash: auth.HashKey("bar"), Scopes: []string{auth.ScopeAdmin}},
+					},
+				},
+			},
+		},
+	}
+
+	cacheProvider := memory.NewCacheProvider(&memory.CacheConfiguration{
+		LifeTime:      time.Hour,
+		CleanInterval: time.Hour,
+		MemoryLimit:   10 << 20,
+	})
+
+	notFound := &os.PathError{Op: "open", Path: "/kayaks.jpg", Err: os.ErrNotExist}
+
+	sourceProvider := &provider.MockSourceProvider{}
+	sourceProvider.On("Get", mock.Anything).Return(nil, notFound)
+
+	brandASource := &provider.MockSourceProvider{}
+	brandASource.On("Get", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Path == "/kayaks.jpg"
+	})).Return(func(res *image.Resource) *image.Resource {
+		return &image.Resource{
+			Path:       res.Path,
+			Name:       "kayaks.jpg",
+			Options:    res.Options,
+			Body:       data,
+			Size:       len(data),
+			ModifiedAt: time.Now(),
+		}
+	}, nil)
+
+	brandBSource := &provider.MockSourceProvider{}
+	brandBSource.On("Get", mock.Anything).Return(nil, notFound)
+
+	brandAParser := image.NewOptionParser(nil)
+	brandAParser.SetDefaults(url.Values{"q": {"70"}})
+
+	table, err := mount.NewTable(
+		&mount.Mount{
+			Source:       sourceProvider,
+			Cache:        cacheProvider,
+			OptionParser: image.NewOptionParser(nil),
+		},
+		&mount.Mount{
+			Name:         "brand-a",
+			Host:         "images.brand-a.com",
+			Source:       brandASource,
+			Cache:        namespace.NewCacheProvider("brand-a", cacheProvider),
+			OptionParser: brandAParser,
+		},
+		&mount.Mount{
+			Name:         "brand-b",
+			Prefix:       "/brand-b",
+			Source:       brandBSource,
+			Cache:        namespace.NewCacheProvider("brand-b", cacheProvider),
+			OptionParser: image.NewOptionParser(nil),
+		},
+	)
+	assert.NoError(t, err)
+
+	imageProcessor := &image.MockProcessor{}
+	imageProcessor.On("ProcessImage", mock.MatchedBy(func(res *image.Resource) bool {
+		// the default options of the mount
+		return res.Options.Quality == 70
+	})).Return(nil)
+
+	controller := NewImageController(cfg, nil, imageProcessor, nil, nil, nil, nil, nil, table)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	get := func(target string) *http.Response {
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		return w.Result()
+	}
+
+	resp := get("http://images.brand-a.com/kayaks.jpg?w=40")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "source", resp.Header.Get("X-Image-From"))
+
+	assert.Eventually(t, func() bool {
+		return get("http://images.brand-a.com/kayaks.jpg?w=40").Header.Get("X-Image-From") == "cache"
+	}, time.Second, 10*time.Millisecond)
+
+	// the cache of the other mounts is isolated
+	assert.Equal(t, http.StatusNotFound, get("http://localhost/kayaks.jpg?w=40&q=70").StatusCode)
+	assert.Equal(t, http.StatusNotFound, get("http://localhost/brand-b/kayaks.jpg?w=40&q=70").StatusCode)
+
+	brandBSource.AssertCalled(t, "Get", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Path == "/kayaks.jpg"
+	}))
+
+	del := func(target string, token string) int {
+		req := httptest.NewRequest(http.MethodDelete, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		return w.Result().StatusCode
+	}
+
+	// the keys of the mount replace the global keys
+	assert.Equal(t, http.StatusUnauthorized, del("http://localhost/brand-b/kayaks.jpg?from=cache", "foo"))
+	assert.Equal(t, http.StatusOK, del("http://localhost/brand-b/kayaks.jpg?from=cache", "bar"))
+	assert.Equal(t, http.StatusOK, del("http://images.brand-a.com/kayaks.jpg?from=cache", "foo"))
+	assert.Equal(t, http.StatusUnauthorized, del("http://images.brand-a.com/kayaks.jpg?from=cache", "bar"))
+
+	assert.Equal(t, "source", get("http://images.brand-a.com/kayaks.jpg?w=40").Header.Get("X-Image-From"))
+}
+
+func TestImageControllerSignedUrlsWithMounts(t *testing.T) {
+	data, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	cfg := &config.Configuration{
+		Auth: &config.AuthConfiguration{
+			Keys: []auth.Key{
+				{Name: "catalog", Hash: auth.HashKey("foo"), Scopes: []string{auth.ScopeUpload}, Paths: []string{"/products/"}},
+			},
+		},
+		Signature: &signature.Configuration{
+			Enable: true,
+			Keys:   []string{"secret"},
+		},
+		Image: &config.ImageConfiguration{
+			Source: &config.ImageSourceConfiguration{
+				MaxSize: 10 << 20,
+			},
+			Support: &config.ImageSupportConfiguration{
+				Extensions: map[string]interface{}{
+					"jpg": true,
+				},
+			},
+		},
+	}
+
+	notFound := &os.PathError{Op: "open", Path: "/kayaks.jpg", Err: os.ErrNotExist}
+
+	sourceProvider := &provider.MockSourceProvider{}
+	sourceProvider.On("Get", mock.Anything).Return(nil, notFound)
+
+	brandASource := &provider.MockSourceProvider{}
+	brandASource.On("Get", mock.Anything).Return(nil, notFound)
+	brandASource.On("Set", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Path == "/products/kayaks.jpg"
+	})).Return(nil).Once()
+
+	cacheProvider := &provider.MockCacheProvider{}
+	cacheProvider.On("Get", mock.Anything).Return(nil, notFound).Maybe()
+	cacheProvider.On("Del", mock.Anything).Return(nil).Maybe()
+
+	table, err := mount.NewTable(
+		&mount.Mount{
+			Source:       sourceProvider,
+			Cache:        cacheProvider,
+			OptionParser: image.NewOptionParser(nil),
+		},
+		&mount.Mount{
+			Name:         "brand-a",
+			Host:         "images.brand-a.com",
+			Source:       brandASource,
+			Cache:        cacheProvider,
+			OptionParser: image.NewOptionParser(nil),
+		},
+	)
+	assert.NoError(t, err)
+
+	controller := NewImageController(cfg, nil, &image.MockProcessor{}, nil, nil, nil, nil, nil, table)
+
+	router := server.NewRouter()
+
+	router.AddController(controller)
+
+	do := func(method string, target string, token string, body []byte) *http.Response {
+		req := httptest.NewRequest(method, target, bytes.NewReader(body))
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		return w.Result()
+	}
+
+	// the image url signatures of a host mount are not valid on another mount
+	unscoped, err := signature.SignURL("secret", "/kayaks.jpg?w=40", time.Time{})
+	assert.NoError(t, err)
+
+	scoped, err := signature.SignScopedURL("secret", "brand-a", "/kayaks.jpg?w=40", time.Time{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "http://localhost"+unscoped, "", nil).StatusCode)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "http://images.brand-a.com"+unscoped, "", nil).StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "http://images.brand-a.com"+scoped, "", nil).StatusCode)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "http://localhost"+scoped, "", nil).StatusCode)
+
+	// the upload urls are signed for the mount
+	resp := do(http.MethodPost, "http://images.brand-a.com/_sign-upload/products/", "foo", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	signed := struct {
+		URL string `json:"url"`
+	}{}
+
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&signed))
+
+	u, err := url.Parse(signed.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "brand-a", u.Query().Get(signature.ParamMount))
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "http://localhost/products/kayaks.jpg?"+u.RawQuery, "", data).StatusCode)
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "http://images.brand-a.com/products/kayaks.jpg?"+u.RawQuery, "", data).StatusCode)
+
+	sourceProvider.AssertNotCalled(t, "Set", mock.Anything)
+	brandASource.AssertExpectations(t)
 }
@@ -11,8 +11,13 @@ import (
 func init() {
 	prometheus.MustRegister(CacheHit)
 	prometheus.MustRegister(CacheMiss)
+	prometheus.MustRegister(DerivativeHit)
 	prometheus.MustRegister(ImageDeliveredBytes)
 	prometheus.MustRegister(ImageReceivedBytes)
+	prometheus.MustRegister(ImageLimitExceeded)
+	prometheus.MustRegister(ImageEager)
+	prometheus.MustRegister(RateLimited)
+	prometheus.MustRegister(UploadRejected)
 }
 
 // CacheHit counter.
@@ -33,6 +38,15 @@ var CacheMiss = prometheus.NewCounterVec(
 	[]string{},
 )
 
+// DerivativeHit counter.
+var DerivativeHit = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "derivative_hit_total",
+		Help: "The count of images processed from a cached derivative.",
+	},
+	[]string{},
+)
+
 // ImageDeliveredBytes counter.
 var ImageDeliveredBytes = prometheus.NewCounterVec(
 	prometheus.CounterOpts{
@@ -50,3 +64,39 @@ var ImageReceivedBytes = prometheus.NewCounterVec(
 	},
 	[]string{},
 )
+
+// ImageLimitExceeded counter.
+var ImageLimitExceeded = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "image_limit_exceeded_total",
+		Help: "The count of requests rejected by an image limit.",
+	},
+	[]string{"limit"},
+)
+
+// ImageEager counter.
+var ImageEager = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "image_eager_total",
+		Help: "The count of eager transforms requested on upload.",
+	},
+	[]string{"status"},
+)
+
+// RateLimited counter.
+var RateLimited = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rate_limited_total",
+		Help: "The count of requests rejected by the client quotas.",
+	},
+	[]string{"budget"},
+)
+
+// UploadRejected counter.
+var UploadRejected = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "upload_rejected_total",
+		Help: "The count of uploads rejected by the upload policy.",
+	},
+	[]string{"rule"},
+)
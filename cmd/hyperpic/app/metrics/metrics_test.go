@@ -14,6 +14,11 @@ import (
 func TestMetrics(t *testing.T) {
 	assert.True(t, prometheus.Unregister(CacheHit))
 	assert.True(t, prometheus.Unregister(CacheMiss))
+	assert.True(t, prometheus.Unregister(DerivativeHit))
 	assert.True(t, prometheus.Unregister(ImageDeliveredBytes))
 	assert.True(t, prometheus.Unregister(ImageReceivedBytes))
+	assert.True(t, prometheus.Unregister(ImageLimitExceeded))
+	assert.True(t, prometheus.Unregister(ImageEager))
+	assert.True(t, prometheus.Unregister(RateLimited))
+	assert.True(t, prometheus.Unregister(UploadRejected))
 }
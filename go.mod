@@ -3,18 +3,23 @@ module github.com/hyperscale/hyperpic
 require (
 	github.com/euskadi31/go-server v0.0.0-20191009113222-686c429d32ee
 	github.com/euskadi31/go-service v1.4.0
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/go-openapi/validate v0.21.0 // indirect
 	github.com/gorilla/schema v1.2.1
 	github.com/h2non/bimg v1.1.9
 	github.com/h2non/filetype v1.1.3
 	github.com/justinas/alice v1.2.0
+	github.com/mitchellh/mapstructure v1.5.0
 	github.com/pbnjay/memory v0.0.0-20210728143218-7b4eea64cf58
 	github.com/pelletier/go-toml v1.9.5 // indirect
 	github.com/pkg/errors v0.9.1
+	github.com/pkg/sftp v1.13.6
 	github.com/prometheus/client_golang v1.18.0
 	github.com/rs/zerolog v1.32.0
 	github.com/spf13/viper v1.18.2
 	github.com/stretchr/testify v1.8.4
+	golang.org/x/crypto v0.16.0
+	golang.org/x/net v0.19.0
 )
 
 go 1.16
@@ -0,0 +1,100 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"strings"
+	"time"
+)
+
+// Actions
+const (
+	ActionUpload     = "upload"
+	ActionDelete     = "delete"
+	ActionPurge      = "purge"
+	ActionSignUpload = "sign-upload"
+	ActionQuery      = "audit"
+	ActionAdmin      = "admin"
+)
+
+// Outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeDenied  = "denied"
+	OutcomeFailure = "failure"
+)
+
+// Configuration struct
+type Configuration struct {
+	Enable bool
+	// File is the JSON lines file of the audit log
+	File string
+}
+
+// Entry of the audit log
+type Entry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	IP        string    `json:"ip"`
+	Method    string    `json:"method"`
+	Action    string    `json:"action"`
+	Path      string    `json:"path"`
+	Hash      string    `json:"hash,omitempty"`
+	Size      int       `json:"size,omitempty"`
+	Status    int       `json:"status"`
+	Outcome   string    `json:"outcome"`
+}
+
+// Outcome returns the outcome of the http status
+func Outcome(status int) string {
+	switch {
+	case status < 400:
+		return OutcomeSuccess
+	case status == 401 || status == 403:
+		return OutcomeDenied
+	default:
+		return OutcomeFailure
+	}
+}
+
+// Filter of the entries read from the audit log, the zero values match all the entries
+type Filter struct {
+	// Path is a path prefix
+	Path  string
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+// Match returns true if the entry matches the filter
+func (f Filter) Match(e *Entry) bool {
+	if f.Path != "" && !strings.HasPrefix(e.Path, f.Path) {
+		return false
+	}
+
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && e.Time.After(f.To) {
+		return false
+	}
+
+	return true
+}
+
+// Sink writes the entries of the audit log
+//
+//go:generate mockery -case=underscore -inpkg -name=Sink
+type Sink interface {
+	Write(entry *Entry) error
+}
+
+// Reader is implemented by the sinks able to read the audit log back
+type Reader interface {
+	// Query returns the most recent entries matching the filter, in chronological order
+	Query(filter *Filter) ([]*Entry, error)
+}
@@ -0,0 +1,34 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutcome(t *testing.T) {
+	assert.Equal(t, OutcomeSuccess, Outcome(http.StatusCreated))
+	assert.Equal(t, OutcomeDenied, Outcome(http.StatusUnauthorized))
+	assert.Equal(t, OutcomeDenied, Outcome(http.StatusForbidden))
+	assert.Equal(t, OutcomeFailure, Outcome(http.StatusRequestEntityTooLarge))
+	assert.Equal(t, OutcomeFailure, Outcome(http.StatusInternalServerError))
+}
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	entry := &Entry{Time: now, Path: "/products/kayaks.jpg"}
+
+	assert.True(t, Filter{}.Match(entry))
+	assert.True(t, Filter{Path: "/products/"}.Match(entry))
+	assert.False(t, Filter{Path: "/users/"}.Match(entry))
+	assert.True(t, Filter{From: now, To: now}.Match(entry))
+	assert.False(t, Filter{From: now.Add(time.Second)}.Match(entry))
+	assert.False(t, Filter{To: now.Add(-time.Second)}.Match(entry))
+}
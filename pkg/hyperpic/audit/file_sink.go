@@ -0,0 +1,155 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// maxLineSize is the maximum size of an entry read from the file
+const maxLineSize = 1 << 20
+
+// blockSize is the size of the blocks read from the end of the file
+const blockSize = 64 * 1024
+
+var _ Sink = (*FileSink)(nil)
+var _ Reader = (*FileSink)(nil)
+
+// FileSink appends the entries to a JSON lines file
+type FileSink struct {
+	mtx  sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileSink opens the file in append mode, the file and its directory are created if needed
+func NewFileSink(path string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{
+		path: path,
+		file: file,
+	}, nil
+}
+
+// Write appends the entry to the file
+func (s *FileSink) Write(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	_, err = s.file.Write(append(line, '\n'))
+
+	return err
+}
+
+// Query reads the file from the end and returns the most recent entries matching the
+// filter, the reading stops once the limit is reached
+func (s *FileSink) Query(filter *Filter) ([]*Entry, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*Entry{}
+
+	err = readLinesReverse(file, info.Size(), func(line []byte) bool {
+		entry := &Entry{}
+
+		// a partial line can be read while an entry is written
+		if err := json.Unmarshal(line, entry); err != nil {
+			return true
+		}
+
+		if !filter.Match(entry) {
+			return true
+		}
+
+		entries = append(entries, entry)
+
+		return filter.Limit <= 0 || len(entries) < filter.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// chronological order
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+// readLinesReverse calls fn with the lines of the reader from the last one, until fn returns false
+func readLinesReverse(r io.ReaderAt, size int64, fn func(line []byte) bool) error {
+	buf := []byte{}
+
+	for offset := size; offset > 0; {
+		n := int64(blockSize)
+		if n > offset {
+			n = offset
+		}
+
+		offset -= n
+
+		block := make([]byte, n, n+int64(len(buf)))
+
+		if _, err := r.ReadAt(block, offset); err != nil {
+			return err
+		}
+
+		buf = append(block, buf...)
+
+		for i := bytes.LastIndexByte(buf, '\n'); i >= 0; i = bytes.LastIndexByte(buf, '\n') {
+			if line := buf[i+1:]; len(line) > 0 && !fn(line) {
+				return nil
+			}
+
+			buf = buf[:i]
+		}
+
+		if len(buf) > maxLineSize {
+			return bufio.ErrTooLong
+		}
+	}
+
+	if len(buf) > 0 {
+		fn(buf)
+	}
+
+	return nil
+}
+
+// Close the file
+func (s *FileSink) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.file.Close()
+}
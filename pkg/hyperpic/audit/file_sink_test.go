@@ -0,0 +1,118 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log", "audit.log")
+
+	sink, err := NewFileSink(path)
+	assert.NoError(t, err)
+
+	now := time.Unix(1700000000, 0).UTC()
+
+	for i, p := range []string{"/products/a.jpg", "/users/b.jpg", "/products/c.jpg", "/products/d.jpg"} {
+		assert.NoError(t, sink.Write(&Entry{
+			Time:    now.Add(time.Duration(i) * time.Minute),
+			Actor:   "catalog",
+			Action:  ActionUpload,
+			Path:    p,
+			Status:  201,
+			Outcome: OutcomeSuccess,
+		}))
+	}
+
+	assert.NoError(t, sink.Close())
+
+	// the entries are appended to the existing file
+	sink, err = NewFileSink(path)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Write(&Entry{Time: now.Add(time.Hour), Action: ActionDelete, Path: "/products/a.jpg"}))
+
+	entries, err := sink.Query(&Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 5)
+	assert.Equal(t, "catalog", entries[0].Actor)
+	assert.True(t, now.Equal(entries[0].Time))
+
+	entries, err = sink.Query(&Filter{Path: "/products/", Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "/products/d.jpg", entries[0].Path)
+	assert.Equal(t, ActionDelete, entries[1].Action)
+
+	entries, err = sink.Query(&Filter{From: now.Add(time.Minute), To: now.Add(2 * time.Minute)})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "/users/b.jpg", entries[0].Path)
+
+	// a partial line is skipped
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0640)
+	assert.NoError(t, err)
+	_, err = file.WriteString(`{"time":`)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	entries, err = sink.Query(&Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 5)
+
+	assert.NoError(t, sink.Close())
+
+	_, err = NewFileSink(filepath.Join(path, "audit.log"))
+	assert.Error(t, err)
+}
+
+func TestFileSinkQueryFromTheEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileSink(path)
+	assert.NoError(t, err)
+
+	defer sink.Close()
+
+	now := time.Unix(1700000000, 0).UTC()
+
+	// the entries span several blocks
+	for i := 0; i < 2000; i++ {
+		assert.NoError(t, sink.Write(&Entry{
+			Time:   now.Add(time.Duration(i) * time.Second),
+			Action: ActionUpload,
+			Path:   fmt.Sprintf("/products/%d.jpg", i),
+		}))
+	}
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Greater(t, info.Size(), int64(2*blockSize))
+
+	all, err := sink.Query(&Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, all, 2000)
+
+	for i, entry := range all {
+		assert.Equal(t, fmt.Sprintf("/products/%d.jpg", i), entry.Path)
+	}
+
+	entries, err := sink.Query(&Filter{Limit: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, all[1997:], entries)
+
+	entries, err = sink.Query(&Filter{Path: "/products/1", Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "/products/1998.jpg", entries[0].Path)
+	assert.Equal(t, "/products/1999.jpg", entries[1].Path)
+}
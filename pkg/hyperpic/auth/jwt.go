@@ -0,0 +1,534 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/fsutil"
+)
+
+// Algorithms of the JWT signatures
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+)
+
+// Errors
+var (
+	ErrMalformedToken = errors.New("jwt: malformed token")
+	ErrAlgorithm      = errors.New("jwt: unsupported algorithm")
+	ErrSignature      = errors.New("jwt: invalid signature")
+	ErrExpiredToken   = errors.New("jwt: token has expired")
+	ErrNotValidYet    = errors.New("jwt: token is not valid yet")
+	ErrNoExpiry       = errors.New("jwt: missing exp claim")
+	ErrMalformedClaim = errors.New("jwt: malformed time claim")
+	ErrIssuer         = errors.New("jwt: invalid issuer")
+	ErrAudience       = errors.New("jwt: invalid audience")
+)
+
+// JWTConfiguration struct
+type JWTConfiguration struct {
+	Enable   bool
+	Keys     []JWTKey
+	JWKSFile string `mapstructure:"jwks_file"`
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+	Claims   JWTClaimsConfiguration
+}
+
+// JWTKey is a static verification key, a secret for HS256 or a PEM public key for RS256 and ES256
+type JWTKey struct {
+	ID        string `mapstructure:"kid"`
+	Algorithm string `mapstructure:"alg"`
+	Secret    string
+	PublicKey string `mapstructure:"public_key"`
+}
+
+// JWTClaimsConfiguration are the names of the claims holding the permissions of the token
+type JWTClaimsConfiguration struct {
+	Paths   string
+	MaxSize string `mapstructure:"max_size"`
+	Methods string
+}
+
+// Token is a verified JWT, a token without paths or methods claims is not allowed
+type Token struct {
+	Subject string
+	Paths   []string
+	MaxSize int64
+	Methods []string
+	Claims  map[string]interface{}
+}
+
+// Allow returns true if the token permits the method on the path
+func (t Token) Allow(method string, path string) bool {
+	allowed := false
+
+	for _, m := range t.Methods {
+		if strings.EqualFold(m, method) {
+			allowed = true
+
+			break
+		}
+	}
+
+	if !allowed {
+		return false
+	}
+
+	for _, prefix := range t.Paths {
+		if fsutil.HasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type verificationKey struct {
+	id        string
+	algorithm string
+	key       interface{}
+}
+
+// JWTVerifier verifies the signature and the claims of the tokens
+type JWTVerifier struct {
+	cfg  *JWTConfiguration
+	keys []verificationKey
+	now  func() time.Time
+}
+
+// NewJWTVerifier constructor, loads the static keys and the JWKS file
+func NewJWTVerifier(cfg *JWTConfiguration) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		cfg: cfg,
+		now: time.Now,
+	}
+
+	for _, k := range cfg.Keys {
+		key, err := parseJWTKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", k.ID, err)
+		}
+
+		v.keys = append(v.keys, key)
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKSFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+
+		v.keys = append(v.keys, keys...)
+	}
+
+	return v, nil
+}
+
+func parseJWTKey(k JWTKey) (verificationKey, error) {
+	key := verificationKey{
+		id:        k.ID,
+		algorithm: k.Algorithm,
+	}
+
+	if k.Algorithm == AlgorithmHS256 {
+		if k.Secret == "" {
+			return key, errors.New("empty secret")
+		}
+
+		key.key = []byte(k.Secret)
+
+		return key, nil
+	}
+
+	block, _ := pem.Decode([]byte(k.PublicKey))
+	if block == nil {
+		return key, errors.New("invalid PEM public key")
+	}
+
+	var (
+		pub interface{}
+		err error
+	)
+
+	switch block.Type {
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			pub = cert.PublicKey
+		}
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+
+	if err != nil {
+		return key, err
+	}
+
+	key.key = pub
+
+	return key, checkKeyAlgorithm(key)
+}
+
+func checkKeyAlgorithm(key verificationKey) error {
+	switch k := key.key.(type) {
+	case []byte:
+		if key.algorithm == AlgorithmHS256 {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if key.algorithm == AlgorithmRS256 {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if key.algorithm == AlgorithmES256 && k.Curve == elliptic.P256() {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w %q for the key", ErrAlgorithm, key.algorithm)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k jwk) verificationKey() (verificationKey, error) {
+	key := verificationKey{
+		id:        k.Kid,
+		algorithm: k.Alg,
+	}
+
+	switch k.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return key, err
+		}
+
+		if key.algorithm == "" {
+			key.algorithm = AlgorithmHS256
+		}
+
+		key.key = secret
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return key, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return key, err
+		}
+
+		if key.algorithm == "" {
+			key.algorithm = AlgorithmRS256
+		}
+
+		key.key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		if k.Crv != "P-256" {
+			return key, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return key, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return key, err
+		}
+
+		if key.algorithm == "" {
+			key.algorithm = AlgorithmES256
+		}
+
+		key.key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	default:
+		return key, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+
+	return key, checkKeyAlgorithm(key)
+}
+
+func loadJWKSFile(path string) ([]verificationKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks %s: %w", path, err)
+	}
+
+	keys := []verificationKey{}
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.verificationKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks %s key %q: %w", path, k.Kid, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// IsJWT returns true if the bearer token looks like a JWT
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify checks the signature, the time and the issuer and audience of the token
+func (v *JWTVerifier) Verify(raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	if err := v.verifySignature(header.Alg, header.Kid, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	claims := map[string]interface{}{}
+
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if err := v.verifyClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return v.token(claims), nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformedToken
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrMalformedToken
+	}
+
+	return nil
+}
+
+func (v *JWTVerifier) verifySignature(alg string, kid string, signed string, signature []byte) error {
+	switch alg {
+	case AlgorithmHS256, AlgorithmRS256, AlgorithmES256:
+	default:
+		return ErrAlgorithm
+	}
+
+	sum := sha256.Sum256([]byte(signed))
+
+	for _, key := range v.keys {
+		if key.algorithm != alg || (kid != "" && key.id != "" && key.id != kid) {
+			continue
+		}
+
+		valid := false
+
+		switch k := key.key.(type) {
+		case []byte:
+			mac := hmac.New(sha256.New, k)
+			mac.Write([]byte(signed))
+
+			valid = hmac.Equal(signature, mac.Sum(nil))
+		case *rsa.PublicKey:
+			valid = rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], signature) == nil
+		case *ecdsa.PublicKey:
+			if len(signature) == 64 {
+				r := new(big.Int).SetBytes(signature[:32])
+				s := new(big.Int).SetBytes(signature[32:])
+
+				valid = ecdsa.Verify(k, sum[:], r, s)
+			}
+		}
+
+		if valid {
+			return nil
+		}
+	}
+
+	return ErrSignature
+}
+
+// numericDate returns the time of the claim, ok is false when the claim is absent
+func numericDate(claims map[string]interface{}, name string) (t time.Time, ok bool, err error) {
+	raw, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	value, ok := raw.(float64)
+	if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+		return time.Time{}, true, ErrMalformedClaim
+	}
+
+	return time.Unix(int64(value), 0), true, nil
+}
+
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := []string{}
+
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+
+		return list
+	}
+
+	return nil
+}
+
+func (v *JWTVerifier) verifyClaims(claims map[string]interface{}) error {
+	now := v.now()
+
+	exp, ok, err := numericDate(claims, "exp")
+
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return ErrNoExpiry
+	case now.After(exp.Add(v.cfg.Leeway)):
+		return ErrExpiredToken
+	}
+
+	nbf, ok, err := numericDate(claims, "nbf")
+
+	switch {
+	case err != nil:
+		return err
+	case ok && now.Add(v.cfg.Leeway).Before(nbf):
+		return ErrNotValidYet
+	}
+
+	if v.cfg.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.cfg.Issuer {
+			return ErrIssuer
+		}
+	}
+
+	if v.cfg.Audience != "" {
+		valid := false
+
+		for _, aud := range stringList(claims["aud"]) {
+			if aud == v.cfg.Audience {
+				valid = true
+
+				break
+			}
+		}
+
+		if !valid {
+			return ErrAudience
+		}
+	}
+
+	return nil
+}
+
+func claimName(name string, def string) string {
+	if name == "" {
+		return def
+	}
+
+	return name
+}
+
+func (v *JWTVerifier) token(claims map[string]interface{}) *Token {
+	t := &Token{
+		Claims:  claims,
+		Paths:   stringList(claims[claimName(v.cfg.Claims.Paths, "paths")]),
+		Methods: stringList(claims[claimName(v.cfg.Claims.Methods, "methods")]),
+	}
+
+	t.Subject, _ = claims["sub"].(string)
+
+	if size, ok := claims[claimName(v.cfg.Claims.MaxSize, "max_size")].(float64); ok {
+		t.MaxSize = int64(size)
+	}
+
+	return t
+}
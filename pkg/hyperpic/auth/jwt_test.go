@@ -0,0 +1,259 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func signJWT(t *testing.T, alg string, kid string, key interface{}, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	assert.NoError(t, err)
+
+	payload, err := json.Marshal(claims)
+	assert.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sum := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:])
+		assert.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, sum[:])
+		assert.NoError(t, err)
+
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func publicKeyPEM(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	assert.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	verifier, err := NewJWTVerifier(&JWTConfiguration{
+		Keys: []JWTKey{
+			{ID: "hs", Algorithm: AlgorithmHS256, Secret: "secret"},
+			{ID: "rs", Algorithm: AlgorithmRS256, PublicKey: publicKeyPEM(t, &rsaKey.PublicKey)},
+			{ID: "es", Algorithm: AlgorithmES256, PublicKey: publicKeyPEM(t, &ecKey.PublicKey)},
+		},
+		Issuer:   "https://auth.your-domain.tld",
+		Audience: "hyperpic",
+		Leeway:   time.Minute,
+	})
+	assert.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+
+	verifier.now = func() time.Time {
+		return now
+	}
+
+	claims := map[string]interface{}{
+		"sub":      "catalog",
+		"iss":      "https://auth.your-domain.tld",
+		"aud":      []string{"hyperpic", "other"},
+		"exp":      now.Add(time.Hour).Unix(),
+		"paths":    []string{"/products/"},
+		"methods":  "post",
+		"max_size": 1 << 20,
+	}
+
+	for alg, key := range map[string]interface{}{
+		AlgorithmHS256: []byte("secret"),
+		AlgorithmRS256: rsaKey,
+		AlgorithmES256: ecKey,
+	} {
+		token, err := verifier.Verify(signJWT(t, alg, "", key, claims))
+		assert.NoError(t, err, alg)
+
+		assert.Equal(t, "catalog", token.Subject)
+		assert.Equal(t, []string{"/products/"}, token.Paths)
+		assert.Equal(t, []string{"post"}, token.Methods)
+		assert.Equal(t, int64(1<<20), token.MaxSize)
+	}
+
+	_, err = verifier.Verify(signJWT(t, AlgorithmHS256, "", []byte("other"), claims))
+	assert.Equal(t, ErrSignature, err)
+
+	// the kid selects the key
+	_, err = verifier.Verify(signJWT(t, AlgorithmHS256, "rs", []byte("secret"), claims))
+	assert.Equal(t, ErrSignature, err)
+
+	_, err = verifier.Verify(signJWT(t, "none", "", []byte("secret"), claims))
+	assert.Equal(t, ErrAlgorithm, err)
+
+	_, err = verifier.Verify("foo.bar")
+	assert.Equal(t, ErrMalformedToken, err)
+
+	_, err = verifier.Verify("foo.bar.baz")
+	assert.Equal(t, ErrMalformedToken, err)
+
+	for _, test := range []struct {
+		name  string
+		value interface{}
+		err   error
+	}{
+		{name: "exp", value: now.Add(-2 * time.Minute).Unix(), err: ErrExpiredToken},
+		{name: "exp", value: now.Add(-30 * time.Second).Unix(), err: nil},
+		{name: "exp", value: nil, err: ErrMalformedClaim},
+		{name: "exp", value: "tomorrow", err: ErrMalformedClaim},
+		{name: "nbf", value: now.Add(2 * time.Minute).Unix(), err: ErrNotValidYet},
+		{name: "nbf", value: "now", err: ErrMalformedClaim},
+		{name: "iss", value: "https://evil.tld", err: ErrIssuer},
+		{name: "aud", value: "other", err: ErrAudience},
+	} {
+		c := map[string]interface{}{}
+
+		for k, v := range claims {
+			c[k] = v
+		}
+
+		c[test.name] = test.value
+
+		_, err := verifier.Verify(signJWT(t, AlgorithmHS256, "", []byte("secret"), c))
+		assert.Equal(t, test.err, err, test.name)
+	}
+
+	// the exp claim is required
+	c := map[string]interface{}{}
+
+	for k, v := range claims {
+		c[k] = v
+	}
+
+	delete(c, "exp")
+
+	_, err = verifier.Verify(signJWT(t, AlgorithmHS256, "", []byte("secret"), c))
+	assert.Equal(t, ErrNoExpiry, err)
+}
+
+func TestNewJWTVerifierWithJWKSFile(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	encode := func(b []byte) string {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rs", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": "AQAB"},
+			{"kty": "EC", "kid": "es", "crv": "P-256", "x": encode(ecKey.X.Bytes()), "y": encode(ecKey.Y.Bytes())},
+			{"kty": "oct", "kid": "hs", "k": encode([]byte("secret"))},
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		},
+	})
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+
+	assert.NoError(t, os.WriteFile(path, jwks, 0600))
+
+	verifier, err := NewJWTVerifier(&JWTConfiguration{
+		JWKSFile: path,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, verifier.keys, 3)
+
+	claims := map[string]interface{}{"sub": "catalog", "exp": time.Now().Add(time.Hour).Unix()}
+
+	_, err = verifier.Verify(signJWT(t, AlgorithmRS256, "rs", rsaKey, claims))
+	assert.NoError(t, err)
+
+	_, err = verifier.Verify(signJWT(t, AlgorithmES256, "es", ecKey, claims))
+	assert.NoError(t, err)
+
+	_, err = verifier.Verify(signJWT(t, AlgorithmHS256, "hs", []byte("secret"), claims))
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"keys": [{"kty": "EC", "crv": "P-384"}]}`), 0600))
+
+	_, err = NewJWTVerifier(&JWTConfiguration{JWKSFile: path})
+	assert.Error(t, err)
+
+	_, err = NewJWTVerifier(&JWTConfiguration{JWKSFile: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+}
+
+func TestNewJWTVerifierWithBadKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	for _, key := range []JWTKey{
+		{Algorithm: AlgorithmHS256},
+		{Algorithm: AlgorithmRS256, PublicKey: "foo"},
+		{Algorithm: AlgorithmES256, PublicKey: publicKeyPEM(t, &rsaKey.PublicKey)},
+		{Algorithm: "PS256", PublicKey: publicKeyPEM(t, &rsaKey.PublicKey)},
+	} {
+		_, err := NewJWTVerifier(&JWTConfiguration{Keys: []JWTKey{key}})
+		assert.Error(t, err, key.Algorithm)
+	}
+}
+
+func TestTokenAllow(t *testing.T) {
+	token := Token{
+		Paths:   []string{"/products/"},
+		Methods: []string{"POST"},
+	}
+
+	assert.True(t, token.Allow("POST", "/products/foo.jpg"))
+	assert.False(t, token.Allow("DELETE", "/products/foo.jpg"))
+	assert.False(t, token.Allow("POST", "/foo.jpg"))
+
+	token.Paths = []string{"/products"}
+
+	assert.True(t, token.Allow("POST", "/products/foo.jpg"))
+	assert.False(t, token.Allow("POST", "/products-private/foo.jpg"))
+	// the token is denied without paths or methods
+	assert.False(t, Token{}.Allow("DELETE", "/foo.jpg"))
+	assert.False(t, Token{Methods: []string{"POST"}}.Allow("POST", "/foo.jpg"))
+	assert.False(t, Token{Paths: []string{"/"}}.Allow("POST", "/foo.jpg"))
+	assert.True(t, Token{Methods: []string{"POST"}, Paths: []string{"/"}}.Allow("POST", "/foo.jpg"))
+	assert.True(t, IsJWT("a.b.c"))
+	assert.False(t, IsJWT("foo"))
+}
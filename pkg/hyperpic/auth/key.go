@@ -0,0 +1,134 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/fsutil"
+)
+
+// Scopes of the keys
+const (
+	ScopeUpload = "upload"
+	ScopeDelete = "delete"
+	ScopePurge  = "purge"
+	ScopeAdmin  = "admin"
+)
+
+// Errors
+var (
+	ErrUnknownKey = errors.New("auth: unknown key")
+	ErrExpiredKey = errors.New("auth: key has expired")
+)
+
+// Key is an API key, only the sha256 hash of the token is stored
+type Key struct {
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Scopes    []string  `json:"scopes"`
+	Paths     []string  `json:"paths"`
+	ExpiresAt time.Time `json:"expires_at" mapstructure:"expires_at"`
+}
+
+// HashKey returns the hex encoded sha256 of the token
+func HashKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// IsExpired returns true if the key has an expiry in the past
+func (k Key) IsExpired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+// Allow returns true if the key has the scope, or the admin scope, on the path.
+// A key without path prefixes is allowed on all paths.
+func (k Key) Allow(scope string, path string) bool {
+	if !k.hasScope(scope) {
+		return false
+	}
+
+	if len(k.Paths) == 0 {
+		return true
+	}
+
+	for _, prefix := range k.Paths {
+		if fsutil.HasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (k Key) hasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadKeyFile reads the json list of keys of the file
+func LoadKeyFile(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []Key{}
+
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Keyring authenticates the tokens
+type Keyring struct {
+	keys []Key
+	now  func() time.Time
+}
+
+// NewKeyring constructor
+func NewKeyring(keys []Key) *Keyring {
+	return &Keyring{
+		keys: keys,
+		now:  time.Now,
+	}
+}
+
+// Authenticate returns the key of the token
+func (k *Keyring) Authenticate(token string) (*Key, error) {
+	hash := []byte(HashKey(token))
+
+	for i := range k.keys {
+		key := &k.keys[i]
+
+		if subtle.ConstantTimeCompare(hash, []byte(strings.ToLower(key.Hash))) == 0 {
+			continue
+		}
+
+		if key.IsExpired(k.now()) {
+			return nil, ErrExpiredKey
+		}
+
+		return key, nil
+	}
+
+	return nil, ErrUnknownKey
+}
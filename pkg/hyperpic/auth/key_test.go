@@ -0,0 +1,101 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashKey(t *testing.T) {
+	assert.Equal(t, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", HashKey("test"))
+}
+
+func TestKeyAllow(t *testing.T) {
+	key := Key{
+		Scopes: []string{ScopeUpload, ScopePurge},
+		Paths:  []string{"/products/", "/users/"},
+	}
+
+	assert.True(t, key.Allow(ScopeUpload, "/products/foo.jpg"))
+	assert.True(t, key.Allow(ScopePurge, "/users/foo.jpg"))
+	assert.False(t, key.Allow(ScopeDelete, "/products/foo.jpg"))
+	assert.False(t, key.Allow(ScopeUpload, "/foo.jpg"))
+
+	// the prefix ends on a path segment
+	key.Paths = []string{"/products"}
+
+	assert.True(t, key.Allow(ScopeUpload, "/products/foo.jpg"))
+	assert.False(t, key.Allow(ScopeUpload, "/products-private/foo.jpg"))
+
+	admin := Key{
+		Scopes: []string{ScopeAdmin},
+	}
+
+	assert.True(t, admin.Allow(ScopeDelete, "/foo.jpg"))
+	assert.True(t, admin.Allow(ScopeAdmin, "/foo.jpg"))
+	assert.False(t, Key{}.Allow(ScopeUpload, "/foo.jpg"))
+}
+
+func TestKeyringAuthenticate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	keyring := NewKeyring([]Key{
+		{Name: "catalog", Hash: HashKey("foo"), Scopes: []string{ScopeUpload}},
+		{Name: "old", Hash: HashKey("bar"), ExpiresAt: now.Add(-time.Hour)},
+		{Name: "new", Hash: "BAA5A0964D3320FBC0C6A922140453C8513EA24AB8FD0577034804A967248096", ExpiresAt: now.Add(time.Hour)},
+	})
+	keyring.now = func() time.Time {
+		return now
+	}
+
+	key, err := keyring.Authenticate("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "catalog", key.Name)
+
+	key, err = keyring.Authenticate("baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "new", key.Name)
+
+	_, err = keyring.Authenticate("bar")
+	assert.Equal(t, ErrExpiredKey, err)
+
+	_, err = keyring.Authenticate("qux")
+	assert.Equal(t, ErrUnknownKey, err)
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "keys.json")
+
+	assert.NoError(t, os.WriteFile(path, []byte(`[
+		{"name": "catalog", "hash": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", "scopes": ["upload"], "paths": ["/products/"], "expires_at": "2025-01-01T00:00:00Z"}
+	]`), 0600))
+
+	keys, err := LoadKeyFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []Key{
+		{
+			Name:      "catalog",
+			Hash:      HashKey("test"),
+			Scopes:    []string{ScopeUpload},
+			Paths:     []string{"/products/"},
+			ExpiresAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}, keys)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{`), 0600))
+
+	_, err = LoadKeyFile(path)
+	assert.Error(t, err)
+
+	_, err = LoadKeyFile(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
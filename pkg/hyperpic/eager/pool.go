@@ -0,0 +1,196 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package eager
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/h2non/bimg"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/fsutil"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+	"github.com/rs/zerolog/log"
+)
+
+// Status of a task
+const (
+	StatusQueued   = "queued"
+	StatusRejected = "rejected"
+	StatusInvalid  = "invalid"
+)
+
+// Configuration struct
+type Configuration struct {
+	Enable    bool
+	Workers   int
+	QueueSize int `mapstructure:"queue_size"`
+	Rules     []Rule
+}
+
+// Rule lists the transforms generated for the uploads under the path prefix
+type Rule struct {
+	Prefix     string
+	Transforms []string
+}
+
+// Transforms returns the transforms of the rules matching the path
+func (c Configuration) Transforms(path string) []string {
+	transforms := []string{}
+
+	for _, rule := range c.Rules {
+		if fsutil.HasPathPrefix(path, rule.Prefix) {
+			transforms = append(transforms, rule.Transforms...)
+		}
+	}
+
+	return transforms
+}
+
+// Task is the status of an eager transform
+type Task struct {
+	Transform string `json:"transform"`
+	URL       string `json:"url,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Pool generates the transforms in background and stores them in cache
+type Pool struct {
+	parser    *image.OptionParser
+	processor image.Processor
+	cache     provider.CacheProvider
+	queue     chan *image.Resource
+	wg        sync.WaitGroup
+}
+
+// NewPool constructor, starts the workers
+func NewPool(cfg *Configuration, parser *image.OptionParser, processor image.Processor, cache provider.CacheProvider) *Pool {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	p := &Pool{
+		parser:    parser,
+		processor: processor,
+		cache:     cache,
+		queue:     make(chan *image.Resource, cfg.QueueSize),
+	}
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+
+		go p.work()
+	}
+
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for resource := range p.queue {
+		if err := p.processor.ProcessImage(resource); err != nil {
+			log.Error().Err(err).Msgf("Eager transform of %s failed", resource.Path)
+
+			continue
+		}
+
+		if err := p.cache.Set(resource); err != nil {
+			log.Error().Err(err).Msgf("Eager cache of %s failed", resource.Path)
+		}
+	}
+}
+
+// Submit queues the transforms of the source, a transform is a query string or a
+// preset name. Transforms are rejected when the queue is full.
+func (p *Pool) Submit(source *image.Resource, transforms []string) []Task {
+	tasks := []Task{}
+	seen := map[string]bool{}
+
+	for _, transform := range transforms {
+		if seen[transform] {
+			continue
+		}
+
+		seen[transform] = true
+
+		task := Task{
+			Transform: transform,
+		}
+
+		values, options, err := p.parse(transform, source.Body)
+		if err != nil {
+			task.Status = StatusInvalid
+			task.Error = err.Error()
+
+			tasks = append(tasks, task)
+
+			continue
+		}
+
+		task.URL = source.Path + "?" + values.Encode()
+
+		resource := &image.Resource{
+			Path:       source.Path,
+			Name:       source.Name,
+			Body:       source.Body,
+			Size:       source.Size,
+			ModifiedAt: source.ModifiedAt,
+			Options:    options,
+		}
+
+		select {
+		case p.queue <- resource:
+			task.Status = StatusQueued
+		default:
+			task.Status = StatusRejected
+			task.Error = "queue is full"
+		}
+
+		tasks = append(tasks, task)
+	}
+
+	return tasks
+}
+
+// parse the transform, the source format is used when the transform has no format
+// so the url of the task hits the cache.
+func (p *Pool) parse(transform string, body []byte) (url.Values, *image.Options, error) {
+	values := url.Values{}
+
+	if strings.Contains(transform, "=") {
+		var err error
+
+		values, err = url.ParseQuery(transform)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		values.Set("p", transform)
+	}
+
+	options, err := p.parser.ParseQuery(values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if options.Format == bimg.UNKNOWN {
+		options.Format = bimg.DetermineImageType(body)
+
+		values.Set("fm", bimg.ImageTypeName(options.Format))
+	}
+
+	return values, options, nil
+}
+
+// Close waits for the queued transforms
+func (p *Pool) Close() {
+	close(p.queue)
+
+	p.wg.Wait()
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package eager
+
+import (
+	"os"
+	"testing"
+
+	"github.com/h2non/bimg"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestConfigurationTransforms(t *testing.T) {
+	cfg := Configuration{
+		Rules: []Rule{
+			{Prefix: "/products/", Transforms: []string{"thumb"}},
+			{Prefix: "/products/shoes/", Transforms: []string{"w=800&fm=webp"}},
+			{Prefix: "/users/", Transforms: []string{"w=50"}},
+		},
+	}
+
+	assert.Equal(t, []string{"thumb", "w=800&fm=webp"}, cfg.Transforms("/products/shoes/red.jpg"))
+	assert.Equal(t, []string{"thumb"}, cfg.Transforms("/products/red.jpg"))
+	assert.Equal(t, []string{}, cfg.Transforms("/red.jpg"))
+}
+
+func TestPoolSubmit(t *testing.T) {
+	body, err := os.ReadFile("../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	presets, err := image.NewPresets(&image.PresetsConfiguration{
+		Definitions: map[string]map[string]interface{}{
+			"thumb": {"w": 200, "h": 200, "fit": "crop"},
+		},
+	})
+	assert.NoError(t, err)
+
+	parser := image.NewOptionParser(nil)
+	parser.SetPresets(presets)
+
+	processor := &image.MockProcessor{}
+
+	processor.On("ProcessImage", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Options.Width == 200 && res.Options.Fit == image.FitCropCenter && res.Options.Format == bimg.JPEG
+	})).Return(nil).Once()
+
+	processor.On("ProcessImage", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Options.Width == 800 && res.Options.Format == bimg.WEBP
+	})).Return(nil).Once()
+
+	cache := &provider.MockCacheProvider{}
+
+	cache.On("Set", mock.MatchedBy(func(res *image.Resource) bool {
+		return res.Path == "/products/kayaks.jpg"
+	})).Return(nil).Twice()
+
+	pool := NewPool(&Configuration{Workers: 2, QueueSize: 10}, parser, processor, cache)
+
+	tasks := pool.Submit(&image.Resource{
+		Path: "/products/kayaks.jpg",
+		Body: body,
+	}, []string{"thumb", "w=800&fm=webp", "thumb", "p=foo"})
+
+	pool.Close()
+
+	assert.Equal(t, []Task{
+		{Transform: "thumb", URL: "/products/kayaks.jpg?fm=jpeg&p=thumb", Status: StatusQueued},
+		{Transform: "w=800&fm=webp", URL: "/products/kayaks.jpg?fm=webp&w=800", Status: StatusQueued},
+		{Transform: "p=foo", Status: StatusInvalid, Error: "invalid options: p: unknown preset"},
+	}, tasks)
+
+	processor.AssertExpectations(t)
+	cache.AssertExpectations(t)
+}
+
+func TestPoolSubmitWithFullQueue(t *testing.T) {
+	pool := &Pool{
+		parser: image.NewOptionParser(nil),
+		queue:  make(chan *image.Resource),
+	}
+
+	tasks := pool.Submit(&image.Resource{
+		Path: "/kayaks.jpg",
+	}, []string{"w=200&fm=png"})
+
+	assert.Equal(t, []Task{
+		{Transform: "w=200&fm=png", URL: "/kayaks.jpg?fm=png&w=200", Status: StatusRejected, Error: "queue is full"},
+	}, tasks)
+}
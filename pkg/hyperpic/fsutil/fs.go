@@ -23,6 +23,12 @@ func ContainsDotDot(v string) bool {
 	return false
 }
 
+// HasPathPrefix returns true if the path is the prefix or is under the prefix,
+// "/products" matches "/products/kayaks.jpg" but not "/products-private/kayaks.jpg"
+func HasPathPrefix(path string, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
 func isSlashRune(r rune) bool {
 	return r == '/' || r == '\\'
 }
@@ -41,3 +41,13 @@ func TestContainsDotDot(t *testing.T) {
 		}
 	}
 }
+
+func TestHasPathPrefix(t *testing.T) {
+	assert.True(t, HasPathPrefix("/products/foo.jpg", "/products"))
+	assert.True(t, HasPathPrefix("/products/foo.jpg", "/products/"))
+	assert.True(t, HasPathPrefix("/products", "/products"))
+	assert.True(t, HasPathPrefix("/foo.jpg", "/"))
+	assert.False(t, HasPathPrefix("/products-private/foo.jpg", "/products"))
+	assert.False(t, HasPathPrefix("/productsfoo.jpg", "/products"))
+	assert.False(t, HasPathPrefix("/products", "/products/"))
+}
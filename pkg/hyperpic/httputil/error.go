@@ -13,7 +13,15 @@ type ErrorMessageInterface interface {
 
 // ErrorMessage struct
 type ErrorMessage struct {
-	Code    int    `json:"code"`
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Fields  []ErrorField `json:"fields,omitempty"`
+}
+
+// ErrorField describes an invalid request parameter
+type ErrorField struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
 	Message string `json:"message"`
 }
 
@@ -5,10 +5,12 @@
 package httputil
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/memfs"
+	"github.com/rs/zerolog/log"
 )
 
 // ServeImage from resource
@@ -21,3 +23,14 @@ func ServeImage(w http.ResponseWriter, r *http.Request, resource *image.Resource
 		memfs.NewBuffer(&resource.Body),
 	)
 }
+
+// Failure writes the error message as ErrorResponse json
+func Failure(w http.ResponseWriter, status int, err ErrorMessage) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err}); err != nil {
+		log.Error().Err(err).Msg("json.Encode failed")
+	}
+}
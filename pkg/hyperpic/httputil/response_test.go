@@ -6,6 +6,7 @@ package httputil
 
 import (
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
@@ -33,3 +34,23 @@ func TestServeImage(t *testing.T) {
 	assert.Equal(t, "image/jpeg", resp.Header.Get("Content-Type"))
 	assert.Equal(t, "bar", string(body))
 }
+
+func TestFailure(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	Failure(w, http.StatusBadRequest, ErrorMessage{
+		Code:    http.StatusBadRequest,
+		Message: "Invalid options",
+		Fields: []ErrorField{
+			{Name: "fm", Value: "jpj", Message: "unsupported format"},
+		},
+	})
+
+	resp := w.Result()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"error":{"code":400,"message":"Invalid options","fields":[{"name":"fm","value":"jpj","message":"unsupported format"}]}}`, string(body))
+}
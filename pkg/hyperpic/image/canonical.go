@@ -0,0 +1,92 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+
+	"github.com/h2non/bimg"
+)
+
+// CacheKeyVersion is the version of the cache key scheme, it must be incremented
+// when the canonical form changes so old cache entries are not reused.
+const CacheKeyVersion = 2
+
+// Canonical returns the options resolved to the effective output parameters:
+// the dpr is applied on the size, the default quality is explicit and the fit
+// modes producing the same image are merged, so equivalent requests share a cache key.
+func (o Options) Canonical() Options {
+	width, height := o.OutputSize()
+
+	c := Options{
+		Width:       width,
+		Height:      height,
+		DPR:         1.0,
+		Fit:         FitContain,
+		Quality:     o.EffectiveQuality(),
+		Format:      o.Format,
+		Orientation: o.Orientation,
+		Brightness:  o.Brightness,
+		Contrast:    o.Contrast,
+		Gamma:       o.Gamma,
+		Sharpen:     o.Sharpen,
+		Operations:  o.Operations,
+	}
+
+	if o.Blur > 0 {
+		c.Blur = o.Blur
+	}
+
+	if o.Crop.Width > 0 && o.Crop.Height > 0 {
+		c.Crop = o.Crop
+	}
+
+	// only these fit modes change the output, the others are resized like contain
+	switch o.Fit {
+	case FitFill, FitCropCenter, FitCropFocalPoint:
+		if c.Width > 0 || c.Height > 0 || c.Crop.Width > 0 {
+			c.Fit = o.Fit
+		}
+	}
+
+	// black is the default background of libvips
+	if len(o.Background) == 3 && (o.Background[0] != 0 || o.Background[1] != 0 || o.Background[2] != 0) {
+		c.Background = o.Background
+	}
+
+	return c
+}
+
+// cacheKey returns the canonical representation of every option affecting the pixels
+func (o Options) cacheKey() string {
+	c := o.Canonical()
+
+	bg := ""
+	if len(c.Background) == 3 {
+		bg = fmt.Sprintf("%02x%02x%02x", c.Background[0], c.Background[1], c.Background[2])
+	}
+
+	return fmt.Sprintf(
+		"v%d:w=%d&h=%d&fit=%d&q=%d&fm=%s&or=%d&bg=%s&bri=%d&con=%d&gam=%g&sharp=%d&blur=%d&crop=%d,%d,%d,%d&ops=%s",
+		CacheKeyVersion,
+		c.Width,
+		c.Height,
+		c.Fit,
+		c.Quality,
+		bimg.ImageTypeName(c.Format),
+		c.Orientation,
+		bg,
+		c.Brightness,
+		c.Contrast,
+		c.Gamma,
+		c.Sharpen,
+		c.Blur,
+		c.Crop.Width,
+		c.Crop.Height,
+		c.Crop.X,
+		c.Crop.Y,
+		c.Operations.String(),
+	)
+}
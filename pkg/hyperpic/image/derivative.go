@@ -0,0 +1,133 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"math"
+
+	"github.com/h2non/bimg"
+)
+
+// Derivative describes a processed image stored in cache
+type Derivative struct {
+	Width       int  `json:"width"`
+	Height      int  `json:"height"`
+	Quality     int  `json:"quality"`
+	Generation  int  `json:"generation"`
+	Destructive bool `json:"destructive"`
+}
+
+// Pixels returns the number of pixels of the derivative
+func (d Derivative) Pixels() int {
+	return d.Width * d.Height
+}
+
+// DerivativeCriteria struct
+type DerivativeCriteria struct {
+	Width         int
+	Height        int
+	MinQuality    int
+	MaxGeneration int
+}
+
+// Match returns true if the derivative can be used to build an image of the requested size.
+// Derivatives already re-encoded MaxGeneration times are rejected to avoid accumulating
+// compression artifacts.
+func (c DerivativeCriteria) Match(d *Derivative) bool {
+	if d == nil || d.Destructive {
+		return false
+	}
+
+	if d.Generation >= c.MaxGeneration {
+		return false
+	}
+
+	if d.Quality < c.MinQuality {
+		return false
+	}
+
+	return d.Width >= c.Width && d.Height >= c.Height
+}
+
+// NewDerivative returns the derivative metadata of an image processed with options
+func NewDerivative(buf []byte, o *Options, generation int) (*Derivative, error) {
+	size, err := bimg.Size(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Derivative{
+		Width:       size.Width,
+		Height:      size.Height,
+		Quality:     o.EffectiveQuality(),
+		Generation:  generation,
+		Destructive: !o.IsFullFrame(),
+	}, nil
+}
+
+// OutputSize returns the requested output size in pixels, the dpr is applied and the size is
+// rounded to the nearest pixel. It is the only rounding used by the processing and the cache key.
+func (o Options) OutputSize() (int, int) {
+	dpr := o.DPR
+
+	if dpr == 0.0 {
+		dpr = 1.0
+	}
+
+	return int(math.Round(float64(o.Width) * dpr)), int(math.Round(float64(o.Height) * dpr))
+}
+
+// EffectiveQuality returns the encoding quality applied by libvips, lossless formats are 100
+func (o Options) EffectiveQuality() int {
+	switch o.Format {
+	case bimg.PNG, bimg.TIFF, bimg.GIF:
+		return 100
+	}
+
+	if o.Quality <= 0 {
+		return bimg.Quality
+	}
+
+	return o.Quality
+}
+
+// IsFullFrame returns true if the options only scale the whole image
+// (no crop, rotation, filter or chain), the output is then a faithful
+// smaller copy of the source.
+func (o Options) IsFullFrame() bool {
+	if len(o.Operations) > 0 {
+		return false
+	}
+
+	if o.Crop.Width > 0 && o.Crop.Height > 0 {
+		return false
+	}
+
+	if o.Fit != FitContain && o.Fit != FitMax {
+		return false
+	}
+
+	return o.Orientation == bimg.D0 &&
+		o.Brightness == 0 &&
+		o.Contrast == 0 &&
+		o.Gamma == 0 &&
+		o.Sharpen == 0 &&
+		o.Blur == 0
+}
+
+// IsDerivable returns true if the options can be applied on a full frame derivative
+// instead of the original image: the target size is known and no coordinates in
+// source pixels are used.
+func (o Options) IsDerivable() bool {
+	if o.Width <= 0 && o.Height <= 0 {
+		return false
+	}
+
+	if len(o.Operations) > 0 {
+		return false
+	}
+
+	return o.Crop.Width <= 0 || o.Crop.Height <= 0
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"os"
+	"testing"
+
+	"github.com/h2non/bimg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDerivativeCriteriaMatch(t *testing.T) {
+	c := DerivativeCriteria{
+		Width:         400,
+		Height:        300,
+		MinQuality:    85,
+		MaxGeneration: 1,
+	}
+
+	assert.True(t, c.Match(&Derivative{Width: 800, Height: 600, Quality: 90, Generation: 0}))
+	assert.True(t, c.Match(&Derivative{Width: 400, Height: 300, Quality: 85, Generation: 0}))
+	assert.False(t, c.Match(nil))
+	assert.False(t, c.Match(&Derivative{Width: 399, Height: 600, Quality: 90}))
+	assert.False(t, c.Match(&Derivative{Width: 800, Height: 600, Quality: 75}))
+	assert.False(t, c.Match(&Derivative{Width: 800, Height: 600, Quality: 90, Generation: 1}))
+	assert.False(t, c.Match(&Derivative{Width: 800, Height: 600, Quality: 90, Destructive: true}))
+}
+
+func TestOptionsIsFullFrame(t *testing.T) {
+	assert.True(t, Options{Width: 200}.IsFullFrame())
+	assert.True(t, Options{Width: 200, Fit: FitMax, Format: bimg.WEBP}.IsFullFrame())
+	assert.False(t, Options{Width: 200, Fit: FitCropCenter}.IsFullFrame())
+	assert.False(t, Options{Width: 200, Orientation: bimg.D90}.IsFullFrame())
+	assert.False(t, Options{Width: 200, Blur: 2}.IsFullFrame())
+	assert.False(t, Options{Crop: CropType{Width: 10, Height: 10}}.IsFullFrame())
+	assert.False(t, Options{Operations: Operations{{Type: OperationFlop}}}.IsFullFrame())
+}
+
+func TestOptionsEffectiveQuality(t *testing.T) {
+	assert.Equal(t, bimg.Quality, Options{}.EffectiveQuality())
+	assert.Equal(t, 60, Options{Quality: 60, Format: bimg.WEBP}.EffectiveQuality())
+	assert.Equal(t, 100, Options{Quality: 60, Format: bimg.PNG}.EffectiveQuality())
+}
+
+func TestOptionsOutputSize(t *testing.T) {
+	o := Options{Width: 333, Height: 111, DPR: 1.5}
+
+	width, height := o.OutputSize()
+	assert.Equal(t, 500, width)
+	assert.Equal(t, 167, height)
+
+	// the processing and the cache key use the same size
+	assert.Equal(t, width, o.ToBimg().Width)
+	assert.Equal(t, height, o.ToBimg().Height)
+	assert.Equal(t, width, o.Canonical().Width)
+	assert.Equal(t, height, o.Canonical().Height)
+
+	width, height = Options{Width: 400}.OutputSize()
+	assert.Equal(t, 400, width)
+	assert.Equal(t, 0, height)
+}
+
+func TestNewDerivative(t *testing.T) {
+	body, err := os.ReadFile("../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	d, err := NewDerivative(body, &Options{Width: 20, Quality: 90}, 1)
+	assert.NoError(t, err)
+
+	size, err := bimg.Size(body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, &Derivative{
+		Width:      size.Width,
+		Height:     size.Height,
+		Quality:    90,
+		Generation: 1,
+	}, d)
+
+	_, err = NewDerivative([]byte{0x01}, &Options{}, 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	stdimage "image"
+	_ "image/gif"  // gif header
+	_ "image/jpeg" // jpeg header
+	_ "image/png"  // png header
+	"io"
+	"os"
+
+	"github.com/h2non/bimg"
+)
+
+// headerSize is the number of bytes read to detect the image type
+const headerSize = 512
+
+// Header returns the size and the type of the image read from the beginning of the stream,
+// the image is not decoded. The JPEG, PNG and GIF headers are parsed while reading, the
+// other formats are read by libvips from the file or from a temporary copy of the stream.
+func Header(r io.Reader) (int, int, bimg.ImageType, error) {
+	br := bufio.NewReaderSize(r, headerSize)
+
+	header, err := br.Peek(headerSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, 0, bimg.UNKNOWN, err
+	}
+
+	imageType := bimg.DetermineImageType(header)
+
+	switch imageType {
+	case bimg.JPEG, bimg.PNG, bimg.GIF:
+		cfg, _, err := stdimage.DecodeConfig(br)
+		if err != nil {
+			return 0, 0, imageType, err
+		}
+
+		return cfg.Width, cfg.Height, imageType, nil
+	case bimg.UNKNOWN:
+		return 0, 0, imageType, fmt.Errorf("MimeType %s is not supported", detectMimeType(header))
+	}
+
+	// libvips reads the file by its name
+	file, ok := r.(*os.File)
+	if !ok {
+		tmp, err := os.CreateTemp("", "hyperpic-header-")
+		if err != nil {
+			return 0, 0, imageType, err
+		}
+
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if _, err := io.Copy(tmp, br); err != nil {
+			return 0, 0, imageType, err
+		}
+
+		file = tmp
+	}
+
+	width, height, err := headerFile(file.Name())
+
+	return width, height, imageType, err
+}
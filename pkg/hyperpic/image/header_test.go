@@ -0,0 +1,33 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/h2non/bimg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeader(t *testing.T) {
+	for _, path := range []string{"../../../_resources/demo/kayaks.jpg", "../../../_resources/hyperpic.png"} {
+		body, err := os.ReadFile(path)
+		assert.NoError(t, err)
+
+		size, err := bimg.Size(body)
+		assert.NoError(t, err)
+
+		width, height, imageType, err := Header(bytes.NewReader(body))
+		assert.NoError(t, err)
+		assert.Equal(t, size.Width, width, path)
+		assert.Equal(t, size.Height, height, path)
+		assert.Equal(t, bimg.DetermineImageType(body), imageType, path)
+	}
+
+	_, _, _, err := Header(bytes.NewReader([]byte{0x01}))
+	assert.Error(t, err)
+}
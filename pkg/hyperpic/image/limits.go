@@ -0,0 +1,171 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/h2non/bimg"
+)
+
+// Limits
+const (
+	LimitSourcePixels = "source_pixels"
+	LimitOutputPixels = "output_pixels"
+	LimitDPR          = "dpr"
+	LimitUpscale      = "upscale"
+)
+
+// LimitError is returned when a source or the requested options exceed a limit
+type LimitError struct {
+	Limit string
+	Value float64
+	Max   float64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %g exceeds the limit of %g", e.Limit, e.Value, e.Max)
+}
+
+// StatusCode returns the HTTP status code of the error
+func (e *LimitError) StatusCode() int {
+	if e.Limit == LimitSourcePixels {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
+
+// LimitsConfiguration struct, a zero value disables the limit
+type LimitsConfiguration struct {
+	MaxSourcePixels int     `mapstructure:"max_source_pixels"`
+	MaxOutputPixels int     `mapstructure:"max_output_pixels"`
+	MaxDPR          float64 `mapstructure:"max_dpr"`
+	MaxUpscale      float64 `mapstructure:"max_upscale"`
+}
+
+// CheckOptions checks the limits which do not depend on the source
+func (c LimitsConfiguration) CheckOptions(o *Options) error {
+	if c.MaxDPR > 0 && o.DPR > c.MaxDPR {
+		return &LimitError{Limit: LimitDPR, Value: o.DPR, Max: c.MaxDPR}
+	}
+
+	if c.MaxOutputPixels <= 0 {
+		return nil
+	}
+
+	width, height := o.OutputSize()
+
+	if err := c.checkOutputPixels(width, height); err != nil {
+		return err
+	}
+
+	for _, op := range o.Operations {
+		if op.Type != OperationResize {
+			continue
+		}
+
+		if err := c.checkOutputPixels(op.Width, op.Height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c LimitsConfiguration) checkOutputPixels(width, height int) error {
+	if c.MaxOutputPixels <= 0 {
+		return nil
+	}
+
+	if pixels := width * height; pixels > c.MaxOutputPixels {
+		return &LimitError{Limit: LimitOutputPixels, Value: float64(pixels), Max: float64(c.MaxOutputPixels)}
+	}
+
+	return nil
+}
+
+// Check checks the limits for a source of width x height pixels, read from the image header.
+// The size is followed through the operations, each resize step is checked like the output.
+func (c LimitsConfiguration) Check(o *Options, width, height int) error {
+	if c.MaxSourcePixels > 0 && width*height > c.MaxSourcePixels {
+		return &LimitError{Limit: LimitSourcePixels, Value: float64(width * height), Max: float64(c.MaxSourcePixels)}
+	}
+
+	if err := c.CheckOptions(o); err != nil {
+		return err
+	}
+
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+
+	var err error
+
+	for _, op := range o.Operations {
+		switch op.Type {
+		case OperationCrop:
+			width, height = op.Width, op.Height
+		case OperationRotate:
+			if op.Angle == bimg.D90 || op.Angle == bimg.D270 {
+				width, height = height, width
+			}
+		case OperationResize:
+			// a resize step fits the image in the box
+			if width, height, err = c.checkResize(FitContain, width, height, op.Width, op.Height); err != nil {
+				return err
+			}
+		}
+	}
+
+	outWidth, outHeight := o.OutputSize()
+
+	_, _, err = c.checkResize(o.Fit, width, height, outWidth, outHeight)
+
+	return err
+}
+
+// checkResize checks the resize of a width x height image to outWidth x outHeight
+// and returns the size of the resized image.
+func (c LimitsConfiguration) checkResize(fit FitType, width, height, outWidth, outHeight int) (int, int, error) {
+	if outWidth == 0 && outHeight == 0 {
+		return width, height, nil
+	}
+
+	rx := float64(outWidth) / float64(width)
+	ry := float64(outHeight) / float64(height)
+
+	// the missing dimension keeps the aspect ratio
+	switch {
+	case outWidth == 0:
+		rx = ry
+		outWidth = int(math.Round(float64(width) * ry))
+	case outHeight == 0:
+		ry = rx
+		outHeight = int(math.Round(float64(height) * rx))
+	}
+
+	// contain fits the image in the box, the other modes cover it
+	scale := math.Max(rx, ry)
+	if fit == FitContain || fit == FitMax {
+		scale = math.Min(rx, ry)
+	}
+
+	if c.MaxUpscale > 0 && scale > c.MaxUpscale {
+		return 0, 0, &LimitError{Limit: LimitUpscale, Value: math.Round(scale*100) / 100, Max: c.MaxUpscale}
+	}
+
+	if err := c.checkOutputPixels(outWidth, outHeight); err != nil {
+		return 0, 0, err
+	}
+
+	if fit == FitContain || fit == FitMax {
+		return int(math.Round(float64(width) * scale)), int(math.Round(float64(height) * scale)), nil
+	}
+
+	return outWidth, outHeight, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/h2non/bimg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitsConfigurationCheckOptions(t *testing.T) {
+	c := LimitsConfiguration{
+		MaxOutputPixels: 1000 * 1000,
+		MaxDPR:          3,
+	}
+
+	assert.NoError(t, c.CheckOptions(&Options{Width: 1000, Height: 1000}))
+	assert.NoError(t, c.CheckOptions(&Options{Width: 50000}))
+
+	err := c.CheckOptions(&Options{Width: 100, DPR: 5})
+	assert.EqualError(t, err, "dpr 5 exceeds the limit of 3")
+	assert.Equal(t, http.StatusBadRequest, err.(*LimitError).StatusCode())
+
+	err = c.CheckOptions(&Options{Width: 600, Height: 600, DPR: 2})
+	assert.EqualError(t, err, "output_pixels 1.44e+06 exceeds the limit of 1e+06")
+
+	err = c.CheckOptions(&Options{Operations: Operations{{Type: OperationResize, Width: 5000, Height: 5000}}})
+	assert.Equal(t, LimitOutputPixels, err.(*LimitError).Limit)
+
+	assert.NoError(t, LimitsConfiguration{}.CheckOptions(&Options{Width: 50000, Height: 50000, DPR: 5}))
+}
+
+func TestLimitsConfigurationCheck(t *testing.T) {
+	c := LimitsConfiguration{
+		MaxSourcePixels: 4000 * 4000,
+		MaxOutputPixels: 2000 * 2000,
+		MaxUpscale:      2,
+	}
+
+	assert.NoError(t, c.Check(&Options{Width: 400}, 4000, 3000))
+	assert.NoError(t, c.Check(&Options{}, 1000, 1000))
+
+	err := c.Check(&Options{Width: 400}, 5000, 5000)
+	assert.EqualError(t, err, "source_pixels 2.5e+07 exceeds the limit of 1.6e+07")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, err.(*LimitError).StatusCode())
+
+	// 4000 wide keeps the 4:3 ratio, 4000x3000 is larger than the output limit
+	err = c.Check(&Options{Width: 4000}, 4000, 3000)
+	assert.Equal(t, LimitOutputPixels, err.(*LimitError).Limit)
+
+	err = c.Check(&Options{Width: 300}, 100, 100)
+	assert.EqualError(t, err, "upscale 3 exceeds the limit of 2")
+
+	// contain fits in the box, the smallest ratio is applied
+	assert.NoError(t, c.Check(&Options{Width: 1000, Height: 250}, 1000, 100))
+
+	err = c.Check(&Options{Width: 1000, Height: 250, Fit: FitCropCenter}, 1000, 100)
+	assert.EqualError(t, err, "upscale 2.5 exceeds the limit of 2")
+}
+
+func TestLimitsConfigurationCheckWithOperations(t *testing.T) {
+	c := LimitsConfiguration{
+		MaxOutputPixels: 2000 * 2000,
+		MaxUpscale:      2,
+	}
+
+	assert.NoError(t, c.Check(&Options{
+		Operations: Operations{{Type: OperationResize, Width: 400, Height: 0}},
+	}, 4000, 3000))
+
+	// the missing side of a step keeps the aspect ratio
+	err := c.Check(&Options{
+		Operations: Operations{{Type: OperationResize, Width: 0, Height: 300}},
+	}, 100, 100)
+	assert.EqualError(t, err, "upscale 3 exceeds the limit of 2")
+
+	err = c.Check(&Options{
+		Operations: Operations{{Type: OperationResize, Width: 4000, Height: 0}},
+	}, 4000, 3000)
+	assert.EqualError(t, err, "output_pixels 1.2e+07 exceeds the limit of 4e+06")
+
+	// each step is checked from the size of the previous one
+	err = c.Check(&Options{
+		Operations: Operations{
+			{Type: OperationResize, Width: 200, Height: 0},
+			{Type: OperationResize, Width: 0, Height: 600},
+		},
+	}, 400, 400)
+	assert.EqualError(t, err, "upscale 3 exceeds the limit of 2")
+
+	// the output is resized from the result of the chain
+	err = c.Check(&Options{
+		Width:      300,
+		Operations: Operations{{Type: OperationCrop, Width: 100, Height: 50}},
+	}, 1000, 1000)
+	assert.EqualError(t, err, "upscale 3 exceeds the limit of 2")
+
+	err = c.Check(&Options{
+		Height: 300,
+		Operations: Operations{
+			{Type: OperationCrop, Width: 200, Height: 100},
+			{Type: OperationRotate, Angle: bimg.D90},
+		},
+	}, 1000, 1000)
+	assert.NoError(t, err)
+}
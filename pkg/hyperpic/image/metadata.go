@@ -0,0 +1,311 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/h2non/bimg"
+)
+
+// JPEG markers
+const (
+	jpegSOI   = 0xd8
+	jpegEOI   = 0xd9
+	jpegSOS   = 0xda
+	jpegAPP0  = 0xe0
+	jpegAPP1  = 0xe1
+	jpegAPP2  = 0xe2
+	jpegAPP14 = 0xee
+	jpegAPP15 = 0xef
+	jpegCOM   = 0xfe
+)
+
+// exifOrientation is the tag of the orientation in the EXIF
+const exifOrientation = 0x0112
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// pngMetadataChunks are the text, EXIF and time chunks of a PNG
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+// stripMetadata removes the metadata of the JPEG, PNG and WebP images without re-encoding
+// them, the data appended after the image is dropped. The color profiles are kept and the
+// orientation of a JPEG is kept in a minimal EXIF. It returns false for the other formats
+// or when the image cannot be parsed.
+func stripMetadata(t bimg.ImageType, body []byte) ([]byte, bool) {
+	switch t {
+	case bimg.JPEG:
+		return stripJPEGMetadata(body)
+	case bimg.PNG:
+		return stripPNGMetadata(body)
+	case bimg.WEBP:
+		return stripWebPMetadata(body)
+	default:
+		return nil, false
+	}
+}
+
+func stripJPEGMetadata(body []byte) ([]byte, bool) {
+	if len(body) < 4 || body[0] != 0xff || body[1] != jpegSOI {
+		return nil, false
+	}
+
+	out := make([]byte, 0, len(body))
+	out = append(out, 0xff, jpegSOI)
+
+	// the minimal EXIF follows the JFIF segment
+	exifAt := len(out)
+	orientation := uint16(0)
+
+	i := 2
+
+	for {
+		if i >= len(body) || body[i] != 0xff {
+			return nil, false
+		}
+
+		// the fill bytes before the marker
+		for i < len(body) && body[i] == 0xff {
+			i++
+		}
+
+		if i >= len(body) {
+			return nil, false
+		}
+
+		marker := body[i]
+		i++
+
+		if marker == jpegEOI {
+			out = append(out, 0xff, jpegEOI)
+
+			break
+		}
+
+		// the standalone markers
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+			out = append(out, 0xff, marker)
+
+			continue
+		}
+
+		if i+2 > len(body) {
+			return nil, false
+		}
+
+		length := int(binary.BigEndian.Uint16(body[i:]))
+		if length < 2 || i+length > len(body) {
+			return nil, false
+		}
+
+		segment := body[i-2 : i+length]
+		data := body[i+2 : i+length]
+
+		i += length
+
+		switch {
+		case marker == jpegAPP1:
+			if o, ok := readExifOrientation(data); ok {
+				orientation = o
+			}
+
+			continue
+		case marker == jpegCOM, marker > jpegAPP2 && marker <= jpegAPP15 && marker != jpegAPP14:
+			continue
+		}
+
+		out = append(out, segment...)
+
+		if marker == jpegAPP0 && exifAt == 2 {
+			exifAt = len(out)
+		}
+
+		if marker != jpegSOS {
+			continue
+		}
+
+		// the entropy coded data ends at the first marker which is not a restart
+		start := i
+
+		for i+1 < len(body) && !(body[i] == 0xff && body[i+1] != 0 && (body[i+1] < 0xd0 || body[i+1] > 0xd7)) {
+			i++
+		}
+
+		if i+1 >= len(body) {
+			return nil, false
+		}
+
+		out = append(out, body[start:i]...)
+	}
+
+	if orientation > 1 {
+		exif := orientationExif(orientation)
+
+		out = append(out[:exifAt], append(exif, out[exifAt:]...)...)
+	}
+
+	return out, true
+}
+
+// readExifOrientation returns the orientation of the first IFD of an APP1 EXIF segment
+func readExifOrientation(data []byte) (uint16, bool) {
+	if !bytes.HasPrefix(data, exifHeader) {
+		return 0, false
+	}
+
+	tiff := data[len(exifHeader):]
+	if len(tiff) < 8 {
+		return 0, false
+	}
+
+	var order binary.ByteOrder
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 0, false
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0, false
+		}
+
+		if order.Uint16(tiff[entry:]) == exifOrientation {
+			return order.Uint16(tiff[entry+8:]), true
+		}
+	}
+
+	return 0, false
+}
+
+// orientationExif returns an APP1 segment with the orientation only
+func orientationExif(orientation uint16) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2a, // big endian
+		0x00, 0x00, 0x00, 0x08, // offset of the first IFD
+		0x00, 0x01, // one entry
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, // orientation, short, count 1
+		byte(orientation >> 8), byte(orientation), 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, // no next IFD
+	}
+
+	length := 2 + len(exifHeader) + len(tiff)
+
+	segment := []byte{0xff, jpegAPP1, byte(length >> 8), byte(length)}
+	segment = append(segment, exifHeader...)
+
+	return append(segment, tiff...)
+}
+
+func stripPNGMetadata(body []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(body, pngHeader) {
+		return nil, false
+	}
+
+	out := make([]byte, 0, len(body))
+	out = append(out, pngHeader...)
+
+	i := len(pngHeader)
+
+	for {
+		if i+8 > len(body) {
+			return nil, false
+		}
+
+		length := int(binary.BigEndian.Uint32(body[i:]))
+		kind := string(body[i+4 : i+8])
+
+		// length, type, data and crc
+		end := i + 12 + length
+		if length < 0 || end > len(body) {
+			return nil, false
+		}
+
+		if !pngMetadataChunks[kind] {
+			out = append(out, body[i:end]...)
+		}
+
+		i = end
+
+		if kind == "IEND" {
+			return out, true
+		}
+	}
+}
+
+func stripWebPMetadata(body []byte) ([]byte, bool) {
+	if len(body) < 12 || string(body[:4]) != "RIFF" || string(body[8:12]) != "WEBP" {
+		return nil, false
+	}
+
+	size := int(binary.LittleEndian.Uint32(body[4:]))
+	if size < 4 || 8+size > len(body) {
+		return nil, false
+	}
+
+	out := make([]byte, 12, len(body))
+	copy(out, body[:12])
+
+	for i := 12; i < 8+size; {
+		if i+8 > 8+size {
+			return nil, false
+		}
+
+		kind := string(body[i : i+4])
+		length := int(binary.LittleEndian.Uint32(body[i+4:]))
+
+		// the chunks are padded to an even size
+		end := i + 8 + length + length&1
+		if length < 0 || end > 8+size {
+			return nil, false
+		}
+
+		switch kind {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			if length < 1 {
+				return nil, false
+			}
+
+			chunk := append([]byte{}, body[i:end]...)
+
+			// clear the EXIF and XMP flags
+			chunk[8] &^= 0x0c
+
+			out = append(out, chunk...)
+		default:
+			out = append(out, body[i:end]...)
+		}
+
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+
+	return out, true
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	stdimage "image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/h2non/bimg"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestImage() *stdimage.RGBA {
+	img := stdimage.NewRGBA(stdimage.Rect(0, 0, 16, 8))
+
+	for i := range img.Pix {
+		img.Pix[i] = byte(i)
+	}
+
+	return img
+}
+
+func jpegSegment(marker byte, data []byte) []byte {
+	length := len(data) + 2
+
+	return append([]byte{0xff, marker, byte(length >> 8), byte(length)}, data...)
+}
+
+func pngChunk(kind string, data []byte) []byte {
+	chunk := make([]byte, 8, 12+len(data))
+
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], kind)
+
+	chunk = append(chunk, data...)
+	chunk = append(chunk, 0, 0, 0, 0)
+
+	binary.BigEndian.PutUint32(chunk[len(chunk)-4:], crc32.ChecksumIEEE(chunk[4:len(chunk)-4]))
+
+	return chunk
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.NoError(t, jpeg.Encode(&buf, newTestImage(), &jpeg.Options{Quality: 90}))
+
+	encoded := buf.Bytes()
+
+	// a little endian EXIF with the GPS tag before the orientation 6
+	exif := append([]byte("Exif\x00\x00"), "II\x2a\x00\x08\x00\x00\x00\x02\x00"...)
+	exif = append(exif, 0x25, 0x88, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	exif = append(exif, 0x12, 0x01, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00)
+	exif = append(exif, 0x00, 0x00, 0x00, 0x00)
+
+	body := append([]byte{}, encoded[:2]...)
+	body = append(body, jpegSegment(jpegAPP1, exif)...)
+	body = append(body, jpegSegment(jpegAPP1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))...)
+	body = append(body, jpegSegment(0xed, []byte("Photoshop 3.0\x00"))...)
+	body = append(body, jpegSegment(jpegCOM, []byte("secret"))...)
+	body = append(body, encoded[2:]...)
+	body = append(body, "<script>"...)
+
+	stripped, ok := stripMetadata(bimg.JPEG, body)
+	assert.True(t, ok)
+
+	// the image data is not re-encoded
+	assert.True(t, bytes.HasSuffix(stripped, encoded[2:]))
+	assert.NotContains(t, string(stripped), "secret")
+	assert.NotContains(t, string(stripped), "xmpmeta")
+	assert.NotContains(t, string(stripped), "Photoshop")
+	assert.NotContains(t, string(stripped), "<script>")
+
+	orientation, ok := readExifOrientation(stripped[6:])
+	assert.True(t, ok)
+	assert.Equal(t, uint16(6), orientation)
+
+	_, err := jpeg.Decode(bytes.NewReader(stripped))
+	assert.NoError(t, err)
+
+	// without orientation there is no EXIF
+	stripped, ok = stripMetadata(bimg.JPEG, append(append([]byte{}, encoded...), "<script>"...))
+	assert.True(t, ok)
+	assert.Equal(t, encoded, stripped)
+
+	_, ok = stripMetadata(bimg.JPEG, encoded[:len(encoded)/2])
+	assert.False(t, ok)
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.NoError(t, png.Encode(&buf, newTestImage()))
+
+	encoded := buf.Bytes()
+
+	// the text chunk is inserted after the header chunk
+	ihdr := len(pngHeader) + 25
+
+	body := append([]byte{}, encoded[:ihdr]...)
+	body = append(body, pngChunk("tEXt", []byte("Comment\x00secret"))...)
+	body = append(body, encoded[ihdr:]...)
+	body = append(body, "<script>"...)
+
+	stripped, ok := stripMetadata(bimg.PNG, body)
+	assert.True(t, ok)
+	assert.Equal(t, encoded, stripped)
+
+	_, ok = stripMetadata(bimg.PNG, encoded[:len(encoded)-4])
+	assert.False(t, ok)
+}
+
+func TestStripWebPMetadata(t *testing.T) {
+	chunk := func(kind string, data []byte) []byte {
+		c := append([]byte(kind), 0, 0, 0, 0)
+
+		binary.LittleEndian.PutUint32(c[4:], uint32(len(data)))
+
+		c = append(c, data...)
+
+		if len(data)%2 == 1 {
+			c = append(c, 0)
+		}
+
+		return c
+	}
+
+	riff := func(chunks ...[]byte) []byte {
+		body := []byte("RIFF\x00\x00\x00\x00WEBP")
+
+		for _, c := range chunks {
+			body = append(body, c...)
+		}
+
+		binary.LittleEndian.PutUint32(body[4:], uint32(len(body)-8))
+
+		return body
+	}
+
+	vp8x := []byte{0x0c | 0x10, 0, 0, 0, 15, 0, 0, 7, 0, 0}
+	frame := chunk("VP8 ", []byte("frame"))
+
+	body := append(riff(chunk("VP8X", vp8x), frame, chunk("EXIF", []byte("secret")), chunk("XMP ", []byte("<x:xmpmeta/>"))), "<script>"...)
+
+	stripped, ok := stripMetadata(bimg.WEBP, body)
+	assert.True(t, ok)
+	assert.Equal(t, riff(chunk("VP8X", []byte{0x10, 0, 0, 0, 15, 0, 0, 7, 0, 0}), frame), stripped)
+
+	_, ok = stripMetadata(bimg.WEBP, body[:20])
+	assert.False(t, ok)
+
+	_, ok = stripMetadata(bimg.GIF, body)
+	assert.False(t, ok)
+}
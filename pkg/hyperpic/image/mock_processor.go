@@ -2,6 +2,7 @@
 
 package image
 
+import io "io"
 import mock "github.com/stretchr/testify/mock"
 
 // MockProcessor is an autogenerated mock type for the Processor type
@@ -22,3 +23,17 @@ func (_m *MockProcessor) ProcessImage(resource *Resource) error {
 
 	return r0
 }
+
+// ProcessStream provides a mock function with given fields: r, resource
+func (_m *MockProcessor) ProcessStream(r io.Reader, resource *Resource) error {
+	ret := _m.Called(r, resource)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(io.Reader, *Resource) error); ok {
+		r0 = rf(r, resource)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
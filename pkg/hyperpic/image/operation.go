@@ -0,0 +1,234 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// OperationType type
+type OperationType int
+
+// Operation
+const (
+	OperationResize OperationType = iota + 1
+	OperationCrop
+	OperationRotate
+	OperationFlip
+	OperationFlop
+	OperationBlur
+)
+
+// Operation separators
+const (
+	operationSeparator         = "|"
+	operationArgumentSeparator = ":"
+	argumentSeparator          = ","
+)
+
+// maxOperations is the maximum number of steps allowed in a chain.
+const maxOperations = 16
+
+var operationToType = map[string]OperationType{
+	"resize": OperationResize,
+	"crop":   OperationCrop,
+	"rotate": OperationRotate,
+	"flip":   OperationFlip,
+	"flop":   OperationFlop,
+	"blur":   OperationBlur,
+}
+
+var operationTypeToName = map[OperationType]string{
+	OperationResize: "resize",
+	OperationCrop:   "crop",
+	OperationRotate: "rotate",
+	OperationFlip:   "flip",
+	OperationFlop:   "flop",
+	OperationBlur:   "blur",
+}
+
+// Operation is a single step of a transformation chain
+type Operation struct {
+	Type   OperationType
+	Width  int
+	Height int
+	X      int
+	Y      int
+	Angle  bimg.Angle
+	Sigma  int
+}
+
+// String returns the canonical form of the operation
+func (o Operation) String() string {
+	name := operationTypeToName[o.Type]
+
+	switch o.Type {
+	case OperationResize:
+		return fmt.Sprintf("%s:%d,%d", name, o.Width, o.Height)
+	case OperationCrop:
+		return fmt.Sprintf("%s:%d,%d,%d,%d", name, o.Width, o.Height, o.X, o.Y)
+	case OperationRotate:
+		return fmt.Sprintf("%s:%d", name, o.Angle)
+	case OperationBlur:
+		return fmt.Sprintf("%s:%d", name, o.Sigma)
+	default:
+		return name
+	}
+}
+
+// ToBimg creates a new bimg compatible options struct for this step only.
+// Intermediate steps are encoded in PNG with a fast compression, a lossless
+// format, the final encoding is done with the request options.
+func (o Operation) ToBimg() bimg.Options {
+	opts := bimg.Options{
+		NoProfile:     true,
+		StripMetadata: true,
+		Type:          bimg.PNG,
+		Compression:   1,
+	}
+
+	switch o.Type {
+	case OperationResize:
+		opts.Width = o.Width
+		opts.Height = o.Height
+		opts.Enlarge = true
+	case OperationCrop:
+		opts.AreaWidth = o.Width
+		opts.AreaHeight = o.Height
+		opts.Left = o.X
+		opts.Top = o.Y
+	case OperationRotate:
+		opts.Rotate = o.Angle
+	case OperationFlip:
+		opts.Flip = true
+	case OperationFlop:
+		opts.Flop = true
+	case OperationBlur:
+		opts.GaussianBlur.Sigma = 0.5 * float64(o.Sigma)
+		opts.GaussianBlur.MinAmpl = 1.0 * float64(o.Sigma)
+	}
+
+	return opts
+}
+
+// Operations is an ordered list of operations
+type Operations []Operation
+
+// String returns the canonical form of the chain, used by the cache key
+func (ops Operations) String() string {
+	parts := make([]string, 0, len(ops))
+
+	for _, op := range ops {
+		parts = append(parts, op.String())
+	}
+
+	return strings.Join(parts, operationSeparator)
+}
+
+// ParseOperations parses a chain like "crop:800,600,100,50|rotate:90|resize:400,0"
+func ParseOperations(s string) (Operations, error) {
+	steps := strings.Split(s, operationSeparator)
+
+	if len(steps) > maxOperations {
+		return nil, fmt.Errorf("ops: too many operations (%d), the maximum is %d", len(steps), maxOperations)
+	}
+
+	ops := make(Operations, 0, len(steps))
+
+	for i, step := range steps {
+		op, err := parseOperation(step)
+		if err != nil {
+			return nil, fmt.Errorf("ops: step %d: %w", i+1, err)
+		}
+
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+func parseOperation(s string) (Operation, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), operationArgumentSeparator, 2)
+
+	t, ok := operationToType[parts[0]]
+	if !ok {
+		return Operation{}, fmt.Errorf("unknown operation %q", parts[0])
+	}
+
+	var args []int
+
+	if len(parts) == 2 && parts[1] != "" {
+		for _, value := range strings.Split(parts[1], argumentSeparator) {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return Operation{}, fmt.Errorf("%s: invalid argument %q", parts[0], value)
+			}
+
+			args = append(args, n)
+		}
+	}
+
+	op := Operation{
+		Type: t,
+	}
+
+	switch t {
+	case OperationResize:
+		if len(args) != 2 {
+			return Operation{}, fmt.Errorf("resize: expected 2 arguments (width,height), got %d", len(args))
+		}
+
+		op.Width = args[0]
+		op.Height = args[1]
+
+		if op.Width < 0 || op.Height < 0 || (op.Width == 0 && op.Height == 0) {
+			return Operation{}, fmt.Errorf("resize: invalid size %dx%d", op.Width, op.Height)
+		}
+	case OperationCrop:
+		if len(args) != 4 {
+			return Operation{}, fmt.Errorf("crop: expected 4 arguments (width,height,x,y), got %d", len(args))
+		}
+
+		op.Width = args[0]
+		op.Height = args[1]
+		op.X = args[2]
+		op.Y = args[3]
+
+		if op.Width <= 0 || op.Height <= 0 || op.X < 0 || op.Y < 0 {
+			return Operation{}, fmt.Errorf("crop: invalid area %dx%d+%d+%d", op.Width, op.Height, op.X, op.Y)
+		}
+	case OperationRotate:
+		if len(args) != 1 {
+			return Operation{}, fmt.Errorf("rotate: expected 1 argument (angle), got %d", len(args))
+		}
+
+		angle, ok := orientationToType[strconv.Itoa(args[0])]
+		if !ok {
+			return Operation{}, fmt.Errorf("rotate: invalid angle %d", args[0])
+		}
+
+		op.Angle = angle
+	case OperationBlur:
+		if len(args) != 1 {
+			return Operation{}, fmt.Errorf("blur: expected 1 argument (sigma), got %d", len(args))
+		}
+
+		if args[0] < 1 || args[0] > 100 {
+			return Operation{}, fmt.Errorf("blur: invalid value %d, use values between 1 and 100", args[0])
+		}
+
+		op.Sigma = args[0]
+	default:
+		if len(args) != 0 {
+			return Operation{}, fmt.Errorf("%s: expected no argument, got %d", parts[0], len(args))
+		}
+	}
+
+	return op, nil
+}
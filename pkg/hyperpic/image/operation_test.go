@@ -0,0 +1,119 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/h2non/bimg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOperations(t *testing.T) {
+	ops, err := ParseOperations("crop:800,600,100,50|rotate:90|resize:400,0|crop:200,200,0,10|flip|flop|blur:5")
+	assert.NoError(t, err)
+
+	assert.Equal(t, Operations{
+		{Type: OperationCrop, Width: 800, Height: 600, X: 100, Y: 50},
+		{Type: OperationRotate, Angle: bimg.D90},
+		{Type: OperationResize, Width: 400, Height: 0},
+		{Type: OperationCrop, Width: 200, Height: 200, X: 0, Y: 10},
+		{Type: OperationFlip},
+		{Type: OperationFlop},
+		{Type: OperationBlur, Sigma: 5},
+	}, ops)
+
+	assert.Equal(t, "crop:800,600,100,50|rotate:90|resize:400,0|crop:200,200,0,10|flip|flop|blur:5", ops.String())
+}
+
+func TestParseOperationsWithError(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{
+			value:    "scale:2",
+			expected: `ops: step 1: unknown operation "scale"`,
+		},
+		{
+			value:    "resize:400,0|resize:0,0",
+			expected: "ops: step 2: resize: invalid size 0x0",
+		},
+		{
+			value:    "resize:400",
+			expected: "ops: step 1: resize: expected 2 arguments (width,height), got 1",
+		},
+		{
+			value:    "resize:a,10",
+			expected: `ops: step 1: resize: invalid argument "a"`,
+		},
+		{
+			value:    "crop:10,10,-1,0",
+			expected: "ops: step 1: crop: invalid area 10x10+-1+0",
+		},
+		{
+			value:    "crop:10,10",
+			expected: "ops: step 1: crop: expected 4 arguments (width,height,x,y), got 2",
+		},
+		{
+			value:    "rotate:42",
+			expected: "ops: step 1: rotate: invalid angle 42",
+		},
+		{
+			value:    "blur:200",
+			expected: "ops: step 1: blur: invalid value 200, use values between 1 and 100",
+		},
+		{
+			value:    "flip:1",
+			expected: "ops: step 1: flip: expected no argument, got 1",
+		},
+		{
+			value:    "",
+			expected: `ops: step 1: unknown operation ""`,
+		},
+		{
+			value:    strings.Repeat("flip|", 16) + "flip",
+			expected: "ops: too many operations (17), the maximum is 16",
+		},
+	}
+
+	for _, tc := range tests {
+		ops, err := ParseOperations(tc.value)
+		assert.EqualError(t, err, tc.expected)
+		assert.Nil(t, ops)
+	}
+}
+
+func TestOperationToBimg(t *testing.T) {
+	assert.Equal(t, bimg.Options{
+		Width:         400,
+		Height:        300,
+		Enlarge:       true,
+		NoProfile:     true,
+		StripMetadata: true,
+		Type:          bimg.PNG,
+		Compression:   1,
+	}, Operation{Type: OperationResize, Width: 400, Height: 300}.ToBimg())
+
+	assert.Equal(t, bimg.Options{
+		AreaWidth:     40,
+		AreaHeight:    30,
+		Left:          10,
+		Top:           20,
+		NoProfile:     true,
+		StripMetadata: true,
+		Type:          bimg.PNG,
+		Compression:   1,
+	}, Operation{Type: OperationCrop, Width: 40, Height: 30, X: 10, Y: 20}.ToBimg())
+
+	assert.Equal(t, bimg.Options{
+		Rotate:        bimg.D180,
+		NoProfile:     true,
+		StripMetadata: true,
+		Type:          bimg.PNG,
+		Compression:   1,
+	}, Operation{Type: OperationRotate, Angle: bimg.D180}.ToBimg())
+}
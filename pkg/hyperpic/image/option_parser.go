@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
@@ -19,16 +20,26 @@ import (
 
 // OptionParser struct
 type OptionParser struct {
-	decoder *schema.Decoder
+	decoder  *schema.Decoder
+	strict   bool
+	params   map[string]bool
+	presets  *Presets
+	defaults url.Values
 }
 
-// NewOptionParser func
-func NewOptionParser() *OptionParser {
+// NewOptionParser func, nil cfg uses the lenient mode
+func NewOptionParser(cfg *OptionsConfiguration) *OptionParser {
+	if cfg == nil {
+		cfg = &OptionsConfiguration{}
+	}
+
 	decoder := schema.NewDecoder()
 	decoder.IgnoreUnknownKeys(true)
 
 	parser := &OptionParser{
 		decoder: decoder,
+		strict:  cfg.Strict,
+		params:  schemaParams(),
 	}
 
 	parser.register()
@@ -159,13 +170,89 @@ func (p OptionParser) cropConverter(s string) reflect.Value {
 	})
 }
 
+// SetPresets used by the p parameter
+func (p *OptionParser) SetPresets(presets *Presets) {
+	p.presets = presets
+}
+
+// SetDefaults sets the default parameters, overridden by the preset and by the query
+func (p *OptionParser) SetDefaults(defaults url.Values) {
+	p.defaults = defaults
+}
+
 // Parse Option from url
 func (p OptionParser) Parse(r *http.Request) (*Options, error) {
+	return p.ParseQuery(r.URL.Query())
+}
+
+// ParseQuery parses options from query parameters, in strict mode every invalid or
+// unknown parameter is reported in a ValidationError instead of falling back to a
+// default value. The parameters of the preset named by p are used as default values,
+// they override the default parameters of the parser.
+func (p OptionParser) ParseQuery(query url.Values) (*Options, error) {
 	option := &Options{}
 
-	if err := p.decoder.Decode(option, r.URL.Query()); err != nil {
+	defaults := p.defaults
+
+	name := query.Get("p")
+	if name == "" {
+		name = defaults.Get("p")
+	}
+
+	if name != "" {
+		values, ok := p.presets.Get(name)
+		if !ok {
+			return nil, &ValidationError{
+				Params: []ParamError{
+					{Name: "p", Value: name, Message: "unknown preset"},
+				},
+			}
+		}
+
+		defaults = mergeValues(defaults, values)
+	}
+
+	if len(defaults) > 0 {
+		query = mergeValues(defaults, query)
+	}
+
+	err := p.decoder.Decode(option, query)
+
+	if isStrict(query, p.strict) {
+		if params := p.validate(query, err); len(params) > 0 {
+			return nil, &ValidationError{
+				Params: params,
+			}
+		}
+	}
+
+	if err != nil {
 		return nil, err
 	}
 
+	if ops := query.Get("ops"); ops != "" {
+		operations, err := ParseOperations(ops)
+		if err != nil {
+			return nil, err
+		}
+
+		option.Operations = operations
+	}
+
 	return option, nil
 }
+
+// mergeValues returns a copy of defaults overridden by values
+func mergeValues(defaults url.Values, values url.Values) url.Values {
+	merged := url.Values{}
+
+	for key, value := range defaults {
+		merged[key] = value
+	}
+
+	for key, value := range values {
+		merged[key] = value
+	}
+
+	return merged
+}
@@ -6,6 +6,7 @@ package image
 
 import (
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/h2non/bimg"
@@ -15,7 +16,7 @@ import (
 func TestOptionParserParse(t *testing.T) {
 	req := httptest.NewRequest("GET", "http://localhost:8574/stock-photo-103005233.jpg?w=400&h=400&fit=crop&dpr=2&or=45&fm=webp", nil)
 
-	parser := NewOptionParser()
+	parser := NewOptionParser(nil)
 
 	options, err := parser.Parse(req)
 	assert.NoError(t, err)
@@ -31,7 +32,7 @@ func TestOptionParserParse(t *testing.T) {
 func TestBadFormatOptionParser(t *testing.T) {
 	req := httptest.NewRequest("GET", "http://localhost:8574/stock-photo-103005233.jpg?w=400&h=400&fit=bad&dpr=2&or=45&fm=bar", nil)
 
-	parser := NewOptionParser()
+	parser := NewOptionParser(nil)
 
 	options, err := parser.Parse(req)
 	assert.NoError(t, err)
@@ -42,7 +43,7 @@ func TestBadFormatOptionParser(t *testing.T) {
 func TestBadFitOptionParser(t *testing.T) {
 	req := httptest.NewRequest("GET", "http://localhost:8574/stock-photo-103005233.jpg?w=400&h=400&fit=bad&dpr=2&or=45&fm=jpg", nil)
 
-	parser := NewOptionParser()
+	parser := NewOptionParser(nil)
 
 	options, err := parser.Parse(req)
 	assert.NoError(t, err)
@@ -53,7 +54,7 @@ func TestBadFitOptionParser(t *testing.T) {
 func TestBadOrientationOptionParser(t *testing.T) {
 	req := httptest.NewRequest("GET", "http://localhost:8574/stock-photo-103005233.jpg?w=400&h=400&fit=bad&dpr=2&or=42&fm=jpg", nil)
 
-	parser := NewOptionParser()
+	parser := NewOptionParser(nil)
 
 	options, err := parser.Parse(req)
 	assert.NoError(t, err)
@@ -103,7 +104,7 @@ func TestBackgroundColorOptionParser(t *testing.T) {
 	for _, assertion := range assertions {
 		req := httptest.NewRequest("GET", assertion.value, nil)
 
-		parser := NewOptionParser()
+		parser := NewOptionParser(nil)
 
 		options, err := parser.Parse(req)
 		assert.NoError(t, err)
@@ -167,7 +168,7 @@ func TestBadCropOptionParser(t *testing.T) {
 	for _, assertion := range assertions {
 		req := httptest.NewRequest("GET", assertion.value, nil)
 
-		parser := NewOptionParser()
+		parser := NewOptionParser(nil)
 
 		options, err := parser.Parse(req)
 		assert.NoError(t, err)
@@ -178,3 +179,113 @@ func TestBadCropOptionParser(t *testing.T) {
 		assert.Equal(t, assertion.expected.Y, options.Crop.Y)
 	}
 }
+
+func TestOperationsOptionParser(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://localhost:8574/stock-photo-103005233.jpg?w=200&ops=crop:800,600,100,50%7Crotate:90%7Cresize:400,0", nil)
+
+	parser := NewOptionParser(nil)
+
+	options, err := parser.Parse(req)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 200, options.Width)
+	assert.Equal(t, Operations{
+		{Type: OperationCrop, Width: 800, Height: 600, X: 100, Y: 50},
+		{Type: OperationRotate, Angle: bimg.D90},
+		{Type: OperationResize, Width: 400},
+	}, options.Operations)
+}
+
+func TestBadOperationsOptionParser(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://localhost:8574/stock-photo-103005233.jpg?ops=rotate:90%7Cresize:0,0", nil)
+
+	parser := NewOptionParser(nil)
+
+	options, err := parser.Parse(req)
+	assert.EqualError(t, err, "ops: step 2: resize: invalid size 0x0")
+	assert.Nil(t, options)
+}
+
+func TestStrictOptionParser(t *testing.T) {
+	parser := NewOptionParser(&OptionsConfiguration{
+		Strict: true,
+	})
+
+	req := httptest.NewRequest("GET", "http://localhost:8574/foo.jpg?w=400&h=400&fit=crop&dpr=2&or=90&fm=webp&bg=fff&crop=10,10,0,0&q=80&ops=flip&strict=1", nil)
+
+	options, err := parser.Parse(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, options.Width)
+
+	req = httptest.NewRequest("GET", "http://localhost:8574/foo.jpg?w=-1&h=abc&fit=bad&or=42&fm=bar&bg=zz&crop=1,2&q=101&dpr=0&blur=200&ops=nope&foo=bar", nil)
+
+	_, err = parser.Parse(req)
+	assert.IsType(t, &ValidationError{}, err)
+
+	params := err.(*ValidationError).Params
+
+	names := []string{}
+	for _, param := range params {
+		names = append(names, param.Name)
+	}
+
+	assert.Equal(t, []string{"bg", "blur", "crop", "dpr", "fit", "fm", "foo", "h", "ops", "or", "q", "w"}, names)
+	assert.Equal(t, ParamError{Name: "foo", Value: "bar", Message: "unknown parameter"}, params[6])
+	assert.Contains(t, err.Error(), "invalid options: bg: invalid color, blur: must be between 0 and 100")
+
+	// strict mode can be disabled per request
+	req = httptest.NewRequest("GET", "http://localhost:8574/foo.jpg?w=400&fm=bar&strict=0", nil)
+
+	options, err = parser.Parse(req)
+	assert.NoError(t, err)
+	assert.Equal(t, bimg.UNKNOWN, options.Format)
+}
+
+func TestStrictOptionParserPerRequest(t *testing.T) {
+	parser := NewOptionParser(nil)
+
+	req := httptest.NewRequest("GET", "http://localhost:8574/foo.jpg?w=400&fm=bar&strict=true", nil)
+
+	_, err := parser.Parse(req)
+	assert.EqualError(t, err, "invalid options: fm: unsupported format")
+}
+
+func TestOptionParserDefaults(t *testing.T) {
+	presets, err := NewPresets(&PresetsConfiguration{
+		Definitions: map[string]map[string]interface{}{
+			"thumb": {"w": 200, "h": 200, "fit": "crop"},
+		},
+	})
+	assert.NoError(t, err)
+
+	parser := NewOptionParser(nil)
+	parser.SetPresets(presets)
+	parser.SetDefaults(url.Values{"q": {"70"}, "fm": {"webp"}, "w": {"1024"}})
+
+	options, err := parser.ParseQuery(url.Values{"w": {"400"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 400, options.Width)
+	assert.Equal(t, 70, options.Quality)
+	assert.Equal(t, bimg.WEBP, options.Format)
+
+	// the preset overrides the defaults
+	options, err = parser.ParseQuery(url.Values{"p": {"thumb"}, "q": {"90"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, options.Width)
+	assert.Equal(t, 200, options.Height)
+	assert.Equal(t, 90, options.Quality)
+	assert.Equal(t, bimg.WEBP, options.Format)
+
+	// the default preset
+	parser.SetDefaults(url.Values{"p": {"thumb"}})
+
+	options, err = parser.ParseQuery(url.Values{})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, options.Width)
+	assert.Equal(t, FitCropCenter, options.Fit)
+
+	parser.SetDefaults(url.Values{"p": {"bad"}})
+
+	_, err = parser.ParseQuery(url.Values{})
+	assert.EqualError(t, err, "invalid options: p: unknown preset")
+}
@@ -8,6 +8,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"math"
 
 	"github.com/h2non/bimg"
 	"github.com/rs/zerolog/log"
@@ -60,6 +61,7 @@ var formatToType = map[string]bimg.ImageType{
 	"png":  bimg.PNG,
 	"webp": bimg.WEBP,
 	"tiff": bimg.TIFF,
+	"avif": bimg.AVIF,
 }
 
 var fitToType = map[string]FitType{
@@ -113,16 +115,43 @@ type Options struct {
 	Quality     int            `schema:"q"`
 	Format      bimg.ImageType `schema:"fm"`
 	Compression int            `schema:"-"`
+	Operations  Operations     `schema:"-"`
 	hash        string         `schema:"-"`
 	//pixel       int            `schema:"-"`
 }
 
-// Hash return hash of options
+// Hash return hash of options, prefixed by the cache key version
 func (o *Options) Hash() string {
 	if o.hash != "" {
 		return o.hash
 	}
 
+	sum := sha256.Sum256([]byte(o.cacheKey()))
+
+	o.hash = fmt.Sprintf("v%d-%s", CacheKeyVersion, hex.EncodeToString(sum[:]))
+
+	return o.hash
+}
+
+// LegacyHash returns the hash of the version 1 key scheme, used to migrate cache entries.
+// It is empty when the options have a crop zone: the version 1 key ignored it, the
+// entry may hold another crop. It is also empty when the size scaled by the dpr is not
+// an integer: the version 1 truncated it, the entry is one pixel smaller.
+func (o Options) LegacyHash() string {
+	if o.Crop.Width > 0 && o.Crop.Height > 0 {
+		return ""
+	}
+
+	dpr := o.DPR
+
+	if dpr == 0.0 {
+		dpr = 1.0
+	}
+
+	if width, height := float64(o.Width)*dpr, float64(o.Height)*dpr; width != math.Trunc(width) || height != math.Trunc(height) {
+		return ""
+	}
+
 	hasher := sha256.New()
 	_, _ = hasher.Write([]byte(fmt.Sprintf(
 		"w=%d&h=%d&fit=%d&q=%d&fm=%d&dpr=%f&or=%d&bg=%v&bri=%d&con=%d&gam=%f&sharp=%d&blur=%d",
@@ -140,33 +169,21 @@ func (o *Options) Hash() string {
 		o.Sharpen,
 		o.Blur,
 	)))
-	o.hash = hex.EncodeToString(hasher.Sum(nil))
 
-	return o.hash
+	if len(o.Operations) > 0 {
+		_, _ = hasher.Write([]byte("&ops=" + o.Operations.String()))
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))
 }
 
 // ToBimg creates a new bimg compatible options struct mapping the fields properly
 func (o Options) ToBimg() bimg.Options {
-	dpr := o.DPR
-
-	if dpr == 0.0 {
-		dpr = 1.0
-	}
-
-	width := float64(o.Width)
-	height := float64(o.Height)
-
-	if width > 0 {
-		width = (width * dpr)
-	}
-
-	if height > 0 {
-		height = (height * dpr)
-	}
+	width, height := o.OutputSize()
 
 	opts := bimg.Options{
-		Width:         int(width),
-		Height:        int(height),
+		Width:         width,
+		Height:        height,
 		Crop:          o.Fit == FitCropCenter,
 		Rotate:        o.Orientation,
 		NoProfile:     true,
@@ -17,10 +17,83 @@ func TestOptionsHash(t *testing.T) {
 		Height: 400,
 	}
 
-	assert.Equal(t, "619a9e108e52e84031672a4ce9e1588bda14b54a3a2bd3b95267544e59753014", o.Hash())
+	assert.Equal(t, "v2-29beed07c5d2326f536ad608a976f444af882bed8dccd3c3a52d3b61dc21206d", o.Hash())
 
 	// Test static cache
-	assert.Equal(t, "619a9e108e52e84031672a4ce9e1588bda14b54a3a2bd3b95267544e59753014", o.Hash())
+	assert.Equal(t, "v2-29beed07c5d2326f536ad608a976f444af882bed8dccd3c3a52d3b61dc21206d", o.Hash())
+}
+
+func TestOptionsLegacyHash(t *testing.T) {
+	o := &Options{
+		Width:  400,
+		Height: 400,
+	}
+
+	assert.Equal(t, "619a9e108e52e84031672a4ce9e1588bda14b54a3a2bd3b95267544e59753014", o.LegacyHash())
+
+	o.Crop = CropType{Width: 10, Height: 10}
+
+	assert.Equal(t, "", o.LegacyHash())
+
+	// the version 1 key truncated the size scaled by the dpr
+	o = &Options{
+		Width: 333,
+		DPR:   1.5,
+	}
+
+	assert.Equal(t, "", o.LegacyHash())
+
+	o.Width = 334
+
+	assert.NotEqual(t, "", o.LegacyHash())
+}
+
+func TestOptionsHashCanonical(t *testing.T) {
+	equivalents := [][]*Options{
+		{
+			{Width: 800},
+			{Width: 400, DPR: 2},
+			{Width: 800, DPR: 1, Quality: bimg.Quality},
+			{Width: 800, Fit: FitMax},
+			{Width: 800, Fit: FitStretch, Background: []uint8{0, 0, 0}},
+		},
+		{
+			{Width: 200, Height: 200, Fit: FitCropCenter, Format: bimg.PNG},
+			{Width: 100, Height: 100, DPR: 2, Fit: FitCropCenter, Format: bimg.PNG, Quality: 30},
+		},
+		{
+			{Format: bimg.WEBP, Fit: FitCropCenter},
+			{Format: bimg.WEBP},
+		},
+	}
+
+	for _, options := range equivalents {
+		for _, o := range options[1:] {
+			assert.Equal(t, options[0].Hash(), o.Hash())
+		}
+	}
+
+	different := []*Options{
+		{Width: 800},
+		{Width: 800, Quality: 60},
+		{Width: 800, Format: bimg.WEBP},
+		{Width: 800, Fit: FitCropCenter, Height: 600},
+		{Width: 800, Fit: FitFill, Height: 600},
+		{Width: 800, Background: []uint8{255, 255, 255}},
+		{Width: 800, Blur: 2},
+		{Width: 800, Orientation: bimg.D90},
+		{Width: 800, Crop: CropType{Width: 100, Height: 100}},
+		{Width: 800, Crop: CropType{Width: 100, Height: 100, X: 10}},
+		{Width: 800, Operations: Operations{{Type: OperationFlip}}},
+	}
+
+	hashes := map[string]bool{}
+
+	for _, o := range different {
+		hashes[o.Hash()] = true
+	}
+
+	assert.Len(t, hashes, len(different))
 }
 
 func TestOptionsToBimg(t *testing.T) {
@@ -106,3 +179,24 @@ func TestOptionsToBimgWithBlur(t *testing.T) {
 		},
 	}, o.ToBimg())
 }
+
+func TestOptionsHashWithOperations(t *testing.T) {
+	o := &Options{
+		Width:  400,
+		Height: 400,
+		Operations: Operations{
+			{Type: OperationRotate, Angle: bimg.D90},
+		},
+	}
+
+	other := &Options{
+		Width:  400,
+		Height: 400,
+		Operations: Operations{
+			{Type: OperationFlip},
+		},
+	}
+
+	assert.NotEqual(t, "v2-29beed07c5d2326f536ad608a976f444af882bed8dccd3c3a52d3b61dc21206d", o.Hash())
+	assert.NotEqual(t, o.Hash(), other.Hash())
+}
@@ -0,0 +1,275 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// presetExt is the extension of the preset files, the file name is the preset name
+const presetExt = ".json"
+
+var presetNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// PresetsConfiguration struct
+type PresetsConfiguration struct {
+	Path           string
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+	Definitions    map[string]map[string]interface{}
+}
+
+// Presets store, the presets are read from the configuration and from the json
+// files of the presets directory, a file overrides a preset of the same name.
+type Presets struct {
+	mtx         sync.RWMutex
+	cfg         *PresetsConfiguration
+	parser      *OptionParser
+	definitions map[string]map[string]interface{}
+	presets     map[string]url.Values
+	state       string
+}
+
+// NewPresets constructor, returns an error if a preset is invalid
+func NewPresets(cfg *PresetsConfiguration) (*Presets, error) {
+	p := &Presets{
+		cfg: cfg,
+		parser: NewOptionParser(&OptionsConfiguration{
+			Strict: true,
+		}),
+		definitions: cfg.Definitions,
+		presets:     map[string]url.Values{},
+	}
+
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Get preset parameters by name, the name is case insensitive
+func (p *Presets) Get(name string) (url.Values, bool) {
+	if p == nil {
+		return nil, false
+	}
+
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	values, ok := p.presets[strings.ToLower(name)]
+
+	return values, ok
+}
+
+// Names returns the sorted names of the presets
+func (p *Presets) Names() []string {
+	if p == nil {
+		return []string{}
+	}
+
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	names := make([]string, 0, len(p.presets))
+
+	for name := range p.presets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// SetDefinitions replaces the presets of the configuration, used when the config file changes
+func (p *Presets) SetDefinitions(definitions map[string]map[string]interface{}) error {
+	p.mtx.Lock()
+	previous := p.definitions
+	p.definitions = definitions
+	p.mtx.Unlock()
+
+	if err := p.Reload(); err != nil {
+		p.mtx.Lock()
+		p.definitions = previous
+		p.mtx.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// Reload the presets, the current presets are kept if one of them is invalid.
+// The names are lowercased, viper already lowercases the keys of the config file.
+func (p *Presets) Reload() error {
+	p.mtx.RLock()
+	definitions := p.definitions
+	p.mtx.RUnlock()
+
+	presets := map[string]url.Values{}
+
+	for name, params := range definitions {
+		values := url.Values{}
+
+		for key, value := range params {
+			values.Set(key, fmt.Sprint(value))
+		}
+
+		presets[strings.ToLower(name)] = values
+	}
+
+	files, err := p.files()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		values, err := readPresetFile(file)
+		if err != nil {
+			return err
+		}
+
+		presets[strings.ToLower(strings.TrimSuffix(filepath.Base(file), presetExt))] = values
+	}
+
+	for name, values := range presets {
+		if err := p.validate(name, values); err != nil {
+			return err
+		}
+	}
+
+	state, _ := p.fileState()
+
+	p.mtx.Lock()
+	p.presets = presets
+	p.state = state
+	p.mtx.Unlock()
+
+	log.Debug().Msgf("Presets loaded: %d", len(presets))
+
+	return nil
+}
+
+// validate the preset with the strict option parser
+func (p *Presets) validate(name string, values url.Values) error {
+	if !presetNameRegexp.MatchString(name) {
+		return fmt.Errorf("preset %s: invalid name", name)
+	}
+
+	for _, key := range []string{"p", "strict"} {
+		if _, ok := values[key]; ok {
+			return fmt.Errorf("preset %s: %s parameter is not allowed", name, key)
+		}
+	}
+
+	if _, err := p.parser.ParseQuery(values); err != nil {
+		return fmt.Errorf("preset %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (p *Presets) files() ([]string, error) {
+	if p.cfg.Path == "" {
+		return []string{}, nil
+	}
+
+	return filepath.Glob(filepath.Join(p.cfg.Path, "*"+presetExt))
+}
+
+// fileState returns a fingerprint of the presets directory
+func (p *Presets) fileState() (string, error) {
+	files, err := p.files()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(&sb, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+
+	return sb.String(), nil
+}
+
+// Run reloads the presets when the files of the presets directory change
+func (p *Presets) Run() {
+	if p.cfg.Path == "" || p.cfg.ReloadInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.cfg.ReloadInterval)
+
+	go func() {
+		for range ticker.C {
+			state, err := p.fileState()
+			if err != nil {
+				log.Error().Err(err).Msgf("Presets directory %s", p.cfg.Path)
+
+				continue
+			}
+
+			p.mtx.RLock()
+			changed := state != p.state
+			p.mtx.RUnlock()
+
+			if !changed {
+				continue
+			}
+
+			if err := p.Reload(); err != nil {
+				log.Error().Err(err).Msg("Presets reload failed, the previous presets are kept")
+
+				// do not retry until the files change again
+				p.mtx.Lock()
+				p.state = state
+				p.mtx.Unlock()
+
+				continue
+			}
+
+			log.Info().Msg("Presets reloaded")
+		}
+	}()
+}
+
+// readPresetFile reads a json object of query parameters
+func readPresetFile(path string) (url.Values, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]interface{}{}
+
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, fmt.Errorf("preset file %s: %w", path, err)
+	}
+
+	values := url.Values{}
+
+	for key, value := range params {
+		values.Set(key, fmt.Sprint(value))
+	}
+
+	return values, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/h2non/bimg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresets(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "hero.json"), []byte(`{"w": 1600, "h": 900, "fit": "crop", "fm": "webp"}`), 0666))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "thumb.json"), []byte(`{"w": 150, "h": 150, "fit": "crop"}`), 0666))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "Banner.json"), []byte(`{"w": 1200}`), 0666))
+
+	presets, err := NewPresets(&PresetsConfiguration{
+		Path: dir,
+		Definitions: map[string]map[string]interface{}{
+			"thumb":  {"w": 200, "h": 200},
+			"avatar": {"w": 64, "h": 64, "fit": "crop-focal-point", "q": 90},
+			"Card":   {"w": 300},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"avatar", "banner", "card", "hero", "thumb"}, presets.Names())
+
+	// the names are case insensitive
+	values, ok := presets.Get("BANNER")
+	assert.True(t, ok)
+	assert.Equal(t, url.Values{"w": {"1200"}}, values)
+
+	_, ok = presets.Get("card")
+	assert.True(t, ok)
+
+	values, ok = presets.Get("thumb")
+	assert.True(t, ok)
+	assert.Equal(t, url.Values{"w": {"150"}, "h": {"150"}, "fit": {"crop"}}, values)
+
+	_, ok = presets.Get("bad")
+	assert.False(t, ok)
+
+	parser := NewOptionParser(nil)
+	parser.SetPresets(presets)
+
+	req := httptest.NewRequest("GET", "http://localhost:8574/foo.jpg?p=hero&w=800", nil)
+
+	options, err := parser.Parse(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 800, options.Width)
+	assert.Equal(t, 900, options.Height)
+	assert.Equal(t, FitCropCenter, options.Fit)
+	assert.Equal(t, bimg.WEBP, options.Format)
+
+	req = httptest.NewRequest("GET", "http://localhost:8574/foo.jpg?p=bad", nil)
+
+	_, err = parser.Parse(req)
+	assert.EqualError(t, err, "invalid options: p: unknown preset")
+}
+
+func TestPresetsValidation(t *testing.T) {
+	_, err := NewPresets(&PresetsConfiguration{
+		Definitions: map[string]map[string]interface{}{
+			"thumb": {"w": 200, "fm": "jpj"},
+		},
+	})
+	assert.EqualError(t, err, "preset thumb: invalid options: fm: unsupported format")
+
+	_, err = NewPresets(&PresetsConfiguration{
+		Definitions: map[string]map[string]interface{}{
+			"thumb": {"w": 200, "p": "hero"},
+		},
+	})
+	assert.EqualError(t, err, "preset thumb: p parameter is not allowed")
+
+	dir := t.TempDir()
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "thumb.json"), []byte(`{"w": `), 0666))
+
+	_, err = NewPresets(&PresetsConfiguration{
+		Path: dir,
+	})
+	assert.Error(t, err)
+}
+
+func TestPresetsSetDefinitions(t *testing.T) {
+	presets, err := NewPresets(&PresetsConfiguration{
+		Definitions: map[string]map[string]interface{}{
+			"thumb": {"w": 200},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, presets.SetDefinitions(map[string]map[string]interface{}{
+		"thumb": {"w": 300},
+	}))
+
+	values, _ := presets.Get("thumb")
+	assert.Equal(t, "300", values.Get("w"))
+
+	assert.Error(t, presets.SetDefinitions(map[string]map[string]interface{}{
+		"thumb": {"w": "abc"},
+	}))
+
+	values, _ = presets.Get("thumb")
+	assert.Equal(t, "300", values.Get("w"))
+}
+
+func TestPresetsHotReload(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "thumb.json")
+
+	assert.NoError(t, os.WriteFile(file, []byte(`{"w": 150}`), 0666))
+
+	presets, err := NewPresets(&PresetsConfiguration{
+		Path:           dir,
+		ReloadInterval: 10 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	presets.Run()
+
+	assert.NoError(t, os.WriteFile(file, []byte(`{"w": 250}`), 0666))
+	assert.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Second)))
+
+	assert.Eventually(t, func() bool {
+		values, _ := presets.Get("thumb")
+
+		return values.Get("w") == "250"
+	}, time.Second, 10*time.Millisecond)
+}
@@ -7,10 +7,13 @@ package image
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 
 	"github.com/h2non/bimg"
 	"github.com/h2non/filetype"
+	"github.com/rs/zerolog/log"
 )
 
 // Image stores an image binary buffer and its MIME type
@@ -23,13 +26,23 @@ type Image struct {
 //go:generate mockery -case=underscore -inpkg -name=Processor
 type Processor interface {
 	ProcessImage(resource *Resource) error
+
+	ProcessStream(r io.Reader, resource *Resource) error
+}
+
+type processor struct {
+	limits *LimitsConfiguration
 }
 
-type processor struct{}
+// NewProcessor constructor, nil limits disable the guards
+func NewProcessor(limits *LimitsConfiguration) Processor {
+	if limits == nil {
+		limits = &LimitsConfiguration{}
+	}
 
-// NewProcessor constructor
-func NewProcessor() Processor {
-	return &processor{}
+	return &processor{
+		limits: limits,
+	}
 }
 
 func (processor) process(buf []byte, opts bimg.Options) (out Image, err error) {
@@ -57,14 +70,28 @@ func (processor) process(buf []byte, opts bimg.Options) (out Image, err error) {
 	return Image{Body: buf, Mime: mime}, nil
 }
 
-// ProcessImage from resource
-func (p processor) ProcessImage(resource *Resource) error {
+// processOperations executes the chain step by step, each step works on the output of the previous one
+func (p processor) processOperations(buf []byte, ops Operations) ([]byte, error) {
+	for i, op := range ops {
+		img, err := p.process(buf, op.ToBimg())
+		if err != nil {
+			return nil, fmt.Errorf("ops: step %d (%s) failed: %w", i+1, op, err)
+		}
+
+		buf = img.Body
+	}
+
+	return buf, nil
+}
+
+// detectMimeType returns the MIME type of the image from its first bytes
+func detectMimeType(buf []byte) string {
 	// Infer the body MIME type via mimesniff algorithm
-	mimeType := http.DetectContentType(resource.Body)
+	mimeType := http.DetectContentType(buf)
 
 	// If cannot infer the type, infer it via magic numbers
 	if mimeType == "application/octet-stream" {
-		kind, err := filetype.Get(resource.Body)
+		kind, err := filetype.Get(buf)
 		if err == nil && kind.MIME.Value != "" {
 			mimeType = kind.MIME.Value
 		}
@@ -75,22 +102,173 @@ func (p processor) ProcessImage(resource *Resource) error {
 		mimeType = "image/svg+xml"
 	}*/
 
+	return mimeType
+}
+
+// update resource with the processed image
+func (processor) update(resource *Resource, img Image) {
+	// a resource loaded from a cached derivative is one generation further from the original
+	generation := 1
+	if resource.Derivative != nil {
+		generation = resource.Derivative.Generation + 1
+	}
+
+	derivative, err := NewDerivative(img.Body, resource.Options, generation)
+	if err != nil {
+		log.Debug().Err(err).Msg("Cannot read the size of the processed image")
+	}
+
+	resource.MimeType = img.Mime
+	resource.Body = img.Body
+	resource.Size = len(img.Body)
+	resource.Derivative = derivative
+}
+
+// ProcessImage from resource
+func (p processor) ProcessImage(resource *Resource) error {
+	mimeType := detectMimeType(resource.Body)
+
 	// Finally check if image MIME type is supported
 	if !IsImageMimeTypeSupported(mimeType) {
 		return fmt.Errorf("MimeType %s is not supported", mimeType)
 	}
 
-	img, err := p.process(resource.Body, resource.Options.ToBimg())
+	// bimg.Size only reads the header, the image is not decoded
+	size, err := bimg.Size(resource.Body)
 	if err != nil {
 		return err
 	}
 
-	resource.MimeType = img.Mime
-	resource.Body = img.Body
+	if err := p.limits.Check(resource.Options, size.Width, size.Height); err != nil {
+		return err
+	}
+
+	buf, err := p.processOperations(resource.Body, resource.Options.Operations)
+	if err != nil {
+		return err
+	}
+
+	opts := resource.Options.ToBimg()
+
+	// the intermediate steps are encoded in PNG, the output keeps the source format
+	if len(resource.Options.Operations) > 0 && opts.Type == bimg.UNKNOWN {
+		opts.Type = bimg.DetermineImageType(resource.Body)
+	}
+
+	img, err := p.process(buf, opts)
+	if err != nil {
+		return err
+	}
+
+	p.update(resource, img)
+
+	return nil
+}
+
+func (processor) thumbnail(path string, opts *Options) (out Image, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("libvips internal error: %v", r)
+			out = Image{}
+		}
+	}()
+
+	buf, err := thumbnailFile(path, opts)
+	if err != nil {
+		return Image{}, err
+	}
+
+	mime := GetImageMimeType(bimg.DetermineImageType(buf))
+
+	return Image{Body: buf, Mime: mime}, nil
+}
+
+// ProcessStream from reader without loading the source in memory when the options
+// allow it: JPEG and WebP are shrunk on load and the file is read sequentially.
+// A reader which is not a file is first copied to a temporary file.
+func (p processor) ProcessStream(r io.Reader, resource *Resource) error {
+	if !resource.Options.IsThumbnail() {
+		return p.processReader(r, resource)
+	}
+
+	file, ok := r.(*os.File)
+	if !ok {
+		tmp, err := os.CreateTemp("", "hyperpic-stream-")
+		if err != nil {
+			return err
+		}
+
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if _, err := io.Copy(tmp, r); err != nil {
+			return err
+		}
+
+		file = tmp
+	}
+
+	header := make([]byte, 512)
+
+	n, err := file.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	header = header[:n]
+
+	mimeType := detectMimeType(header)
+
+	if !IsImageMimeTypeSupported(mimeType) {
+		return fmt.Errorf("MimeType %s is not supported", mimeType)
+	}
+
+	opts := *resource.Options
+
+	if opts.Format == bimg.UNKNOWN {
+		opts.Format = bimg.DetermineImageType(header)
+	}
+
+	// vips_thumbnail only encodes JPEG, WebP and PNG
+	if !opts.IsThumbnail() || opts.Format == bimg.UNKNOWN {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		return p.processReader(file, resource)
+	}
+
+	width, height, err := headerFile(file.Name())
+	if err != nil {
+		return err
+	}
+
+	if err := p.limits.Check(&opts, width, height); err != nil {
+		return err
+	}
+
+	img, err := p.thumbnail(file.Name(), &opts)
+	if err != nil {
+		return err
+	}
+
+	p.update(resource, img)
 
 	return nil
 }
 
+func (p processor) processReader(r io.Reader, resource *Resource) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	resource.Body = body
+	resource.Size = len(body)
+
+	return p.ProcessImage(resource)
+}
+
 // GetImageMimeType returns the MIME type based on the given image type code.
 func GetImageMimeType(code bimg.ImageType) string {
 	switch code {
@@ -102,6 +280,8 @@ func GetImageMimeType(code bimg.ImageType) string {
 		return "image/tiff"
 	case bimg.GIF:
 		return "image/gif"
+	case bimg.AVIF:
+		return "image/avif"
 	case bimg.SVG:
 		return "image/svg+xml"
 	case bimg.PDF:
@@ -0,0 +1,134 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// Peak RSS is a process wide high-water mark, run each benchmark alone to compare them:
+//   go test -run='^$' -bench=BenchmarkProcessLargeImage ./pkg/hyperpic/image/
+//   go test -run='^$' -bench=BenchmarkProcessLargeStream ./pkg/hyperpic/image/
+
+const (
+	largeImageWidth  = 12000
+	largeImageHeight = 8400
+)
+
+// gradient generates pixels on the fly so the source is never held in memory
+type gradient struct{}
+
+func (gradient) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (gradient) Bounds() image.Rectangle {
+	return image.Rect(0, 0, largeImageWidth, largeImageHeight)
+}
+
+func (gradient) At(x, y int) color.Color {
+	return color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x + y), A: 0xff}
+}
+
+// largeImage returns the path of a 100 megapixels JPEG, created once in the temp dir
+func largeImage(b *testing.B) string {
+	b.Helper()
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("hyperpic-bench-%dx%d.jpg", largeImageWidth, largeImageHeight))
+
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := jpeg.Encode(file, gradient{}, &jpeg.Options{Quality: 90}); err != nil {
+		os.Remove(path)
+
+		b.Fatal(err)
+	}
+
+	return path
+}
+
+func reportMaxRSS(b *testing.B) {
+	b.Helper()
+
+	var usage syscall.Rusage
+
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		b.Fatal(err)
+	}
+
+	// Maxrss is in kilobytes on linux
+	b.ReportMetric(float64(usage.Maxrss)/1024, "peak-rss-MB")
+}
+
+func BenchmarkProcessLargeImage(b *testing.B) {
+	path := largeImage(b)
+
+	p := NewProcessor(nil)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		res := &Resource{
+			Body:    body,
+			Options: &Options{Width: 400},
+		}
+
+		if err := p.ProcessImage(res); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	reportMaxRSS(b)
+}
+
+func BenchmarkProcessLargeStream(b *testing.B) {
+	path := largeImage(b)
+
+	p := NewProcessor(nil)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		file, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		res := &Resource{
+			Options: &Options{Width: 400},
+		}
+
+		if err := p.ProcessStream(file, res); err != nil {
+			b.Fatal(err)
+		}
+
+		file.Close()
+	}
+
+	reportMaxRSS(b)
+}
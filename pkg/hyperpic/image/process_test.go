@@ -12,6 +12,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/h2non/bimg"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -60,7 +61,7 @@ func TestProcessImage(t *testing.T) {
 		Options: o,
 	}
 
-	p := NewProcessor()
+	p := NewProcessor(nil)
 
 	assert.NoError(t, p.ProcessImage(res))
 
@@ -73,3 +74,143 @@ func TestProcessImage(t *testing.T) {
 
 	assert.EqualError(t, p.ProcessImage(res), "MimeType application/octet-stream is not supported")
 }
+
+func TestProcessImageWithOperations(t *testing.T) {
+	o := &Options{
+		Width:  20,
+		Height: 20,
+		Fit:    FitCropCenter,
+		Operations: Operations{
+			{Type: OperationCrop, Width: 100, Height: 100, X: 10, Y: 10},
+			{Type: OperationRotate, Angle: 90},
+		},
+	}
+
+	file, err := os.Open("../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	in, err := io.ReadAll(file)
+	assert.NoError(t, err)
+
+	res := &Resource{
+		Body:    in,
+		Options: o,
+	}
+
+	p := NewProcessor(nil)
+
+	assert.NoError(t, p.ProcessImage(res))
+
+	assert.Equal(t, "image/png", res.MimeType)
+}
+
+func TestProcessImageWithCropOperation(t *testing.T) {
+	in, err := os.ReadFile("../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	res := &Resource{
+		Body: in,
+		Options: &Options{
+			Operations: Operations{
+				{Type: OperationCrop, Width: 120, Height: 80, X: 10, Y: 20},
+			},
+		},
+	}
+
+	p := NewProcessor(nil)
+
+	assert.NoError(t, p.ProcessImage(res))
+
+	// the area is extracted and the source format is kept
+	assert.Equal(t, "image/jpeg", res.MimeType)
+
+	size, err := bimg.Size(res.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, 120, size.Width)
+	assert.Equal(t, 80, size.Height)
+}
+
+func TestProcessStream(t *testing.T) {
+	o := &Options{
+		Width:  20,
+		Height: 20,
+		Fit:    FitCropCenter,
+	}
+
+	file, err := os.Open("../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	defer file.Close()
+
+	res := &Resource{
+		Options: o,
+	}
+
+	p := NewProcessor(nil)
+
+	assert.NoError(t, p.ProcessStream(file, res))
+
+	assert.Equal(t, "image/jpeg", res.MimeType)
+	assert.Equal(t, len(res.Body), res.Size)
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(res.Body))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 20, cfg.Height)
+	assert.Equal(t, 20, cfg.Width)
+
+	in, err := os.ReadFile("../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	// reader spooled to a temporary file
+	res = &Resource{
+		Options: &Options{Width: 20, Format: bimg.WEBP},
+	}
+
+	assert.NoError(t, p.ProcessStream(bytes.NewReader(in), res))
+	assert.Equal(t, "image/webp", res.MimeType)
+
+	// options not supported by thumbnail fall back to the in memory processing
+	res = &Resource{
+		Options: &Options{Width: 20, Blur: 2},
+	}
+
+	assert.NoError(t, p.ProcessStream(bytes.NewReader(in), res))
+	assert.Equal(t, "image/png", res.MimeType)
+
+	res = &Resource{
+		Options: o,
+	}
+
+	assert.EqualError(t, p.ProcessStream(bytes.NewReader([]byte{0x01}), res), "MimeType application/octet-stream is not supported")
+}
+
+func TestProcessImageWithLimits(t *testing.T) {
+	in, err := os.ReadFile("../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	size, err := bimg.Size(in)
+	assert.NoError(t, err)
+
+	p := NewProcessor(&LimitsConfiguration{
+		MaxSourcePixels: size.Width*size.Height - 1,
+	})
+
+	err = p.ProcessImage(&Resource{
+		Body:    in,
+		Options: &Options{Width: 20},
+	})
+	assert.IsType(t, &LimitError{}, err)
+	assert.Equal(t, LimitSourcePixels, err.(*LimitError).Limit)
+
+	p = NewProcessor(&LimitsConfiguration{
+		MaxUpscale: 2,
+	})
+
+	err = p.ProcessImage(&Resource{
+		Body:    in,
+		Options: &Options{Width: size.Width * 3},
+	})
+	assert.IsType(t, &LimitError{}, err)
+	assert.Equal(t, LimitUpscale, err.(*LimitError).Limit)
+}
@@ -15,4 +15,5 @@ type Resource struct {
 	ModifiedAt time.Time
 	Body       []byte
 	Size       int
+	Derivative *Derivative
 }
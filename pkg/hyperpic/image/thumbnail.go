@@ -0,0 +1,168 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+/*
+#cgo pkg-config: vips
+#include <stdlib.h>
+#include "vips/vips.h"
+
+static int hyperpic_thumbnail(const char *filename, VipsImage **out, int width, int height, int size, int crop) {
+	return vips_thumbnail(filename, out, width,
+		"height", height,
+		"size", size,
+		"crop", crop,
+		NULL);
+}
+
+static int hyperpic_header(const char *filename, int *width, int *height) {
+	VipsImage *image = vips_image_new_from_file(filename, "access", VIPS_ACCESS_SEQUENTIAL, NULL);
+	if (image == NULL) {
+		return -1;
+	}
+
+	*width = vips_image_get_width(image);
+	*height = vips_image_get_height(image);
+
+	g_object_unref(image);
+
+	return 0;
+}
+
+static int hyperpic_write_to_buffer(VipsImage *in, const char *suffix, void **buf, size_t *len) {
+	return vips_image_write_to_buffer(in, suffix, buf, len, NULL);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/h2non/bimg"
+)
+
+// vipsMaxCoord is the width or height used when only one dimension is constrained
+const vipsMaxCoord = 10000000
+
+func vipsError() error {
+	s := C.GoString(C.vips_error_buffer())
+	C.vips_error_clear()
+
+	return errors.New(s)
+}
+
+func thumbnailSuffix(format bimg.ImageType, quality int) (string, error) {
+	switch format {
+	case bimg.JPEG:
+		return fmt.Sprintf(".jpg[Q=%d,strip]", quality), nil
+	case bimg.WEBP:
+		return fmt.Sprintf(".webp[Q=%d,strip]", quality), nil
+	case bimg.PNG:
+		return ".png[strip]", nil
+	default:
+		return "", fmt.Errorf("format %s is not supported by thumbnail", bimg.ImageTypeName(format))
+	}
+}
+
+// headerFile returns the size of the image file, only the header is read
+func headerFile(path string) (int, int, error) {
+	defer C.vips_thread_shutdown()
+
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var width, height C.int
+
+	if C.hyperpic_header(cpath, &width, &height) != 0 {
+		return 0, 0, vipsError()
+	}
+
+	return int(width), int(height), nil
+}
+
+// thumbnailFile resizes the file with vips_thumbnail: the source is opened with sequential
+// access and JPEG/WebP are shrunk on load, the full resolution image is never decoded in memory.
+func thumbnailFile(path string, o *Options) ([]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	suffix, err := thumbnailSuffix(o.Format, o.EffectiveQuality())
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := o.OutputSize()
+
+	if width == 0 {
+		width = vipsMaxCoord
+	}
+
+	if height == 0 {
+		height = vipsMaxCoord
+	}
+
+	size := C.int(C.VIPS_SIZE_BOTH)
+	crop := C.int(C.VIPS_INTERESTING_NONE)
+
+	switch o.Fit {
+	case FitCropCenter:
+		crop = C.int(C.VIPS_INTERESTING_CENTRE)
+	case FitCropFocalPoint:
+		crop = C.int(C.VIPS_INTERESTING_ATTENTION)
+	}
+
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var image *C.VipsImage
+
+	if C.hyperpic_thumbnail(cpath, &image, C.int(width), C.int(height), size, crop) != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	csuffix := C.CString(suffix)
+	defer C.free(unsafe.Pointer(csuffix))
+
+	var (
+		ptr    unsafe.Pointer
+		length C.size_t
+	)
+
+	if C.hyperpic_write_to_buffer(image, csuffix, &ptr, &length) != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(ptr))
+
+	return C.GoBytes(ptr, C.int(length)), nil
+}
+
+// IsThumbnail returns true if the options can be processed by vips_thumbnail:
+// a resize with an optional center or focal point crop, encoded in JPEG, WebP or PNG.
+func (o Options) IsThumbnail() bool {
+	if !o.IsDerivable() {
+		return false
+	}
+
+	switch o.Fit {
+	case FitContain, FitMax, FitCropCenter, FitCropFocalPoint:
+	default:
+		return false
+	}
+
+	switch o.Format {
+	case bimg.UNKNOWN, bimg.JPEG, bimg.WEBP, bimg.PNG:
+	default:
+		return false
+	}
+
+	return o.Orientation == bimg.D0 &&
+		o.Brightness == 0 &&
+		o.Contrast == 0 &&
+		o.Gamma == 0 &&
+		o.Sharpen == 0 &&
+		o.Blur == 0
+}
@@ -0,0 +1,42 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"testing"
+
+	"github.com/h2non/bimg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsIsThumbnail(t *testing.T) {
+	assert.True(t, Options{Width: 200}.IsThumbnail())
+	assert.True(t, Options{Width: 200, Height: 200, Fit: FitCropCenter, Format: bimg.WEBP}.IsThumbnail())
+	assert.True(t, Options{Height: 200, Fit: FitMax, Format: bimg.PNG}.IsThumbnail())
+	assert.False(t, Options{Width: 200, Fit: FitFill}.IsThumbnail())
+	assert.False(t, Options{}.IsThumbnail())
+	assert.False(t, Options{Width: 200, Format: bimg.GIF}.IsThumbnail())
+	assert.False(t, Options{Width: 200, Orientation: bimg.D90}.IsThumbnail())
+	assert.False(t, Options{Width: 200, Blur: 2}.IsThumbnail())
+	assert.False(t, Options{Width: 200, Crop: CropType{Width: 10, Height: 10}}.IsThumbnail())
+	assert.False(t, Options{Width: 200, Operations: Operations{{Type: OperationFlip}}}.IsThumbnail())
+}
+
+func TestThumbnailSuffix(t *testing.T) {
+	suffix, err := thumbnailSuffix(bimg.JPEG, 80)
+	assert.NoError(t, err)
+	assert.Equal(t, ".jpg[Q=80,strip]", suffix)
+
+	suffix, err = thumbnailSuffix(bimg.WEBP, 75)
+	assert.NoError(t, err)
+	assert.Equal(t, ".webp[Q=75,strip]", suffix)
+
+	suffix, err = thumbnailSuffix(bimg.PNG, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, ".png[strip]", suffix)
+
+	_, err = thumbnailSuffix(bimg.GIF, 100)
+	assert.EqualError(t, err, "format gif is not supported by thumbnail")
+}
@@ -61,6 +61,8 @@ func ExtensionToType(name string) bimg.ImageType {
 		return bimg.TIFF
 	case "gif":
 		return bimg.GIF
+	case "avif":
+		return bimg.AVIF
 	case "svg":
 		return bimg.SVG
 	case "pdf":
@@ -95,6 +95,7 @@ func TestExtensionToType(t *testing.T) {
 		{"gif", bimg.GIF},
 		{"svg", bimg.SVG},
 		{"pdf", bimg.PDF},
+		{"avif", bimg.AVIF},
 		{"multipart/form-data; encoding=utf-8", bimg.UNKNOWN},
 		{"json", bimg.UNKNOWN},
 		{"text", bimg.UNKNOWN},
@@ -121,6 +122,7 @@ func TestGetImageMimeType(t *testing.T) {
 		{bimg.GIF, "image/gif"},
 		{bimg.PDF, "application/pdf"},
 		{bimg.SVG, "image/svg+xml"},
+		{bimg.AVIF, "image/avif"},
 		{bimg.UNKNOWN, "image/jpeg"},
 	}
 
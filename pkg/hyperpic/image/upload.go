@@ -0,0 +1,182 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// Upload rules
+const (
+	UploadRuleFormat    = "format"
+	UploadRuleMinWidth  = "min_width"
+	UploadRuleMinHeight = "min_height"
+	UploadRuleMaxWidth  = "max_width"
+	UploadRuleMaxHeight = "max_height"
+	UploadRuleMaxPixels = "max_pixels"
+)
+
+// UploadError is returned when an uploaded image breaks a rule of the upload policy
+type UploadError struct {
+	Rule    string
+	Value   string
+	Message string
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("upload rejected by %s: %s", e.Rule, e.Message)
+}
+
+// UploadNormalizeConfiguration struct
+type UploadNormalizeConfiguration struct {
+	Enable  bool
+	Format  string
+	Quality int
+}
+
+// UploadConfiguration is the policy of the uploaded images, a zero value disables a rule
+type UploadConfiguration struct {
+	Formats       []string
+	MinWidth      int  `mapstructure:"min_width"`
+	MinHeight     int  `mapstructure:"min_height"`
+	MaxWidth      int  `mapstructure:"max_width"`
+	MaxHeight     int  `mapstructure:"max_height"`
+	MaxPixels     int  `mapstructure:"max_pixels"`
+	StripMetadata bool `mapstructure:"strip_metadata"`
+	Normalize     UploadNormalizeConfiguration
+}
+
+// Validate returns an error when the normalized format is unknown
+func (c UploadConfiguration) Validate() error {
+	if !c.Normalize.Enable {
+		return nil
+	}
+
+	if _, ok := formatToType[strings.ToLower(c.Normalize.Format)]; !ok {
+		return fmt.Errorf("upload: unknown normalize format %q", c.Normalize.Format)
+	}
+
+	return nil
+}
+
+func (c UploadConfiguration) isFormatAllowed(t bimg.ImageType) bool {
+	if len(c.Formats) == 0 {
+		return true
+	}
+
+	for _, format := range c.Formats {
+		format = strings.ToLower(format)
+
+		if format == bimg.ImageTypeName(t) || formatToType[format] == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c UploadConfiguration) checkSize(size bimg.ImageSize) error {
+	checks := []struct {
+		rule    string
+		value   int
+		limit   int
+		message string
+		invalid bool
+	}{
+		{UploadRuleMinWidth, size.Width, c.MinWidth, "width %d is lower than %d", size.Width < c.MinWidth},
+		{UploadRuleMinHeight, size.Height, c.MinHeight, "height %d is lower than %d", size.Height < c.MinHeight},
+		{UploadRuleMaxWidth, size.Width, c.MaxWidth, "width %d exceeds %d", c.MaxWidth > 0 && size.Width > c.MaxWidth},
+		{UploadRuleMaxHeight, size.Height, c.MaxHeight, "height %d exceeds %d", c.MaxHeight > 0 && size.Height > c.MaxHeight},
+		{UploadRuleMaxPixels, size.Width * size.Height, c.MaxPixels, "%d pixels exceeds %d", c.MaxPixels > 0 && size.Width*size.Height > c.MaxPixels},
+	}
+
+	for _, check := range checks {
+		if check.invalid {
+			return &UploadError{
+				Rule:    check.rule,
+				Value:   strconv.Itoa(check.value),
+				Message: fmt.Sprintf(check.message, check.value, check.limit),
+			}
+		}
+	}
+
+	return nil
+}
+
+// Process validates the uploaded image and returns the body to store. The whole image
+// is decoded to reject the files with a valid header only, it is re-encoded when the
+// format is normalized. The metadata of the JPEG, PNG and WebP images are stripped without
+// re-encoding them, the other formats are re-encoded losslessly. The data appended to the
+// image is dropped in both cases.
+func (c UploadConfiguration) Process(body []byte) ([]byte, error) {
+	t := bimg.DetermineImageType(body)
+
+	if t == bimg.UNKNOWN || !bimg.IsTypeSupported(t) {
+		return nil, &UploadError{
+			Rule:    UploadRuleFormat,
+			Message: "not a supported image",
+		}
+	}
+
+	if !c.isFormatAllowed(t) {
+		return nil, &UploadError{
+			Rule:    UploadRuleFormat,
+			Value:   bimg.ImageTypeName(t),
+			Message: "format not allowed",
+		}
+	}
+
+	decodeErr := &UploadError{
+		Rule:    UploadRuleFormat,
+		Value:   bimg.ImageTypeName(t),
+		Message: "cannot be decoded",
+	}
+
+	size, err := bimg.Size(body)
+	if err != nil {
+		return nil, decodeErr
+	}
+
+	if err := c.checkSize(size); err != nil {
+		return nil, err
+	}
+
+	options := bimg.Options{
+		Type:          t,
+		StripMetadata: c.StripMetadata,
+	}
+
+	if c.Normalize.Enable {
+		options.Type = formatToType[strings.ToLower(c.Normalize.Format)]
+		options.Quality = c.Normalize.Quality
+	} else {
+		// the formats stripped by the encoding are not recompressed
+		options.Lossless = true
+	}
+
+	// the size is read from the header, the pixels are only decoded by the encoding
+	buf, err := bimg.Resize(body, options)
+	if err != nil {
+		return nil, decodeErr
+	}
+
+	switch {
+	case c.Normalize.Enable:
+		return buf, nil
+	case c.StripMetadata:
+		// the metadata of the lossy formats are stripped without re-encoding them
+		if stripped, ok := stripMetadata(t, body); ok {
+			return stripped, nil
+		}
+
+		return buf, nil
+	default:
+		return body, nil
+	}
+}
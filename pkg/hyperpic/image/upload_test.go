@@ -0,0 +1,103 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"os"
+	"testing"
+
+	"github.com/h2non/bimg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadConfigurationProcess(t *testing.T) {
+	body, err := os.ReadFile("../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	size, err := bimg.Size(body)
+	assert.NoError(t, err)
+
+	buf, err := UploadConfiguration{Formats: []string{"jpeg", "png"}}.Process(body)
+	assert.NoError(t, err)
+	assert.Equal(t, body, buf)
+
+	buf, err = UploadConfiguration{}.Process(body)
+	assert.NoError(t, err)
+	assert.Equal(t, body, buf)
+
+	buf, err = UploadConfiguration{
+		Normalize: UploadNormalizeConfiguration{
+			Enable:  true,
+			Format:  "jpeg",
+			Quality: 80,
+		},
+	}.Process(body)
+	assert.NoError(t, err)
+	assert.Equal(t, bimg.JPEG, bimg.DetermineImageType(buf))
+
+	buf, err = UploadConfiguration{StripMetadata: true}.Process(body)
+	assert.NoError(t, err)
+	assert.Equal(t, bimg.PNG, bimg.DetermineImageType(buf))
+
+	// the jpeg is not recompressed to strip its metadata
+	jpg, err := os.ReadFile("../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	expected, ok := stripMetadata(bimg.JPEG, jpg)
+	assert.True(t, ok)
+
+	buf, err = UploadConfiguration{StripMetadata: true}.Process(jpg)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, buf)
+
+	_, err = UploadConfiguration{}.Process([]byte("<svg"))
+	assert.Equal(t, &UploadError{Rule: UploadRuleFormat, Message: "not a supported image"}, err)
+
+	_, err = UploadConfiguration{Formats: []string{"jpg", "webp"}}.Process(body)
+	assert.Equal(t, &UploadError{Rule: UploadRuleFormat, Value: "png", Message: "format not allowed"}, err)
+
+	_, err = UploadConfiguration{MinWidth: size.Width + 1}.Process(body)
+	assert.IsType(t, &UploadError{}, err)
+	assert.Equal(t, UploadRuleMinWidth, err.(*UploadError).Rule)
+
+	_, err = UploadConfiguration{MinHeight: size.Height + 1}.Process(body)
+	assert.Equal(t, UploadRuleMinHeight, err.(*UploadError).Rule)
+
+	_, err = UploadConfiguration{MaxWidth: size.Width - 1}.Process(body)
+	assert.Equal(t, UploadRuleMaxWidth, err.(*UploadError).Rule)
+
+	_, err = UploadConfiguration{MaxHeight: size.Height - 1}.Process(body)
+	assert.Equal(t, UploadRuleMaxHeight, err.(*UploadError).Rule)
+
+	_, err = UploadConfiguration{MaxPixels: size.Width*size.Height - 1}.Process(body)
+	assert.Equal(t, UploadRuleMaxPixels, err.(*UploadError).Rule)
+	assert.EqualError(t, err, "upload rejected by max_pixels: "+err.(*UploadError).Message)
+}
+
+func TestUploadConfigurationProcessPolyglot(t *testing.T) {
+	body, err := os.ReadFile("../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	for name, polyglot := range map[string][]byte{
+		// a valid header followed by a script
+		"gif": append([]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"), []byte("alert(document.domain)//")...),
+		// a truncated png
+		"png": body[:64],
+	} {
+		_, err := UploadConfiguration{}.Process(polyglot)
+		assert.Equal(t, &UploadError{Rule: UploadRuleFormat, Value: name, Message: "cannot be decoded"}, err, name)
+	}
+}
+
+func TestUploadConfigurationValidate(t *testing.T) {
+	assert.NoError(t, UploadConfiguration{}.Validate())
+	assert.NoError(t, UploadConfiguration{Normalize: UploadNormalizeConfiguration{Enable: true, Format: "WebP"}}.Validate())
+	assert.NoError(t, UploadConfiguration{Normalize: UploadNormalizeConfiguration{Format: "bmp"}}.Validate())
+	assert.EqualError(
+		t,
+		UploadConfiguration{Normalize: UploadNormalizeConfiguration{Enable: true, Format: "bmp"}}.Validate(),
+		`upload: unknown normalize format "bmp"`,
+	)
+}
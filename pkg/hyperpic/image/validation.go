@@ -0,0 +1,179 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/schema"
+)
+
+// OptionsConfiguration struct
+type OptionsConfiguration struct {
+	Strict bool
+}
+
+// ParamError describes an invalid query parameter
+type ParamError struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned in strict mode with every invalid or unknown parameter
+type ValidationError struct {
+	Params []ParamError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Params))
+
+	for i, param := range e.Params {
+		parts[i] = param.Name + ": " + param.Message
+	}
+
+	return "invalid options: " + strings.Join(parts, ", ")
+}
+
+// reservedParams are the query parameters handled outside of the schema decoder
+var reservedParams = map[string]bool{
+	"ops":    true,
+	"p":      true,
+	"strict": true,
+	"srcset": true,
+	"sizes":  true,
+	"fmt":    true,
+	"s":      true,
+	"exp":    true,
+}
+
+// schemaParams returns the query parameters decoded into Options
+func schemaParams() map[string]bool {
+	params := map[string]bool{}
+
+	t := reflect.TypeOf(Options{})
+
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("schema"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		params[name] = true
+	}
+
+	return params
+}
+
+// isStrict returns the strict mode of the request, the strict parameter overrides the default
+func isStrict(query url.Values, def bool) bool {
+	v := query.Get("strict")
+	if v == "" {
+		return def
+	}
+
+	strict, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+
+	return strict
+}
+
+// validate returns the errors of query parameters which would be silently ignored or replaced
+func (p OptionParser) validate(query url.Values, decodeErr error) []ParamError {
+	params := []ParamError{}
+
+	add := func(name string, message string) {
+		params = append(params, ParamError{
+			Name:    name,
+			Value:   query.Get(name),
+			Message: message,
+		})
+	}
+
+	if errs, ok := decodeErr.(schema.MultiError); ok {
+		for name, err := range errs {
+			switch err.(type) {
+			case schema.ConversionError:
+				add(name, "invalid value")
+			case schema.UnknownKeyError:
+			default:
+				add(name, err.Error())
+			}
+		}
+	}
+
+	for name, values := range query {
+		if !p.params[name] && !reservedParams[name] {
+			add(name, "unknown parameter")
+
+			continue
+		}
+
+		if len(values) > 1 {
+			add(name, "multiple values")
+		}
+
+		value := values[0]
+
+		switch name {
+		case "fm":
+			if _, ok := formatToType[value]; !ok {
+				add(name, "unsupported format")
+			}
+		case "fit":
+			if _, ok := fitToType[value]; !ok {
+				add(name, "unsupported fit")
+			}
+		case "or":
+			if _, ok := orientationToType[value]; !ok {
+				add(name, "unsupported orientation")
+			}
+		case "bg":
+			if bg := p.colorConverter(value).Interface().([]uint8); len(bg) != 3 {
+				add(name, "invalid color")
+			}
+		case "crop":
+			if crop := p.cropConverter(value).Interface().(CropType); crop.Width <= 0 || crop.Height <= 0 || crop.X < 0 || crop.Y < 0 {
+				add(name, "invalid crop, expected w,h,x,y")
+			}
+		case "ops":
+			if _, err := ParseOperations(value); err != nil {
+				add(name, err.Error())
+			}
+		case "strict":
+			if _, err := strconv.ParseBool(value); err != nil {
+				add(name, "invalid boolean")
+			}
+		case "w", "h":
+			if n, err := strconv.Atoi(value); err == nil && n < 0 {
+				add(name, "must be positive")
+			}
+		case "dpr":
+			if f, err := strconv.ParseFloat(value, 64); err == nil && f <= 0 {
+				add(name, "must be greater than 0")
+			}
+		case "q":
+			if n, err := strconv.Atoi(value); err == nil && (n < 0 || n > 100) {
+				add(name, "must be between 0 and 100")
+			}
+		case "blur":
+			if n, err := strconv.Atoi(value); err == nil && (n < 0 || n > 100) {
+				add(name, "must be between 0 and 100")
+			}
+		}
+	}
+
+	sort.SliceStable(params, func(i, j int) bool {
+		return params[i].Name < params[j].Name
+	})
+
+	return params
+}
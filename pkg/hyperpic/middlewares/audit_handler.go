@@ -0,0 +1,127 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/audit"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/ratelimit"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+)
+
+// NewAuditEntryContext stores the audit entry of the request, completed by the next handlers
+func NewAuditEntryContext(ctx context.Context, entry *audit.Entry) context.Context {
+	return context.WithValue(ctx, auditEntryKey, entry)
+}
+
+// AuditEntryFromContext returns the audit entry of the request
+func AuditEntryFromContext(ctx context.Context) (*audit.Entry, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	entry, ok := ctx.Value(auditEntryKey).(*audit.Entry)
+	if !ok {
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// setActor logs the name of the authenticated key and records it in the audit entry
+func setActor(r *http.Request, actor string) {
+	hlog.FromRequest(r).UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Str("auth_key", actor)
+	})
+
+	if entry, ok := AuditEntryFromContext(r.Context()); ok {
+		entry.Actor = actor
+	}
+}
+
+// auditAction returns the action of the request on an image
+func auditAction(r *http.Request) string {
+	switch r.Method {
+	case http.MethodPost:
+		return audit.ActionUpload
+	case http.MethodDelete:
+		if r.URL.Query().Get("from") == "source" {
+			return audit.ActionDelete
+		}
+
+		return audit.ActionPurge
+	default:
+		return audit.ActionAdmin
+	}
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// NewAuditHandler records the request in the audit log once it is served, denied requests included.
+// The action is derived from the method if empty. The auth handlers set the actor and the
+// controller sets the hash and the size of the image.
+func NewAuditHandler(sink audit.Sink, quotas *ratelimit.Quotas, action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if sink == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entry := &audit.Entry{
+				Time:   time.Now().UTC(),
+				IP:     quotas.ClientIP(r),
+				Method: r.Method,
+				Action: action,
+				Path:   r.URL.Path,
+			}
+
+			if entry.Action == "" {
+				entry.Action = auditAction(r)
+			}
+
+			if id, ok := hlog.IDFromRequest(r); ok {
+				entry.RequestID = id.String()
+			}
+
+			sw := &statusWriter{ResponseWriter: w}
+
+			next.ServeHTTP(sw, r.WithContext(NewAuditEntryContext(r.Context(), entry)))
+
+			entry.Status = sw.status
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+
+			entry.Outcome = audit.Outcome(entry.Status)
+
+			if err := sink.Write(entry); err != nil {
+				hlog.FromRequest(r).Error().Err(err).Msg("Audit log write failed")
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/audit"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuditEntryFromContext(t *testing.T) {
+	_, ok := AuditEntryFromContext(nil)
+	assert.False(t, ok)
+
+	_, ok = AuditEntryFromContext(context.Background())
+	assert.False(t, ok)
+
+	entry := &audit.Entry{Path: "/foo.jpg"}
+
+	actual, ok := AuditEntryFromContext(NewAuditEntryContext(context.Background(), entry))
+	assert.True(t, ok)
+	assert.Equal(t, entry, actual)
+}
+
+func TestAuditHandler(t *testing.T) {
+	var entries []*audit.Entry
+
+	sink := &audit.MockSink{}
+	sink.On("Write", mock.Anything).Run(func(args mock.Arguments) {
+		entries = append(entries, args.Get(0).(*audit.Entry))
+	}).Return(nil)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if entry, ok := AuditEntryFromContext(r.Context()); ok {
+			entry.Hash = "abc"
+			entry.Size = 3
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	middleware := alice.New(
+		hlog.NewHandler(zerolog.Nop()),
+		hlog.RequestIDHandler("req_id", "Request-Id"),
+		NewAuditHandler(sink, nil, ""),
+		NewAuthHandler(&config.AuthConfiguration{
+			Secret: "foo",
+		}),
+	)
+
+	for _, test := range []struct {
+		method  string
+		url     string
+		token   string
+		action  string
+		actor   string
+		status  int
+		outcome string
+	}{
+		{method: http.MethodPost, url: "/foo.jpg", token: "foo", action: audit.ActionUpload, actor: "secret", status: http.StatusCreated, outcome: audit.OutcomeSuccess},
+		{method: http.MethodDelete, url: "/foo.jpg?from=source", token: "bar", action: audit.ActionDelete, status: http.StatusUnauthorized, outcome: audit.OutcomeDenied},
+		{method: http.MethodDelete, url: "/foo.jpg", token: "foo", action: audit.ActionPurge, actor: "secret", status: http.StatusCreated, outcome: audit.OutcomeSuccess},
+		{method: http.MethodGet, url: "/foo.jpg", token: "foo", action: audit.ActionAdmin, actor: "secret", status: http.StatusCreated, outcome: audit.OutcomeSuccess},
+	} {
+		entries = nil
+
+		req := httptest.NewRequest(test.method, test.url, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		w := httptest.NewRecorder()
+
+		middleware.ThenFunc(handler).ServeHTTP(w, req)
+
+		assert.Equal(t, test.status, w.Result().StatusCode)
+
+		assert.Len(t, entries, 1)
+
+		entry := entries[0]
+
+		assert.Equal(t, test.action, entry.Action, test.url)
+		assert.Equal(t, test.actor, entry.Actor, test.url)
+		assert.Equal(t, test.status, entry.Status, test.url)
+		assert.Equal(t, test.outcome, entry.Outcome, test.url)
+		assert.Equal(t, test.method, entry.Method)
+		assert.Equal(t, "/foo.jpg", entry.Path)
+		assert.Equal(t, "192.0.2.1", entry.IP)
+		assert.NotEmpty(t, entry.RequestID)
+		assert.False(t, entry.Time.IsZero())
+	}
+
+	// the request is served when the entry cannot be written
+	failing := &audit.MockSink{}
+	failing.On("Write", mock.Anything).Return(errors.New("fail"))
+
+	req := httptest.NewRequest(http.MethodPost, "/foo.jpg", nil)
+
+	w := httptest.NewRecorder()
+
+	NewAuditHandler(failing, nil, audit.ActionSignUpload)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	})).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	failing.AssertCalled(t, "Write", mock.MatchedBy(func(e *audit.Entry) bool {
+		return e.Action == audit.ActionSignUpload && e.Status == http.StatusOK
+	}))
+
+	// disabled
+	called := false
+
+	NewAuditHandler(nil, nil, "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, called = AuditEntryFromContext(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.False(t, called)
+}
@@ -5,17 +5,88 @@
 package middlewares
 
 import (
-	"crypto/subtle"
+	"context"
 	"net/http"
 	"strings"
 
 	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/auth"
+	"github.com/rs/zerolog/hlog"
+	"github.com/rs/zerolog/log"
 )
 
+// NewAuthKeyContext stores the name of the authenticated key
+func NewAuthKeyContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, authKeyKey, name)
+}
+
+// AuthKeyFromContext returns the name of the key verified by the auth handler
+func AuthKeyFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+
+	name, ok := ctx.Value(authKeyKey).(string)
+
+	return name, ok
+}
+
+// requestScope returns the scope needed by the request
+func requestScope(r *http.Request) string {
+	switch r.Method {
+	case http.MethodPost:
+		return auth.ScopeUpload
+	case http.MethodDelete:
+		if r.URL.Query().Get("from") == "source" {
+			return auth.ScopeDelete
+		}
+
+		return auth.ScopePurge
+	default:
+		return auth.ScopeAdmin
+	}
+}
+
 // NewAuthHandler authenticate by key
 func NewAuthHandler(cfg *config.AuthConfiguration) func(http.Handler) http.Handler {
+	if cfg == nil {
+		cfg = &config.AuthConfiguration{}
+	}
+
+	keys := append([]auth.Key{}, cfg.Keys...)
+
+	// the legacy secret is an admin key
+	if cfg.Secret != "" {
+		keys = append(keys, auth.Key{
+			Name:   "secret",
+			Hash:   auth.HashKey(cfg.Secret),
+			Scopes: []string{auth.ScopeAdmin},
+		})
+	}
+
+	keyring := auth.NewKeyring(keys)
+
+	var verifier *auth.JWTVerifier
+
+	if cfg.JWT != nil && cfg.JWT.Enable {
+		var err error
+
+		// the keys are checked at startup, the tokens are rejected if they cannot be loaded
+		verifier, err = auth.NewJWTVerifier(cfg.JWT)
+		if err != nil {
+			log.Error().Err(err).Msg("JWT keys loading failed")
+		}
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the upload is authorized by its signed url
+			if _, ok := UploadPolicyFromContext(r.Context()); ok && r.Method == http.MethodPost {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
 			s := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
 			if len(s) != 2 {
 				http.Error(w, "Not authorized", http.StatusUnauthorized)
@@ -29,13 +100,86 @@ func NewAuthHandler(cfg *config.AuthConfiguration) func(http.Handler) http.Handl
 				return
 			}
 
-			if subtle.ConstantTimeCompare([]byte(s[1]), []byte(cfg.Secret)) == 0 {
+			if cfg.JWT != nil && cfg.JWT.Enable && auth.IsJWT(s[1]) {
+				serveJWT(w, r, next, verifier, s[1])
+
+				return
+			}
+
+			key, err := keyring.Authenticate(s[1])
+			if err != nil {
+				hlog.FromRequest(r).Info().Err(err).Msg("Authentication failed")
+
 				http.Error(w, "Not authorized", http.StatusUnauthorized)
 
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			setActor(r, key.Name)
+
+			if !key.Allow(requestScope(r), r.URL.Path) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewAuthKeyContext(r.Context(), key.Name)))
+		})
+	}
+}
+
+// NewAdminAuthHandler authenticates the admin endpoints, only the keys with the admin scope
+// are accepted. The JWT have no scope, the bearer tokens of the JWT form are forbidden.
+func NewAdminAuthHandler(cfg *config.AuthConfiguration) func(http.Handler) http.Handler {
+	authHandler := NewAuthHandler(cfg)
+
+	return func(next http.Handler) http.Handler {
+		authenticated := authHandler(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+			if len(s) == 2 && s[0] == "Bearer" && auth.IsJWT(s[1]) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+
+				return
+			}
+
+			authenticated.ServeHTTP(w, r)
 		})
 	}
 }
+
+// serveJWT authenticates the request with the token, the claims restrict the methods,
+// the paths and the size of the uploaded body
+func serveJWT(w http.ResponseWriter, r *http.Request, next http.Handler, verifier *auth.JWTVerifier, raw string) {
+	if verifier == nil {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+
+		return
+	}
+
+	token, err := verifier.Verify(raw)
+	if err != nil {
+		hlog.FromRequest(r).Info().Err(err).Msg("Authentication failed")
+
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+
+		return
+	}
+
+	actor := "jwt:" + token.Subject
+
+	setActor(r, actor)
+
+	if !token.Allow(r.Method, r.URL.Path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if token.MaxSize > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, token.MaxSize)
+	}
+
+	next.ServeHTTP(w, r.WithContext(NewAuthKeyContext(r.Context(), actor)))
+}
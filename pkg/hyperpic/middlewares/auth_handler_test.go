@@ -5,13 +5,25 @@
 package middlewares
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/auth"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/signature"
 	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -69,3 +81,213 @@ func TestAuthHandler(t *testing.T) {
 		assert.Equal(t, tc.expectedCode, resp.StatusCode)
 	}
 }
+
+func TestAuthHandlerWithKeys(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}
+
+	middleware := alice.New(
+		NewAuthHandler(&config.AuthConfiguration{
+			Keys: []auth.Key{
+				{Name: "catalog", Hash: auth.HashKey("foo"), Scopes: []string{auth.ScopeUpload, auth.ScopePurge}, Paths: []string{"/products/"}},
+				{Name: "expired", Hash: auth.HashKey("bar"), Scopes: []string{auth.ScopeAdmin}, ExpiresAt: time.Now().Add(-time.Hour)},
+			},
+		}),
+	)
+
+	for _, test := range []struct {
+		method string
+		url    string
+		token  string
+		status int
+	}{
+		{method: http.MethodPost, url: "/products/foo.jpg", token: "foo", status: http.StatusOK},
+		{method: http.MethodDelete, url: "/products/foo.jpg?from=cache", token: "foo", status: http.StatusOK},
+		{method: http.MethodDelete, url: "/products/foo.jpg?from=source", token: "foo", status: http.StatusForbidden},
+		{method: http.MethodPost, url: "/users/foo.jpg", token: "foo", status: http.StatusForbidden},
+		{method: http.MethodPost, url: "/products/foo.jpg", token: "bar", status: http.StatusUnauthorized},
+		{method: http.MethodPost, url: "/products/foo.jpg", token: "baz", status: http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(test.method, test.url, nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		w := httptest.NewRecorder()
+
+		middleware.ThenFunc(handler).ServeHTTP(w, req)
+
+		assert.Equal(t, test.status, w.Result().StatusCode, test.method+" "+test.url)
+	}
+}
+
+func TestAuthHandlerWithSignedUpload(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}
+
+	middleware := alice.New(
+		NewAuthHandler(&config.AuthConfiguration{
+			Secret: "foo",
+		}),
+	)
+
+	ctx := NewUploadPolicyContext(context.Background(), &signature.UploadPolicy{Path: "/foo.jpg"})
+
+	req := httptest.NewRequest(http.MethodPost, "/foo.jpg", nil).WithContext(ctx)
+
+	w := httptest.NewRecorder()
+
+	middleware.ThenFunc(handler).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	// the signed upload url does not authorize the other methods
+	req = httptest.NewRequest(http.MethodDelete, "/foo.jpg", nil).WithContext(ctx)
+
+	w = httptest.NewRecorder()
+
+	middleware.ThenFunc(handler).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+}
+
+func TestAuthHandlerLogsKeyName(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		hlog.FromRequest(r).Info().Msg("served")
+	}
+
+	middleware := alice.New(
+		hlog.NewHandler(zerolog.New(out)),
+		NewAuthHandler(&config.AuthConfiguration{
+			Keys: []auth.Key{
+				{Name: "catalog", Hash: auth.HashKey("foo"), Scopes: []string{auth.ScopeUpload}},
+			},
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/foo.jpg", nil)
+	req.Header.Set("Authorization", "Bearer foo")
+
+	middleware.ThenFunc(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, out.String(), `"auth_key":"catalog"`)
+}
+
+func newHS256Token(t *testing.T, secret string, claims map[string]interface{}) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+	payload, err := json.Marshal(claims)
+	assert.NoError(t, err)
+
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthHandlerWithJWT(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+
+			return
+		}
+
+		io.WriteString(w, "OK")
+	}
+
+	middleware := alice.New(
+		NewAuthHandler(&config.AuthConfiguration{
+			JWT: &auth.JWTConfiguration{
+				Enable: true,
+				Keys: []auth.JWTKey{
+					{Algorithm: auth.AlgorithmHS256, Secret: "secret"},
+				},
+			},
+		}),
+	)
+
+	token := newHS256Token(t, "secret", map[string]interface{}{
+		"sub":      "catalog",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+		"paths":    []string{"/products/"},
+		"methods":  []string{"POST"},
+		"max_size": 10,
+	})
+
+	for _, test := range []struct {
+		method string
+		url    string
+		token  string
+		body   string
+		status int
+	}{
+		{method: http.MethodPost, url: "/products/foo.jpg", token: token, body: "0123456789", status: http.StatusOK},
+		{method: http.MethodPost, url: "/products/foo.jpg", token: token, body: "0123456789a", status: http.StatusRequestEntityTooLarge},
+		{method: http.MethodDelete, url: "/products/foo.jpg", token: token, status: http.StatusForbidden},
+		{method: http.MethodPost, url: "/users/foo.jpg", token: token, status: http.StatusForbidden},
+		{method: http.MethodPost, url: "/products/foo.jpg", token: newHS256Token(t, "other", map[string]interface{}{}), status: http.StatusUnauthorized},
+		{method: http.MethodPost, url: "/products/foo.jpg", token: "foo", status: http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		w := httptest.NewRecorder()
+
+		middleware.ThenFunc(handler).ServeHTTP(w, req)
+
+		assert.Equal(t, test.status, w.Result().StatusCode, test.method+" "+test.url)
+	}
+}
+
+func TestAdminAuthHandler(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}
+
+	middleware := alice.New(
+		NewAdminAuthHandler(&config.AuthConfiguration{
+			Secret: "admin",
+			Keys: []auth.Key{
+				{Name: "catalog", Hash: auth.HashKey("foo"), Scopes: []string{auth.ScopeUpload}},
+			},
+			JWT: &auth.JWTConfiguration{
+				Enable: true,
+				Keys: []auth.JWTKey{
+					{Algorithm: auth.AlgorithmHS256, Secret: "secret"},
+				},
+			},
+		}),
+	)
+
+	// the token allows the GET requests on all the paths but has no admin scope
+	token := newHS256Token(t, "secret", map[string]interface{}{
+		"sub":     "catalog",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+		"paths":   []string{"/"},
+		"methods": []string{"GET", "POST"},
+	})
+
+	for _, test := range []struct {
+		token  string
+		status int
+	}{
+		{token: "admin", status: http.StatusOK},
+		{token: "foo", status: http.StatusForbidden},
+		{token: token, status: http.StatusForbidden},
+		{token: "bar", status: http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/_audit", nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		w := httptest.NewRecorder()
+
+		middleware.ThenFunc(handler).ServeHTTP(w, req)
+
+		assert.Equal(t, test.status, w.Result().StatusCode, test.token)
+	}
+}
@@ -9,8 +9,13 @@ import (
 	"math"
 	"net/http"
 	"strconv"
+
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
 )
 
+// saveDataQuality is the quality used when the client asks to reduce data usage
+const saveDataQuality = 65
+
 func parseInt(value string) int {
 	return int(math.Floor(parseFloat(value) + 0.5))
 }
@@ -21,9 +26,42 @@ func parseFloat(value string) float64 {
 	return math.Abs(val)
 }
 
-// NewClientHintsHandler parse query string
+// snapWidth returns the smallest allowed width larger than the hint, or the largest allowed width
+func snapWidth(widths []int, hint int) (int, bool) {
+	snapped, ok := 0, false
+
+	for _, width := range widths {
+		switch {
+		case !ok:
+			snapped, ok = width, true
+		case snapped < hint && width > snapped, width >= hint && width < snapped:
+			snapped = width
+		}
+	}
+
+	return snapped, ok
+}
+
+// snapDPR returns the largest allowed dpr lower than the hint
+func snapDPR(dprs []float64, hint float64) (float64, bool) {
+	snapped, ok := 0.0, false
+
+	for _, dpr := range dprs {
+		if dpr <= hint && (!ok || dpr > snapped) {
+			snapped, ok = dpr, true
+		}
+	}
+
+	return snapped, ok
+}
+
+// NewClientHintsHandler parse query string, the hints are ignored for the signed urls
+// which fix the image, and the width hint is ignored when a preset sets the size. Under
+// lockdown the width and dpr hints are snapped to the allowlisted values, or ignored.
 // see: http://httpwg.org/http-extensions/client-hints.html
-func NewClientHintsHandler() func(http.Handler) http.Handler {
+func NewClientHintsHandler(lockdown *config.ImageLockdownConfiguration) func(http.Handler) http.Handler {
+	locked := lockdown != nil && lockdown.Enable
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
@@ -35,21 +73,42 @@ func NewClientHintsHandler() func(http.Handler) http.Handler {
 				return
 			}
 
+			if IsSignedFromContext(ctx) {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
 			if dpr := r.Header.Get("DPR"); dpr != "" {
-				options.DPR = parseFloat(dpr)
+				value, ok := parseFloat(dpr), true
+				if locked {
+					value, ok = snapDPR(lockdown.DPRs, value)
+				}
+
+				if ok {
+					options.DPR = value
+
+					w.Header().Set("Content-DPR", fmt.Sprintf("%.1f", options.DPR))
+				}
 
-				w.Header().Set("Content-DPR", fmt.Sprintf("%.1f", options.DPR))
 				w.Header().Add("Vary", "DPR")
 			}
 
-			if width := r.Header.Get("Width"); width != "" {
-				options.Width = parseInt(width)
+			if width := r.Header.Get("Width"); width != "" && r.URL.Query().Get("p") == "" {
+				value, ok := parseInt(width), true
+				if locked {
+					value, ok = snapWidth(lockdown.Widths, value)
+				}
+
+				if ok {
+					options.Width = value
+				}
 
 				w.Header().Add("Vary", "Width")
 			}
 
 			if saveData := r.Header.Get("Save-Data"); saveData == "on" {
-				options.Quality = 65
+				options.Quality = saveDataQuality
 
 				w.Header().Add("Vary", "Save-Data")
 			}
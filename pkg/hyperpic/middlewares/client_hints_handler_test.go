@@ -24,7 +24,7 @@ func TestClientHintsHandlerWithoutParamsInContext(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	middleware := alice.New(
-		NewClientHintsHandler(),
+		NewClientHintsHandler(nil),
 	)
 
 	middleware.ThenFunc(handler).ServeHTTP(w, req)
@@ -54,8 +54,8 @@ func TestClientHintsHandler(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	middleware := alice.New(
-		NewOptionsHandler(image.NewOptionParser()),
-		NewClientHintsHandler(),
+		NewOptionsHandler(image.NewOptionParser(nil)),
+		NewClientHintsHandler(nil),
 	)
 
 	middleware.ThenFunc(handler).ServeHTTP(w, req)
@@ -69,3 +69,63 @@ func TestClientHintsHandler(t *testing.T) {
 	assert.Equal(t, []string{"DPR", "Width", "Save-Data"}, resp.Header["Vary"])
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
+
+func TestClientHintsHandlerSignedOrPreset(t *testing.T) {
+	presets, err := image.NewPresets(&image.PresetsConfiguration{
+		Definitions: map[string]map[string]interface{}{
+			"thumb": {"w": 200, "h": 200},
+		},
+	})
+	assert.NoError(t, err)
+
+	parser := image.NewOptionParser(nil)
+	parser.SetPresets(presets)
+
+	signed := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(NewSignedContext(r.Context(), true)))
+		})
+	}
+
+	for _, test := range []struct {
+		chain   alice.Chain
+		url     string
+		width   int
+		dpr     float64
+		quality int
+	}{
+		{
+			chain:   alice.New(signed, NewOptionsHandler(parser), NewClientHintsHandler(nil)),
+			url:     "http://example.com/foo.jpg?w=420",
+			width:   420,
+			quality: 0,
+		},
+		{
+			chain:   alice.New(NewOptionsHandler(parser), NewClientHintsHandler(nil)),
+			url:     "http://example.com/foo.jpg?p=thumb",
+			width:   200,
+			dpr:     2,
+			quality: 65,
+		},
+	} {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			options, err := OptionsFromContext(r.Context())
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.width, options.Width, test.url)
+			assert.Equal(t, test.dpr, options.DPR, test.url)
+			assert.Equal(t, test.quality, options.Quality, test.url)
+		}
+
+		req := httptest.NewRequest("GET", test.url, nil)
+		req.Header.Set("DPR", "2")
+		req.Header.Set("Width", "320")
+		req.Header.Set("Save-Data", "on")
+
+		w := httptest.NewRecorder()
+
+		test.chain.ThenFunc(handler).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	}
+}
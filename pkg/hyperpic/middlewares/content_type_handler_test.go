@@ -88,7 +88,7 @@ func TestContentTypeHandler(t *testing.T) {
 	}
 
 	middleware := alice.New(
-		NewOptionsHandler(image.NewOptionParser()),
+		NewOptionsHandler(image.NewOptionParser(nil)),
 		NewContentTypeHandler(),
 	)
 
@@ -0,0 +1,117 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/h2non/bimg"
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/httputil"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/rs/zerolog/hlog"
+	"github.com/rs/zerolog/log"
+)
+
+// loadPlaceholder reads the placeholder image served to the blocked requests
+func loadPlaceholder(path string) (*image.Resource, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &image.Resource{
+		Path:       path,
+		Name:       filepath.Base(path),
+		Body:       body,
+		Size:       len(body),
+		MimeType:   image.GetImageMimeType(bimg.DetermineImageType(body)),
+		ModifiedAt: info.ModTime(),
+	}, nil
+}
+
+// refererHost returns the host of the Origin header, or of the Referer header
+func refererHost(r *http.Request) (string, bool) {
+	for _, header := range []string{"Origin", "Referer"} {
+		value := r.Header.Get(header)
+		if value == "" || value == "null" {
+			continue
+		}
+
+		u, err := url.Parse(value)
+		if err != nil || u.Hostname() == "" {
+			return "", true
+		}
+
+		return u.Hostname(), true
+	}
+
+	return "", false
+}
+
+// NewHotlinkHandler blocks the requests of the sites not allowed by the rule of the path,
+// with a 403 or the placeholder image. The responses vary on the Origin and Referer headers,
+// the placeholder is not stored by the caches.
+func NewHotlinkHandler(cfg *config.ImageHotlinkConfiguration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if cfg == nil || !cfg.Enable {
+			return next
+		}
+
+		var placeholder *image.Resource
+
+		if cfg.Placeholder != "" {
+			var err error
+
+			placeholder, err = loadPlaceholder(cfg.Placeholder)
+			if err != nil {
+				log.Error().Err(err).Msg("Hotlink placeholder loading failed")
+			}
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rule := cfg.Rule(r.URL.Path)
+			if rule == nil {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			// a shared cache must not serve the response of a site to another one
+			w.Header().Add("Vary", "Origin")
+			w.Header().Add("Vary", "Referer")
+
+			host, ok := refererHost(r)
+
+			if (!ok && rule.AllowEmpty) || (ok && rule.AllowHost(host)) {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			hlog.FromRequest(r).Info().Str("referer_host", host).Msg("Hotlink blocked")
+
+			if placeholder == nil {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+
+				return
+			}
+
+			w.Header().Set("Content-Type", placeholder.MimeType)
+			w.Header().Set("X-Image-From", "placeholder")
+			w.Header().Set("Cache-Control", "private, no-store")
+
+			httputil.ServeImage(w, r, placeholder)
+		})
+	}
+}
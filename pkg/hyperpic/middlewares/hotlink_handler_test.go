@@ -0,0 +1,113 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHotlinkHandler(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}
+
+	cfg := &config.ImageHotlinkConfiguration{
+		Enable: true,
+		Rules: []config.ImageHotlinkRule{
+			{Prefix: "/", Hosts: []string{"your-domain.tld", "*.your-domain.tld"}, AllowEmpty: true},
+			{Prefix: "/private/", Hosts: []string{"admin.your-domain.tld"}},
+		},
+	}
+
+	for _, test := range []struct {
+		url     string
+		headers map[string]string
+		status  int
+	}{
+		{url: "/foo.jpg", status: http.StatusOK},
+		{url: "/foo.jpg", headers: map[string]string{"Referer": "https://www.your-domain.tld/page"}, status: http.StatusOK},
+		{url: "/foo.jpg", headers: map[string]string{"Origin": "https://your-domain.tld:8443"}, status: http.StatusOK},
+		{url: "/foo.jpg", headers: map[string]string{"Referer": "https://evil.tld/page"}, status: http.StatusForbidden},
+		{url: "/foo.jpg", headers: map[string]string{"Origin": "https://evil.tld", "Referer": "https://your-domain.tld/"}, status: http.StatusForbidden},
+		{url: "/foo.jpg", headers: map[string]string{"Referer": "not a url"}, status: http.StatusForbidden},
+		{url: "/private/foo.jpg", status: http.StatusForbidden},
+		{url: "/private/foo.jpg", headers: map[string]string{"Referer": "https://www.your-domain.tld/"}, status: http.StatusForbidden},
+		{url: "/private/foo.jpg", headers: map[string]string{"Referer": "https://admin.your-domain.tld/"}, status: http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+
+		for key, value := range test.headers {
+			req.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+
+		NewHotlinkHandler(cfg)(http.HandlerFunc(handler)).ServeHTTP(w, req)
+
+		assert.Equal(t, test.status, w.Result().StatusCode, test.url, test.headers)
+
+		// the allowed responses vary on the site of the request
+		assert.Equal(t, []string{"Origin", "Referer"}, w.Result().Header["Vary"], test.url)
+	}
+}
+
+func TestHotlinkHandlerWithPlaceholder(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}
+
+	data, err := os.ReadFile("../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	cfg := &config.ImageHotlinkConfiguration{
+		Enable: true,
+		Rules: []config.ImageHotlinkRule{
+			{Prefix: "/", Hosts: []string{"your-domain.tld"}},
+		},
+		Placeholder: "../../../_resources/hyperpic.png",
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/foo.jpg", nil)
+	req.Header.Set("Referer", "https://evil.tld/")
+
+	w := httptest.NewRecorder()
+
+	NewHotlinkHandler(cfg)(http.HandlerFunc(handler)).ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "placeholder", resp.Header.Get("X-Image-From"))
+	assert.Equal(t, "private, no-store", resp.Header.Get("Cache-Control"))
+	assert.Equal(t, []string{"Origin", "Referer"}, resp.Header["Vary"])
+	assert.Equal(t, data, body)
+
+	// a missing placeholder falls back to a 403
+	cfg.Placeholder = "../../../_resources/missing.png"
+
+	w = httptest.NewRecorder()
+
+	NewHotlinkHandler(cfg)(http.HandlerFunc(handler)).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+
+	// disabled
+	w = httptest.NewRecorder()
+
+	NewHotlinkHandler(&config.ImageHotlinkConfiguration{})(http.HandlerFunc(handler)).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
@@ -0,0 +1,246 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/httputil"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/rs/zerolog/hlog"
+)
+
+// lockdownParams are the parameters accepted with the allowlists, the other
+// parameters accept unbounded values
+var lockdownParams = map[string]bool{
+	"w":      true,
+	"h":      true,
+	"q":      true,
+	"dpr":    true,
+	"fit":    true,
+	"fm":     true,
+	"strict": true,
+	"srcset": true,
+	"sizes":  true,
+	"fmt":    true,
+	"s":      true,
+	"exp":    true,
+}
+
+// markupParams do not change the image, they are accepted with presets
+var markupParams = map[string]bool{
+	"strict": true,
+	"srcset": true,
+	"sizes":  true,
+	"fmt":    true,
+	"s":      true,
+	"exp":    true,
+}
+
+// NewSignedContext marks the request as signed
+func NewSignedContext(ctx context.Context, signed bool) context.Context {
+	return context.WithValue(ctx, signedKey, signed)
+}
+
+// IsSignedFromContext returns true if the request signature has been verified
+func IsSignedFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+
+	signed, _ := ctx.Value(signedKey).(bool)
+
+	return signed
+}
+
+// NewLockdownHandler only accepts presets or the allowlisted sizes and qualities,
+// it must be used after the client hints handler to check the final options.
+func NewLockdownHandler(cfg *config.ImageLockdownConfiguration, optionParser *image.OptionParser) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg == nil || !cfg.Enable {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			ctx := r.Context()
+
+			if cfg.AllowSigned && IsSignedFromContext(ctx) {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			options, err := OptionsFromContext(ctx)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			saveData := r.Header.Get("Save-Data") == "on"
+
+			fields := checkLockdown(cfg, optionParser, r.URL.Query(), options, saveData)
+			if len(fields) > 0 {
+				hlog.FromRequest(r).Info().Msgf("Request rejected by lockdown: %s", r.URL.RawQuery)
+
+				LockdownFailure(w, fields)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LockdownError lists the options of an url refused by the lockdown
+type LockdownError struct {
+	Fields []httputil.ErrorField
+}
+
+func (e *LockdownError) Error() string {
+	names := make([]string, len(e.Fields))
+
+	for i, field := range e.Fields {
+		names[i] = field.Name
+	}
+
+	return fmt.Sprintf("lockdown: options not allowed: %s", strings.Join(names, ", "))
+}
+
+// CheckLockdown returns a LockdownError when the lockdown refuses the options of the query,
+// the urls signed by the server are checked before signing them to not bypass the allowlists.
+func CheckLockdown(cfg *config.ImageLockdownConfiguration, optionParser *image.OptionParser, query url.Values) error {
+	if cfg == nil || !cfg.Enable {
+		return nil
+	}
+
+	options, err := optionParser.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+
+	if fields := checkLockdown(cfg, optionParser, query, options, false); len(fields) > 0 {
+		return &LockdownError{
+			Fields: fields,
+		}
+	}
+
+	return nil
+}
+
+// LockdownFailure writes the options refused by the lockdown
+func LockdownFailure(w http.ResponseWriter, fields []httputil.ErrorField) {
+	httputil.Failure(w, http.StatusBadRequest, httputil.ErrorMessage{
+		Code:    http.StatusBadRequest,
+		Message: "Options not allowed",
+		Fields:  fields,
+	})
+}
+
+func checkLockdown(
+	cfg *config.ImageLockdownConfiguration,
+	optionParser *image.OptionParser,
+	query url.Values,
+	options *image.Options,
+	saveData bool,
+) []httputil.ErrorField {
+	fields := []httputil.ErrorField{}
+
+	add := func(name string, value string, message string) {
+		fields = append(fields, httputil.ErrorField{
+			Name:    name,
+			Value:   value,
+			Message: message,
+		})
+	}
+
+	// the preset values are allowed, the query cannot override them
+	preset := &image.Options{}
+
+	if name := query.Get("p"); name != "" {
+		var err error
+
+		preset, err = optionParser.ParseQuery(url.Values{"p": {name}})
+		if err != nil {
+			add("p", name, "unknown preset")
+
+			return fields
+		}
+
+		for key := range query {
+			if key != "p" && !markupParams[key] {
+				add(key, query.Get(key), "cannot override a preset")
+			}
+		}
+	} else {
+		if !cfg.HasAllowlist() {
+			add("p", "", "a preset is required")
+
+			return fields
+		}
+
+		for key := range query {
+			if !lockdownParams[key] {
+				add(key, query.Get(key), "not allowed")
+			}
+		}
+	}
+
+	if options.Width != preset.Width && !containsInt(cfg.Widths, options.Width) {
+		add("w", fmt.Sprint(options.Width), "width not allowed")
+	}
+
+	if options.Height != preset.Height && !containsInt(cfg.Heights, options.Height) {
+		add("h", fmt.Sprint(options.Height), "height not allowed")
+	}
+
+	// the quality set by the Save-Data client hint is allowed
+	if saveData && options.Quality == saveDataQuality {
+		preset.Quality = saveDataQuality
+	}
+
+	if options.Quality != preset.Quality && !containsInt(cfg.Qualities, options.Quality) {
+		add("q", fmt.Sprint(options.Quality), "quality not allowed")
+	}
+
+	if options.DPR != preset.DPR && options.DPR != 0 && !containsFloat(cfg.DPRs, options.DPR) {
+		add("dpr", fmt.Sprint(options.DPR), "dpr not allowed")
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+
+	return fields
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFloat(values []float64, value float64) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
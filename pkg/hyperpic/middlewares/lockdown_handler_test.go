@@ -0,0 +1,252 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLockdownChain(t *testing.T, cfg *config.ImageLockdownConfiguration) alice.Chain {
+	presets, err := image.NewPresets(&image.PresetsConfiguration{
+		Definitions: map[string]map[string]interface{}{
+			"thumb": {"w": 200, "h": 200, "fit": "crop"},
+		},
+	})
+	assert.NoError(t, err)
+
+	parser := image.NewOptionParser(nil)
+	parser.SetPresets(presets)
+
+	return alice.New(
+		NewOptionsHandler(parser),
+		NewClientHintsHandler(cfg),
+		NewLockdownHandler(cfg, parser),
+	)
+}
+
+func TestLockdownHandlerPresetOnly(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}
+
+	chain := newLockdownChain(t, &config.ImageLockdownConfiguration{
+		Enable: true,
+		DPRs:   []float64{1, 2},
+	})
+
+	for _, test := range []struct {
+		url     string
+		headers map[string]string
+		status  int
+	}{
+		{url: "/foo.jpg?p=thumb", status: http.StatusOK},
+		{url: "/foo.jpg?p=thumb&strict=1", status: http.StatusOK},
+		{url: "/foo.jpg?p=thumb", headers: map[string]string{"DPR": "2", "Save-Data": "on"}, status: http.StatusOK},
+		{url: "/foo.jpg?p=thumb", headers: map[string]string{"DPR": "2.5"}, status: http.StatusOK},
+		{url: "/foo.jpg?p=thumb", headers: map[string]string{"DPR": "0.5"}, status: http.StatusOK},
+		{url: "/foo.jpg?p=thumb", headers: map[string]string{"Width": "320"}, status: http.StatusOK},
+		{url: "/foo.jpg?p=thumb&dpr=2.5", status: http.StatusBadRequest},
+		{url: "/foo.jpg?p=thumb&w=201", status: http.StatusBadRequest},
+		{url: "/foo.jpg?w=200", status: http.StatusBadRequest},
+		{url: "/foo.jpg", status: http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+
+		for key, value := range test.headers {
+			req.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+
+		chain.ThenFunc(handler).ServeHTTP(w, req)
+
+		assert.Equal(t, test.status, w.Result().StatusCode, test.url)
+	}
+}
+
+func TestLockdownHandlerAllowlist(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}
+
+	chain := newLockdownChain(t, &config.ImageLockdownConfiguration{
+		Enable:    true,
+		Widths:    []int{320, 640},
+		Qualities: []int{60, 80},
+	})
+
+	for _, test := range []struct {
+		url     string
+		headers map[string]string
+		status  int
+	}{
+		{url: "/foo.jpg?w=320", status: http.StatusOK},
+		{url: "/foo.jpg?w=640&q=60&fm=webp&fit=crop", status: http.StatusOK},
+		{url: "/foo.jpg?p=thumb", status: http.StatusOK},
+		{url: "/foo.jpg", status: http.StatusOK},
+		{url: "/foo.jpg?w=321", status: http.StatusBadRequest},
+		{url: "/foo.jpg?w=320&h=100", status: http.StatusBadRequest},
+		{url: "/foo.jpg?w=320&q=81", status: http.StatusBadRequest},
+		{url: "/foo.jpg?w=320&dpr=2", status: http.StatusBadRequest},
+		{url: "/foo.jpg?w=320&blur=3", status: http.StatusBadRequest},
+		// the hints are snapped to the allowlists, or ignored without allowlist
+		{url: "/foo.jpg?w=320", headers: map[string]string{"DPR": "2"}, status: http.StatusOK},
+		{url: "/foo.jpg?w=320", headers: map[string]string{"Width": "500"}, status: http.StatusOK},
+		{url: "/foo.jpg?w=320", headers: map[string]string{"Width": "4000"}, status: http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+
+		for key, value := range test.headers {
+			req.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+
+		chain.ThenFunc(handler).ServeHTTP(w, req)
+
+		assert.Equal(t, test.status, w.Result().StatusCode, test.url)
+	}
+}
+
+func TestLockdownHandlerClientHints(t *testing.T) {
+	chain := newLockdownChain(t, &config.ImageLockdownConfiguration{
+		Enable: true,
+		Widths: []int{640, 320, 1280},
+		DPRs:   []float64{1, 2},
+	})
+
+	for _, test := range []struct {
+		headers    map[string]string
+		width      int
+		dpr        float64
+		contentDPR string
+	}{
+		{headers: map[string]string{"DPR": "2"}, width: 320, dpr: 2, contentDPR: "2.0"},
+		{headers: map[string]string{"DPR": "3"}, width: 320, dpr: 2, contentDPR: "2.0"},
+		{headers: map[string]string{"DPR": "0.5"}, width: 320},
+		{headers: map[string]string{"Width": "500"}, width: 640},
+		{headers: map[string]string{"Width": "320"}, width: 320},
+		{headers: map[string]string{"Width": "4000"}, width: 1280},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/foo.jpg?w=320", nil)
+
+		for key, value := range test.headers {
+			req.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+
+		chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			options, err := OptionsFromContext(r.Context())
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.width, options.Width, test.headers)
+			assert.Equal(t, test.dpr, options.DPR, test.headers)
+		}).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode, test.headers)
+		assert.Equal(t, test.contentDPR, w.Result().Header.Get("Content-DPR"), test.headers)
+	}
+}
+
+func TestLockdownHandlerError(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}
+
+	chain := newLockdownChain(t, &config.ImageLockdownConfiguration{
+		Enable: true,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/foo.jpg?p=thumb&w=400&blur=2", nil)
+
+	w := httptest.NewRecorder()
+
+	chain.ThenFunc(handler).ServeHTTP(w, req)
+
+	resp := w.Result()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.JSONEq(t, `{
+		"error": {
+			"code": 400,
+			"message": "Options not allowed",
+			"fields": [
+				{"name": "blur", "value": "2", "message": "cannot override a preset"},
+				{"name": "w", "value": "400", "message": "cannot override a preset"},
+				{"name": "w", "value": "400", "message": "width not allowed"}
+			]
+		}
+	}`, string(body))
+}
+
+func TestLockdownHandlerSignedOrDisabled(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}
+
+	signed := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(NewSignedContext(r.Context(), true)))
+		})
+	}
+
+	cfg := &config.ImageLockdownConfiguration{
+		Enable:      true,
+		AllowSigned: true,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/foo.jpg?w=999", nil)
+	w := httptest.NewRecorder()
+
+	alice.New(signed).Extend(newLockdownChain(t, cfg)).ThenFunc(handler).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	// the client hints cannot change the signed options
+	req = httptest.NewRequest(http.MethodGet, "/foo.jpg?w=999", nil)
+	req.Header.Set("Width", "4000")
+	req.Header.Set("DPR", "3")
+	w = httptest.NewRecorder()
+
+	alice.New(signed).Extend(newLockdownChain(t, cfg)).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		options, err := OptionsFromContext(r.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, 999, options.Width)
+		assert.Equal(t, 0.0, options.DPR)
+	}).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Empty(t, w.Result().Header.Get("Content-DPR"))
+
+	cfg.AllowSigned = false
+
+	req = httptest.NewRequest(http.MethodGet, "/foo.jpg?w=999", nil)
+	w = httptest.NewRecorder()
+
+	alice.New(signed).Extend(newLockdownChain(t, cfg)).ThenFunc(handler).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	cfg.Enable = false
+
+	req = httptest.NewRequest(http.MethodGet, "/foo.jpg?w=999", nil)
+	w = httptest.NewRecorder()
+
+	newLockdownChain(t, cfg).ThenFunc(handler).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.False(t, IsSignedFromContext(req.Context()))
+}
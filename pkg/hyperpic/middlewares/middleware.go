@@ -15,4 +15,10 @@ type key int
 
 const (
 	optionsKey key = iota
+	signedKey
+	signatureKeyKey
+	rateLimitKey
+	authKeyKey
+	uploadPolicyKey
+	auditEntryKey
 )
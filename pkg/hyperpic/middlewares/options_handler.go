@@ -6,9 +6,11 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/euskadi31/go-server/response"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/httputil"
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
 	"github.com/rs/zerolog/log"
 )
@@ -40,6 +42,14 @@ func NewOptionsHandler(optionParser *image.OptionParser) func(http.Handler) http
 			if err != nil {
 				log.Error().Err(err).Msg("Options Parser")
 
+				var validationErr *image.ValidationError
+
+				if errors.As(err, &validationErr) {
+					failureFromValidationError(w, validationErr)
+
+					return
+				}
+
 				response.FailureFromError(w, http.StatusBadRequest, err)
 
 				return
@@ -51,3 +61,21 @@ func NewOptionsHandler(optionParser *image.OptionParser) func(http.Handler) http
 		})
 	}
 }
+
+func failureFromValidationError(w http.ResponseWriter, err *image.ValidationError) {
+	fields := make([]httputil.ErrorField, len(err.Params))
+
+	for i, param := range err.Params {
+		fields[i] = httputil.ErrorField{
+			Name:    param.Name,
+			Value:   param.Value,
+			Message: param.Message,
+		}
+	}
+
+	httputil.Failure(w, http.StatusBadRequest, httputil.ErrorMessage{
+		Code:    http.StatusBadRequest,
+		Message: "Invalid options",
+		Fields:  fields,
+	})
+}
@@ -38,7 +38,7 @@ func TestOptionsHandlerWithError(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	middleware := alice.New(
-		NewOptionsHandler(image.NewOptionParser()),
+		NewOptionsHandler(image.NewOptionParser(nil)),
 	)
 
 	middleware.ThenFunc(handler).ServeHTTP(w, req)
@@ -65,7 +65,7 @@ func TestOptionsHandler(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	middleware := alice.New(
-		NewOptionsHandler(image.NewOptionParser()),
+		NewOptionsHandler(image.NewOptionParser(nil)),
 	)
 
 	middleware.ThenFunc(handler).ServeHTTP(w, req)
@@ -77,3 +77,38 @@ func TestOptionsHandler(t *testing.T) {
 	assert.Equal(t, []byte("OK"), body)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
+
+func TestOptionsHandlerWithStrictError(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "handler should not be called")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/foo.jpg?w=zeer&q=85&fm=jpj&with=420", nil)
+
+	w := httptest.NewRecorder()
+
+	middleware := alice.New(
+		NewOptionsHandler(image.NewOptionParser(&image.OptionsConfiguration{
+			Strict: true,
+		})),
+	)
+
+	middleware.ThenFunc(handler).ServeHTTP(w, req)
+
+	resp := w.Result()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.JSONEq(t, `{
+		"error": {
+			"code": 400,
+			"message": "Invalid options",
+			"fields": [
+				{"name": "fm", "value": "jpj", "message": "unsupported format"},
+				{"name": "w", "value": "zeer", "message": "invalid value"},
+				{"name": "with", "value": "420", "message": "unknown parameter"}
+			]
+		}
+	}`, string(body))
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/hlog"
+)
+
+// NewRateLimitContext stores the client key of the quotas
+func NewRateLimitContext(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, rateLimitKey, client)
+}
+
+// RateLimitFromContext returns the client key of the quotas
+func RateLimitFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	client, _ := ctx.Value(rateLimitKey).(string)
+
+	return client
+}
+
+// rateLimitClient returns the client key: the key verified by the auth handler, the
+// signature key and the client IP, or the client IP
+func rateLimitClient(r *http.Request, quotas *ratelimit.Quotas) string {
+	if name, ok := AuthKeyFromContext(r.Context()); ok {
+		return "key:" + name
+	}
+
+	ip := quotas.ClientIP(r)
+
+	// a signed url is public, its clients do not share a bucket
+	if index, ok := SignatureKeyFromContext(r.Context()); ok {
+		return "signature:" + strconv.Itoa(index) + ":" + ip
+	}
+
+	return "ip:" + ip
+}
+
+// TooManyRequests writes a 429 with the Retry-After header in seconds
+func TooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+}
+
+// NewRateLimitHandler limits the requests per client, the client key is stored in the
+// context for the processing budget. The rejected requests are counted by budget.
+// It must be used after the auth handler to count the requests by key.
+func NewRateLimitHandler(quotas *ratelimit.Quotas, rejected *prometheus.CounterVec) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !quotas.Enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := rateLimitClient(r, quotas)
+
+			if ok, retryAfter := quotas.AllowRequest(client); !ok {
+				hlog.FromRequest(r).Info().Str("client", client).Msg("Rate limit exceeded")
+
+				if rejected != nil {
+					rejected.With(map[string]string{"budget": ratelimit.BudgetRequests}).Add(1)
+				}
+
+				TooManyRequests(w, retryAfter)
+
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewRateLimitContext(r.Context(), client)))
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperscale/hyperpic/cmd/hyperpic/app/config"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/ratelimit"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/signature"
+	"github.com/justinas/alice"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitHandler(t *testing.T) {
+	client := ""
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		client = RateLimitFromContext(r.Context())
+
+		io.WriteString(w, "OK")
+	}
+
+	quotas, err := ratelimit.NewQuotas(&ratelimit.Configuration{
+		Enable:   true,
+		Requests: ratelimit.BucketConfiguration{Rate: 0.5, Burst: 1},
+	})
+	assert.NoError(t, err)
+
+	rejected := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_rate_limited_total"}, []string{"budget"})
+
+	public := alice.New(
+		NewSignatureHandler(&signature.Configuration{Keys: []string{"old", "new"}}, ""),
+		NewRateLimitHandler(quotas, rejected),
+	)
+
+	private := alice.New(
+		NewAuthHandler(&config.AuthConfiguration{Secret: "foo"}),
+		NewRateLimitHandler(quotas, rejected),
+	)
+
+	signed, err := signature.SignURL("new", "/foo.jpg?w=200", time.Time{})
+	assert.NoError(t, err)
+
+	for _, test := range []struct {
+		chain         alice.Chain
+		url           string
+		authorization string
+		status        int
+		client        string
+	}{
+		{chain: public, url: "/foo.jpg", status: http.StatusOK, client: "ip:192.0.2.1"},
+		{chain: public, url: "/foo.jpg", status: http.StatusTooManyRequests},
+		// a bearer is not verified by the public chain, the client IP is used
+		{chain: public, url: "/foo.jpg", authorization: "Bearer bar", status: http.StatusTooManyRequests},
+		{chain: public, url: signed, status: http.StatusOK, client: "signature:1:192.0.2.1"},
+		{chain: private, url: "/foo.jpg", authorization: "Bearer foo", status: http.StatusOK, client: "key:secret"},
+		{chain: private, url: "/foo.jpg", authorization: "Bearer foo", status: http.StatusTooManyRequests},
+		{chain: private, url: "/foo.jpg", authorization: "Bearer bar", status: http.StatusUnauthorized},
+	} {
+		client = ""
+
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+
+		if test.authorization != "" {
+			req.Header.Set("Authorization", test.authorization)
+		}
+
+		w := httptest.NewRecorder()
+
+		test.chain.ThenFunc(handler).ServeHTTP(w, req)
+
+		resp := w.Result()
+
+		assert.Equal(t, test.status, resp.StatusCode, test.url)
+		assert.Equal(t, test.client, client, test.url)
+
+		if test.status == http.StatusTooManyRequests {
+			assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+		}
+	}
+
+	assert.Equal(t, 3.0, testutil.ToFloat64(rejected.With(map[string]string{"budget": ratelimit.BudgetRequests})))
+}
+
+func TestRateLimitHandlerDisabled(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}
+
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+
+		NewRateLimitHandler(nil, nil)(http.HandlerFunc(handler)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo.jpg", nil))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	}
+}
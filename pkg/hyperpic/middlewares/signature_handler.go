@@ -0,0 +1,73 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/signature"
+	"github.com/rs/zerolog/hlog"
+)
+
+// NewSignatureKeyContext stores the index of the key used to sign the request
+func NewSignatureKeyContext(ctx context.Context, index int) context.Context {
+	return context.WithValue(ctx, signatureKeyKey, index)
+}
+
+// SignatureKeyFromContext returns the index of the key used to sign the request
+func SignatureKeyFromContext(ctx context.Context) (int, bool) {
+	if ctx == nil {
+		return -1, false
+	}
+
+	index, ok := ctx.Value(signatureKeyKey).(int)
+	if !ok {
+		return -1, false
+	}
+
+	return index, true
+}
+
+// NewSignatureHandler verifies the s= signature of the url in the scope and marks the request
+// as signed. Unsigned requests are rejected when the signature is required, the signature is
+// ignored when the server has no key.
+func NewSignatureHandler(cfg *signature.Configuration, scope string) func(http.Handler) http.Handler {
+	signer := signature.NewSigner(cfg).WithScope(scope)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+
+			// without key the signature cannot be verified, the s parameter is ignored
+			// unless the signature is required
+			if query.Get(signature.ParamSignature) == "" || (len(cfg.Keys) == 0 && !cfg.Enable) {
+				if cfg.Enable {
+					http.Error(w, "Signature required", http.StatusForbidden)
+
+					return
+				}
+
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			index, err := signer.VerifyKey(r.URL.Path, query)
+			if err != nil {
+				hlog.FromRequest(r).Info().Err(err).Msg("Invalid signature")
+
+				http.Error(w, err.Error(), http.StatusForbidden)
+
+				return
+			}
+
+			ctx := NewSignedContext(r.Context(), true)
+			ctx = NewSignatureKeyContext(ctx, index)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignatureHandler(t *testing.T) {
+	signed := false
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		signed = IsSignedFromContext(r.Context())
+
+		io.WriteString(w, "OK")
+	}
+
+	cfg := &signature.Configuration{
+		Keys: []string{"secret"},
+	}
+
+	valid, err := signature.SignURL("secret", "/foo.jpg?w=200", time.Time{})
+	assert.NoError(t, err)
+
+	expired, err := signature.SignURL("secret", "/foo.jpg?w=200", time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+
+	for _, test := range []struct {
+		enable bool
+		url    string
+		status int
+		signed bool
+	}{
+		{url: valid, status: http.StatusOK, signed: true},
+		{url: "/foo.jpg?w=200", status: http.StatusOK},
+		{url: "/foo.jpg?w=200&s=foo", status: http.StatusForbidden},
+		{url: expired, status: http.StatusForbidden},
+		{enable: true, url: valid, status: http.StatusOK, signed: true},
+		{enable: true, url: "/foo.jpg?w=200", status: http.StatusForbidden},
+		{enable: true, url: valid + "&exp=" + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10), status: http.StatusForbidden},
+	} {
+		signed = false
+		cfg.Enable = test.enable
+
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+
+		w := httptest.NewRecorder()
+
+		NewSignatureHandler(cfg, "")(http.HandlerFunc(handler)).ServeHTTP(w, req)
+
+		assert.Equal(t, test.status, w.Result().StatusCode, test.url)
+		assert.Equal(t, test.signed, signed, test.url)
+	}
+}
+
+func TestSignatureHandlerWithScope(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}
+
+	cfg := &signature.Configuration{
+		Keys: []string{"secret"},
+	}
+
+	unscoped, err := signature.SignURL("secret", "/foo.jpg?w=200", time.Time{})
+	assert.NoError(t, err)
+
+	scoped, err := signature.SignScopedURL("secret", "brand-a", "/foo.jpg?w=200", time.Time{})
+	assert.NoError(t, err)
+
+	for _, test := range []struct {
+		scope  string
+		url    string
+		status int
+	}{
+		{scope: "brand-a", url: scoped, status: http.StatusOK},
+		{scope: "brand-a", url: unscoped, status: http.StatusForbidden},
+		{scope: "brand-b", url: scoped, status: http.StatusForbidden},
+		{scope: "", url: scoped, status: http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+
+		w := httptest.NewRecorder()
+
+		NewSignatureHandler(cfg, test.scope)(http.HandlerFunc(handler)).ServeHTTP(w, req)
+
+		assert.Equal(t, test.status, w.Result().StatusCode, test.scope)
+	}
+}
+
+func TestSignatureHandlerWithoutKey(t *testing.T) {
+	signed := true
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		signed = IsSignedFromContext(r.Context())
+
+		io.WriteString(w, "OK")
+	}
+
+	cfg := &signature.Configuration{}
+
+	req := httptest.NewRequest(http.MethodGet, "/foo.jpg?w=200&s=foo", nil)
+
+	w := httptest.NewRecorder()
+
+	NewSignatureHandler(cfg, "")(http.HandlerFunc(handler)).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.False(t, signed)
+
+	// the required signature cannot be verified
+	cfg.Enable = true
+
+	req = httptest.NewRequest(http.MethodGet, "/foo.jpg?w=200&s=foo", nil)
+
+	w = httptest.NewRecorder()
+
+	NewSignatureHandler(cfg, "")(http.HandlerFunc(handler)).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/signature"
+	"github.com/rs/zerolog/hlog"
+)
+
+// NewUploadPolicyContext stores the policy of the signed upload url
+func NewUploadPolicyContext(ctx context.Context, policy *signature.UploadPolicy) context.Context {
+	return context.WithValue(ctx, uploadPolicyKey, policy)
+}
+
+// UploadPolicyFromContext returns the policy of the signed upload url
+func UploadPolicyFromContext(ctx context.Context) (*signature.UploadPolicy, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	policy, ok := ctx.Value(uploadPolicyKey).(*signature.UploadPolicy)
+	if !ok {
+		return nil, false
+	}
+
+	return policy, true
+}
+
+// NewSignedUploadHandler authorizes the uploads with a signed url instead of a bearer token.
+// The path, the mount and the expiry are checked here, the body is limited to the signed maximum
+// size and the content type is checked by the controller. Requests with an Authorization header
+// or without signature are left to the auth handler.
+func NewSignedUploadHandler(cfg *signature.Configuration, mount string) func(http.Handler) http.Handler {
+	signer := signature.NewSigner(cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+
+			if r.Method != http.MethodPost || r.Header.Get("Authorization") != "" || query.Get(signature.ParamSignature) == "" {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			policy, index, err := signer.VerifyUpload(r.URL.Path, query)
+			if err == nil && policy.Mount != mount {
+				err = signature.ErrMount
+			}
+
+			if err != nil {
+				hlog.FromRequest(r).Info().Err(err).Msg("Invalid upload signature")
+
+				http.Error(w, err.Error(), http.StatusForbidden)
+
+				return
+			}
+
+			setActor(r, "signed-upload")
+
+			if policy.MaxSize > 0 && r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, policy.MaxSize)
+			}
+
+			ctx := NewUploadPolicyContext(r.Context(), policy)
+			ctx = NewSignatureKeyContext(ctx, index)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package middlewares
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadPolicyFromContext(t *testing.T) {
+	_, ok := UploadPolicyFromContext(nil)
+	assert.False(t, ok)
+
+	_, ok = UploadPolicyFromContext(context.Background())
+	assert.False(t, ok)
+
+	policy := &signature.UploadPolicy{Path: "/foo.jpg"}
+
+	actual, ok := UploadPolicyFromContext(NewUploadPolicyContext(context.Background(), policy))
+	assert.True(t, ok)
+	assert.Equal(t, policy, actual)
+}
+
+func TestSignedUploadHandler(t *testing.T) {
+	var policy *signature.UploadPolicy
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		policy, _ = UploadPolicyFromContext(r.Context())
+
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+
+			return
+		}
+
+		io.WriteString(w, "OK")
+	}
+
+	cfg := &signature.Configuration{
+		Keys: []string{"secret"},
+	}
+
+	valid := signature.SignUpload("secret", signature.UploadPolicy{
+		Path:    "/products/",
+		MaxSize: 4,
+		Expires: time.Now().Add(time.Minute),
+		Mount:   "brand-a",
+	}).Encode()
+
+	expired := signature.SignUpload("secret", signature.UploadPolicy{
+		Path:    "/products/",
+		Expires: time.Now().Add(-time.Minute),
+		Mount:   "brand-a",
+	}).Encode()
+
+	// signed for another mount, or without mount
+	other := signature.SignUpload("secret", signature.UploadPolicy{
+		Path:    "/products/",
+		Expires: time.Now().Add(time.Minute),
+		Mount:   "brand-b",
+	}).Encode()
+
+	unbound := signature.SignUpload("secret", signature.UploadPolicy{
+		Path:    "/products/",
+		Expires: time.Now().Add(time.Minute),
+	}).Encode()
+
+	for _, test := range []struct {
+		method string
+		url    string
+		auth   string
+		body   string
+		status int
+		signed bool
+	}{
+		{method: http.MethodPost, url: "/products/foo.jpg?" + valid, body: "abcd", status: http.StatusOK, signed: true},
+		{method: http.MethodPost, url: "/products/foo.jpg?" + valid, body: "abcde", status: http.StatusRequestEntityTooLarge, signed: true},
+		{method: http.MethodPost, url: "/foo.jpg?" + valid, status: http.StatusForbidden},
+		{method: http.MethodPost, url: "/products/foo.jpg?" + expired, status: http.StatusForbidden},
+		{method: http.MethodPost, url: "/products/foo.jpg?" + other, status: http.StatusForbidden},
+		{method: http.MethodPost, url: "/products/foo.jpg?" + unbound, status: http.StatusForbidden},
+		{method: http.MethodPost, url: "/products/foo.jpg?" + valid + "&ct=image%2Fgif", status: http.StatusForbidden},
+		{method: http.MethodPost, url: "/products/foo.jpg", status: http.StatusOK},
+		{method: http.MethodPost, url: "/products/foo.jpg?" + valid, auth: "Bearer foo", status: http.StatusOK},
+		{method: http.MethodDelete, url: "/products/foo.jpg?" + valid, status: http.StatusOK},
+	} {
+		policy = nil
+
+		req := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body))
+
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+
+		w := httptest.NewRecorder()
+
+		NewSignedUploadHandler(cfg, "brand-a")(http.HandlerFunc(handler)).ServeHTTP(w, req)
+
+		assert.Equal(t, test.status, w.Result().StatusCode, test.url)
+		assert.Equal(t, test.signed, policy != nil, test.url)
+	}
+}
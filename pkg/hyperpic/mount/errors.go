@@ -0,0 +1,15 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mount
+
+import "errors"
+
+// mount errors
+var (
+	ErrNoName     = errors.New("mount: the name is empty")
+	ErrNoSelector = errors.New("mount: the host or the prefix is required")
+	ErrDuplicate  = errors.New("mount: the name is already used")
+	ErrReserved   = errors.New("mount: the default name is reserved")
+)
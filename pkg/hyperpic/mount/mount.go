@@ -0,0 +1,102 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/eager"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+)
+
+// DefaultName is the name of the mount serving the requests matching no other mount
+const DefaultName = "default"
+
+type key int
+
+const mountKey key = iota
+
+// Mount is a tenant selected by the Host header or by the path prefix of the request,
+// with its own source provider, cache namespace and option parser
+type Mount struct {
+	Name         string
+	Host         string
+	Prefix       string
+	Source       provider.SourceProvider
+	Cache        provider.CacheProvider
+	OptionParser *image.OptionParser
+	Eager        *eager.Pool
+}
+
+// normalizePrefix returns the prefix with a leading slash and without trailing slash
+func normalizePrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+
+	return "/" + prefix
+}
+
+// hostname returns the host without port, in lower case
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
+
+// Match returns true if the host and the path of the request are served by the mount
+func (m Mount) Match(host string, path string) bool {
+	if m.Host != "" && hostname(m.Host) != hostname(host) {
+		return false
+	}
+
+	if m.Prefix == "" {
+		return true
+	}
+
+	return path == m.Prefix || strings.HasPrefix(path, m.Prefix+"/")
+}
+
+// Path returns the path of the resource in the mount, without the prefix
+func (m Mount) Path(path string) string {
+	if m.Prefix == "" {
+		return path
+	}
+
+	path = strings.TrimPrefix(path, m.Prefix)
+	if path == "" {
+		return "/"
+	}
+
+	return path
+}
+
+// SignatureScope returns the scope of the url signatures, the name of a mount with a host.
+// The full paths of the other mounts differ, their urls are signed without scope.
+func (m Mount) SignatureScope() string {
+	if m.Host == "" {
+		return ""
+	}
+
+	return m.Name
+}
+
+// NewContext returns a copy of the context with the mount
+func NewContext(ctx context.Context, m *Mount) context.Context {
+	return context.WithValue(ctx, mountKey, m)
+}
+
+// FromContext gets the mount out of the context
+func FromContext(ctx context.Context) (*Mount, bool) {
+	m, ok := ctx.Value(mountKey).(*Mount)
+
+	return m, ok
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountMatch(t *testing.T) {
+	host := Mount{Host: "images.brand-a.com"}
+
+	assert.True(t, host.Match("images.brand-a.com", "/kayaks.jpg"))
+	assert.True(t, host.Match("IMAGES.brand-a.com:8080", "/kayaks.jpg"))
+	assert.False(t, host.Match("images.brand-b.com", "/kayaks.jpg"))
+
+	prefix := Mount{Prefix: "/brand-b"}
+
+	assert.True(t, prefix.Match("localhost", "/brand-b/kayaks.jpg"))
+	assert.True(t, prefix.Match("localhost", "/brand-b"))
+	assert.False(t, prefix.Match("localhost", "/brand-bb/kayaks.jpg"))
+	assert.False(t, prefix.Match("localhost", "/kayaks.jpg"))
+
+	both := Mount{Host: "images.brand-c.com", Prefix: "/c"}
+
+	assert.True(t, both.Match("images.brand-c.com", "/c/kayaks.jpg"))
+	assert.False(t, both.Match("images.brand-c.com", "/kayaks.jpg"))
+	assert.False(t, both.Match("localhost", "/c/kayaks.jpg"))
+}
+
+func TestMountPath(t *testing.T) {
+	assert.Equal(t, "/kayaks.jpg", Mount{Host: "images.brand-a.com"}.Path("/kayaks.jpg"))
+	assert.Equal(t, "/products/kayaks.jpg", Mount{Prefix: "/brand-b"}.Path("/brand-b/products/kayaks.jpg"))
+	assert.Equal(t, "/", Mount{Prefix: "/brand-b"}.Path("/brand-b"))
+}
+
+func TestMountSignatureScope(t *testing.T) {
+	assert.Equal(t, "brand-a", Mount{Name: "brand-a", Host: "images.brand-a.com"}.SignatureScope())
+	assert.Equal(t, "", Mount{Name: "brand-b", Prefix: "/brand-b"}.SignatureScope())
+	assert.Equal(t, "", Mount{Name: DefaultName}.SignatureScope())
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	m := &Mount{Name: "brand-a"}
+
+	found, ok := FromContext(NewContext(context.Background(), m))
+	assert.True(t, ok)
+	assert.Equal(t, m, found)
+}
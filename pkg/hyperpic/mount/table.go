@@ -0,0 +1,88 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Table resolves the mount of the requests, the most specific mount is used:
+// the mounts with a host first, then the longest prefixes.
+type Table struct {
+	fallback *Mount
+	mounts   []*Mount
+}
+
+// NewTable constructor, the fallback serves the requests matching no mount
+func NewTable(fallback *Mount, mounts ...*Mount) (*Table, error) {
+	fallback.Name = DefaultName
+
+	names := map[string]bool{}
+
+	sorted := make([]*Mount, 0, len(mounts))
+
+	for _, m := range mounts {
+		m.Prefix = normalizePrefix(m.Prefix)
+
+		switch {
+		case m.Name == "":
+			return nil, ErrNoName
+		case m.Name == DefaultName:
+			return nil, ErrReserved
+		case names[m.Name]:
+			return nil, ErrDuplicate
+		case m.Host == "" && m.Prefix == "":
+			return nil, ErrNoSelector
+		}
+
+		names[m.Name] = true
+
+		sorted = append(sorted, m)
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if (sorted[i].Host != "") != (sorted[j].Host != "") {
+			return sorted[i].Host != ""
+		}
+
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+
+	return &Table{
+		fallback: fallback,
+		mounts:   sorted,
+	}, nil
+}
+
+// Resolve returns the mount serving the request
+func (t Table) Resolve(r *http.Request) *Mount {
+	for _, m := range t.mounts {
+		if m.Match(r.Host, r.URL.Path) {
+			return m
+		}
+	}
+
+	return t.fallback
+}
+
+// Fallback returns the mount serving the requests matching no mount
+func (t Table) Fallback() *Mount {
+	return t.fallback
+}
+
+// All returns the mounts, the fallback is the first one
+func (t Table) All() []*Mount {
+	return append([]*Mount{t.fallback}, t.mounts...)
+}
+
+// Close waits for the eager transforms queued by the mounts
+func (t Table) Close() {
+	for _, m := range t.All() {
+		if m.Eager != nil {
+			m.Eager.Close()
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/eager"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTable(t *testing.T) {
+	_, err := NewTable(&Mount{}, &Mount{Host: "images.brand-a.com"})
+	assert.Equal(t, ErrNoName, err)
+
+	_, err = NewTable(&Mount{}, &Mount{Name: DefaultName, Host: "images.brand-a.com"})
+	assert.Equal(t, ErrReserved, err)
+
+	_, err = NewTable(&Mount{}, &Mount{Name: "brand-a", Prefix: "/"})
+	assert.Equal(t, ErrNoSelector, err)
+
+	_, err = NewTable(&Mount{}, &Mount{Name: "brand-a", Prefix: "/a"}, &Mount{Name: "brand-a", Prefix: "/b"})
+	assert.Equal(t, ErrDuplicate, err)
+}
+
+func TestTableResolve(t *testing.T) {
+	fallback := &Mount{}
+	brandA := &Mount{Name: "brand-a", Host: "images.brand-a.com"}
+	brandB := &Mount{Name: "brand-b", Prefix: "brand-b/"}
+	brandBSale := &Mount{Name: "brand-b-sale", Prefix: "/brand-b/sale"}
+	brandASale := &Mount{Name: "brand-a-sale", Host: "images.brand-a.com", Prefix: "/sale"}
+
+	table, err := NewTable(fallback, brandB, brandA, brandBSale, brandASale)
+	assert.NoError(t, err)
+
+	assert.Equal(t, DefaultName, table.Fallback().Name)
+	assert.Equal(t, "/brand-b", brandB.Prefix)
+
+	for url, expected := range map[string]*Mount{
+		"http://localhost/kayaks.jpg":                      fallback,
+		"http://localhost/brand-b/kayaks.jpg":              brandB,
+		"http://localhost/brand-b/sale/kayaks.jpg":         brandBSale,
+		"http://images.brand-a.com/kayaks.jpg":             brandA,
+		"http://images.brand-a.com/brand-b/kayaks.jpg":     brandA,
+		"http://images.brand-a.com:8574/sale/kayaks.jpg":   brandASale,
+		"http://images.brand-c.com/brand-b/sale/kayak.jpg": brandBSale,
+	} {
+		assert.Equal(t, expected, table.Resolve(httptest.NewRequest("GET", url, nil)), url)
+	}
+
+	assert.Equal(t, []*Mount{fallback, brandASale, brandA, brandBSale, brandB}, table.All())
+}
+
+func TestTableClose(t *testing.T) {
+	pool := eager.NewPool(&eager.Configuration{Workers: 1}, image.NewOptionParser(nil), &image.MockProcessor{}, &provider.MockCacheProvider{})
+
+	table, err := NewTable(&Mount{}, &Mount{Name: "brand-a", Host: "images.brand-a.com", Eager: pool})
+	assert.NoError(t, err)
+
+	table.Close()
+
+	// the queue of the pool is closed
+	assert.Panics(t, func() {
+		pool.Close()
+	})
+}
@@ -0,0 +1,25 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package azure
+
+import "time"
+
+// SourceConfiguration struct
+type SourceConfiguration struct {
+	// Endpoint of the blob service, https://<account>.blob.core.windows.net if empty
+	Endpoint string
+	// Account and Key default to the AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_KEY env variables
+	Account string
+	// Key is the base64 shared key of the account
+	Key string
+	// SAS token used instead of the shared key, defaults to the AZURE_STORAGE_SAS_TOKEN env variable
+	SAS       string
+	Container string
+	// Prefix of the blob names
+	Prefix string
+	// BlockSize of the block uploads, the images larger than a block are uploaded in blocks
+	BlockSize int64 `mapstructure:"block_size"`
+	Timeout   time.Duration
+}
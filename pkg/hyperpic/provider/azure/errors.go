@@ -0,0 +1,47 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// azure errors
+var (
+	ErrInvalidPath = errors.New("invalid URL path")
+	ErrNoAuth      = errors.New("azure: a shared key or a SAS token is required")
+)
+
+// Error returned by the blob service
+type Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("azure: http status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("azure: %s: %s", e.Code, e.Message)
+}
+
+// readError decodes the error of the response body
+func readError(status int, body io.Reader) error {
+	e := &Error{
+		StatusCode: status,
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(body, 1<<16))
+
+	// the body of the HEAD requests is empty
+	_ = xml.Unmarshal(data, e)
+
+	return e
+}
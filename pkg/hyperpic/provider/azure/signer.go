@@ -0,0 +1,121 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sharedKey signs the requests with the Shared Key authorization of the storage services
+type sharedKey struct {
+	account string
+	key     []byte
+}
+
+func newSharedKey(account string, key string) (*sharedKey, error) {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sharedKey{
+		account: account,
+		key:     decoded,
+	}, nil
+}
+
+// canonicalizedHeaders returns the x-ms-* headers sorted by name
+func canonicalizedHeaders(req *http.Request) string {
+	names := []string{}
+	headers := map[string]string{}
+
+	for key, values := range req.Header {
+		key = strings.ToLower(key)
+
+		if !strings.HasPrefix(key, "x-ms-") {
+			continue
+		}
+
+		names = append(names, key)
+		headers[key] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	for _, name := range names {
+		b.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	return b.String()
+}
+
+// canonicalizedResource returns the account, the escaped path and the query parameters sorted by name
+func (k sharedKey) canonicalizedResource(u *url.URL) string {
+	var b strings.Builder
+
+	b.WriteString("/" + k.account + u.EscapedPath())
+
+	query := u.Query()
+
+	names := make([]string, 0, len(query))
+
+	for name := range query {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := append([]string{}, query[name]...)
+
+		sort.Strings(values)
+
+		b.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+
+	return b.String()
+}
+
+// stringToSign of the request, the x-ms-date header replaces the Date header
+func (k sharedKey) stringToSign(req *http.Request) string {
+	length := ""
+	if req.ContentLength > 0 {
+		length = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		length,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"",
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		canonicalizedHeaders(req) + k.canonicalizedResource(req.URL),
+	}, "\n")
+}
+
+// sign adds the authorization header of the request
+func (k sharedKey) sign(req *http.Request) {
+	mac := hmac.New(sha256.New, k.key)
+
+	mac.Write([]byte(k.stringToSign(req)))
+
+	req.Header.Set("Authorization", "SharedKey "+k.account+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedKeyStringToSign(t *testing.T) {
+	k, err := newSharedKey("myaccount", base64.StdEncoding.EncodeToString([]byte("secret")))
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPut, "https://myaccount.blob.core.windows.net/images/products/kayaks.jpg?comp=block&blockid=YmxvY2s%3D", strings.NewReader("abc"))
+	req.Header.Set("Content-Type", "image/jpeg")
+	req.Header.Set("X-Ms-Version", "2021-08-06")
+	req.Header.Set("X-Ms-Date", "Mon, 01 Jan 2024 00:00:00 GMT")
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+
+	assert.Equal(t, "PUT\n\n\n3\n\nimage/jpeg\n\n\n\n\n\n\n"+
+		"x-ms-blob-type:BlockBlob\nx-ms-date:Mon, 01 Jan 2024 00:00:00 GMT\nx-ms-version:2021-08-06\n"+
+		"/myaccount/images/products/kayaks.jpg\nblockid:YmxvY2s=\ncomp:block", k.stringToSign(req))
+
+	k.sign(req)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(k.stringToSign(req)))
+
+	assert.Equal(t, "SharedKey myaccount:"+base64.StdEncoding.EncodeToString(mac.Sum(nil)), req.Header.Get("Authorization"))
+
+	// the content length is empty for the requests without body
+	req = httptest.NewRequest(http.MethodGet, "http://127.0.0.1:10000/myaccount/images/kayaks.jpg", nil)
+
+	assert.Equal(t, "GET\n\n\n\n\n\n\n\n\n\n\n\n/myaccount/myaccount/images/kayaks.jpg", k.stringToSign(req))
+
+	_, err = newSharedKey("myaccount", "%%%")
+	assert.Error(t, err)
+}
@@ -0,0 +1,314 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/fsutil"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/rs/zerolog/log"
+)
+
+// apiVersion of the blob service
+const apiVersion = "2021-08-06"
+
+// defaultBlockSize of the block uploads
+const defaultBlockSize = 4 << 20
+
+// SourceProvider stores the sources as block blobs in an Azure storage container
+type SourceProvider struct {
+	cfg       *SourceConfiguration
+	endpoint  string
+	sas       url.Values
+	sharedKey *sharedKey
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewSourceProvider func
+func NewSourceProvider(cfg *SourceConfiguration) (*SourceProvider, error) {
+	account := cfg.Account
+	if account == "" {
+		account = os.Getenv("AZURE_STORAGE_ACCOUNT")
+	}
+
+	key := cfg.Key
+	if key == "" {
+		key = os.Getenv("AZURE_STORAGE_KEY")
+	}
+
+	sas := cfg.SAS
+	if sas == "" {
+		sas = os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+	}
+
+	p := &SourceProvider{
+		cfg:      cfg,
+		endpoint: strings.TrimSuffix(cfg.Endpoint, "/"),
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		now: time.Now,
+	}
+
+	if p.endpoint == "" {
+		p.endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+
+	switch {
+	case sas != "":
+		query, err := url.ParseQuery(strings.TrimPrefix(sas, "?"))
+		if err != nil {
+			return nil, fmt.Errorf("azure: invalid SAS token: %w", err)
+		}
+
+		p.sas = query
+	case key != "":
+		sk, err := newSharedKey(account, key)
+		if err != nil {
+			return nil, fmt.Errorf("azure: invalid shared key: %w", err)
+		}
+
+		p.sharedKey = sk
+	default:
+		return nil, ErrNoAuth
+	}
+
+	return p, nil
+}
+
+// blobURL returns the url of the blob of the resource path
+func (p SourceProvider) blobURL(resourcePath string) (*url.URL, error) {
+	if fsutil.ContainsDotDot(resourcePath) {
+		return nil, ErrInvalidPath
+	}
+
+	u, err := url.Parse(p.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimPrefix(path.Join(p.cfg.Prefix, resourcePath), "/")
+
+	u.Path += "/" + p.cfg.Container + "/" + name
+
+	segments := strings.Split(u.Path, "/")
+
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	u.RawPath = strings.Join(segments, "/")
+
+	return u, nil
+}
+
+// do authorizes and sends the request, the errors of the service are returned as *Error
+func (p SourceProvider) do(method string, u *url.URL, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	q := url.Values{}
+
+	for key, values := range query {
+		q[key] = values
+	}
+
+	for key, values := range p.sas {
+		q[key] = values
+	}
+
+	target := *u
+	target.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	// the request url is parsed again, the escaped path must be kept
+	req.URL = &target
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	req.Header.Set("X-Ms-Date", p.now().UTC().Format(http.TimeFormat))
+	req.Header.Set("X-Ms-Version", apiVersion)
+
+	if p.sharedKey != nil {
+		p.sharedKey.sign(req)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+
+		return nil, readError(resp.StatusCode, resp.Body)
+	}
+
+	return resp, nil
+}
+
+// Open resource from the container without reading it
+func (p SourceProvider) Open(resource *image.Resource) (*image.Resource, io.ReadCloser, error) {
+	u, err := p.blobURL(resource.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := p.do(http.MethodGet, u, nil, nil, nil)
+	if err != nil {
+		if e, ok := err.(*Error); ok && e.StatusCode == http.StatusNotFound {
+			return nil, nil, &os.PathError{Op: "open", Path: resource.Path, Err: os.ErrNotExist}
+		}
+
+		return nil, nil, err
+	}
+
+	source := &image.Resource{
+		Path:     resource.Path,
+		Options:  resource.Options,
+		Name:     path.Base(resource.Path),
+		Size:     int(resp.ContentLength),
+		MimeType: resp.Header.Get("Content-Type"),
+	}
+
+	if modifiedAt, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		source.ModifiedAt = modifiedAt
+	}
+
+	return source, resp.Body, nil
+}
+
+// Get resource from the container
+func (p SourceProvider) Get(resource *image.Resource) (*image.Resource, error) {
+	source, body, err := p.Open(resource)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	source.Body = data
+	source.Size = len(data)
+
+	return source, nil
+}
+
+// Set resource to the container as a block blob, the images larger than a block are uploaded in blocks
+func (p SourceProvider) Set(resource *image.Resource) error {
+	u, err := p.blobURL(resource.Path)
+	if err != nil {
+		return err
+	}
+
+	contentType := http.DetectContentType(resource.Body)
+
+	blockSize := p.cfg.BlockSize
+	if blockSize <= 0 {
+		blockSize = defaultBlockSize
+	}
+
+	if int64(len(resource.Body)) > blockSize {
+		return p.putBlocks(u, resource.Body, blockSize, contentType)
+	}
+
+	header := http.Header{}
+	header.Set("X-Ms-Blob-Type", "BlockBlob")
+	header.Set("Content-Type", contentType)
+
+	resp, err := p.do(http.MethodPut, u, nil, resource.Body, header)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+type blockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+// putBlocks uploads the blocks then commits the block list, the uncommitted blocks
+// of a failed upload are garbage collected by the service
+func (p SourceProvider) putBlocks(u *url.URL, body []byte, blockSize int64, contentType string) error {
+	list := blockList{}
+
+	for offset := int64(0); offset < int64(len(body)); offset += blockSize {
+		end := offset + blockSize
+		if end > int64(len(body)) {
+			end = int64(len(body))
+		}
+
+		// the ids of the blocks of a blob must have the same length
+		id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", len(list.Latest))))
+
+		resp, err := p.do(http.MethodPut, u, url.Values{
+			"comp":    {"block"},
+			"blockid": {id},
+		}, body[offset:end], nil)
+		if err != nil {
+			return err
+		}
+
+		resp.Body.Close()
+
+		list.Latest = append(list.Latest, id)
+	}
+
+	data, err := xml.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("X-Ms-Blob-Content-Type", contentType)
+
+	resp, err := p.do(http.MethodPut, u, url.Values{"comp": {"blocklist"}}, append([]byte(xml.Header), data...), header)
+	if err != nil {
+		return err
+	}
+
+	log.Debug().Msgf("Write source blob in %d blocks", len(list.Latest))
+
+	return resp.Body.Close()
+}
+
+// Del source blob with its snapshots, the blob is kept for the retention period
+// when soft delete is enabled on the account and can be restored with Undelete Blob
+func (p SourceProvider) Del(resource *image.Resource) error {
+	u, err := p.blobURL(resource.Path)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("X-Ms-Delete-Snapshots", "include")
+
+	resp, err := p.do(http.MethodDelete, u, nil, nil, header)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
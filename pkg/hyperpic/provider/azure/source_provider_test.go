@@ -0,0 +1,358 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/stretchr/testify/assert"
+)
+
+// Well-known account of the Azurite emulator
+const (
+	azuriteAccount = "devstoreaccount1"
+	azuriteKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+type blob struct {
+	body        []byte
+	contentType string
+	modified    time.Time
+	deleted     bool
+}
+
+// fakeBlobService is a stand-in of the blob service with the Azurite path-style urls,
+// it verifies the shared key signatures or the SAS signature
+type fakeBlobService struct {
+	mtx       sync.Mutex
+	sharedKey *sharedKey
+	sas       string
+	blobs     map[string]*blob
+	blocks    map[string][]byte
+	requests  []string
+}
+
+func newFakeBlobService(k *sharedKey, sas string) *fakeBlobService {
+	return &fakeBlobService{
+		sharedKey: k,
+		sas:       sas,
+		blobs:     map[string]*blob{},
+		blocks:    map[string][]byte{},
+	}
+}
+
+func (f *fakeBlobService) error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func (f *fakeBlobService) authorized(r *http.Request) bool {
+	if f.sas != "" {
+		return r.URL.Query().Get("sig") == f.sas
+	}
+
+	clone := r.Clone(r.Context())
+	clone.URL.Host = r.Host
+
+	f.sharedKey.sign(clone)
+
+	return clone.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+func (f *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if r.Header.Get("X-Ms-Version") == "" {
+		f.error(w, http.StatusBadRequest, "MissingRequiredHeader")
+
+		return
+	}
+
+	if !f.authorized(r) {
+		f.error(w, http.StatusForbidden, "AuthenticationFailed")
+
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	name := r.URL.Path
+	query := r.URL.Query()
+
+	f.requests = append(f.requests, r.Method+" "+query.Get("comp"))
+
+	switch {
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		f.blocks[name+"#"+query.Get("blockid")] = body
+
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		list := blockList{}
+		if err := xml.Unmarshal(body, &list); err != nil {
+			f.error(w, http.StatusBadRequest, "InvalidXmlDocument")
+
+			return
+		}
+
+		b := &blob{contentType: r.Header.Get("X-Ms-Blob-Content-Type"), modified: time.Now()}
+
+		for _, id := range list.Latest {
+			block, ok := f.blocks[name+"#"+id]
+			if !ok {
+				f.error(w, http.StatusBadRequest, "InvalidBlockList")
+
+				return
+			}
+
+			b.body = append(b.body, block...)
+		}
+
+		f.blobs[name] = b
+
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut:
+		if r.Header.Get("X-Ms-Blob-Type") != "BlockBlob" {
+			f.error(w, http.StatusBadRequest, "InvalidBlobType")
+
+			return
+		}
+
+		f.blobs[name] = &blob{
+			body:        body,
+			contentType: r.Header.Get("Content-Type"),
+			modified:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet:
+		b, ok := f.blobs[name]
+		if !ok || b.deleted {
+			f.error(w, http.StatusNotFound, "BlobNotFound")
+
+			return
+		}
+
+		w.Header().Set("Content-Type", b.contentType)
+		w.Header().Set("Last-Modified", b.modified.Format(http.TimeFormat))
+		w.Write(b.body)
+	case r.Method == http.MethodDelete:
+		b, ok := f.blobs[name]
+		if !ok || b.deleted {
+			f.error(w, http.StatusNotFound, "BlobNotFound")
+
+			return
+		}
+
+		if r.Header.Get("X-Ms-Delete-Snapshots") != "include" {
+			f.error(w, http.StatusConflict, "SnapshotsPresent")
+
+			return
+		}
+
+		// soft delete
+		b.deleted = true
+
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		f.error(w, http.StatusMethodNotAllowed, "UnsupportedHttpVerb")
+	}
+}
+
+func TestNewSourceProvider(t *testing.T) {
+	_, err := NewSourceProvider(&SourceConfiguration{Account: "myaccount"})
+	assert.Equal(t, ErrNoAuth, err)
+
+	_, err = NewSourceProvider(&SourceConfiguration{Account: "myaccount", Key: "%%%"})
+	assert.Error(t, err)
+
+	_, err = NewSourceProvider(&SourceConfiguration{Account: "myaccount", SAS: "%zz"})
+	assert.Error(t, err)
+
+	p, err := NewSourceProvider(&SourceConfiguration{Account: "myaccount", Container: "images", Prefix: "sources", SAS: "?sv=2021-08-06&sig=abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", p.sas.Get("sig"))
+
+	u, err := p.blobURL("/products/my kayak.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://myaccount.blob.core.windows.net/images/sources/products/my%20kayak.jpg", u.String())
+
+	_, err = p.blobURL("/../kayaks.jpg")
+	assert.Equal(t, ErrInvalidPath, err)
+
+	os.Setenv("AZURE_STORAGE_ACCOUNT", "envaccount")
+	os.Setenv("AZURE_STORAGE_KEY", azuriteKey)
+
+	defer os.Unsetenv("AZURE_STORAGE_ACCOUNT")
+	defer os.Unsetenv("AZURE_STORAGE_KEY")
+
+	p, err = NewSourceProvider(&SourceConfiguration{})
+	assert.NoError(t, err)
+	assert.Equal(t, "envaccount", p.sharedKey.account)
+	assert.Equal(t, "https://envaccount.blob.core.windows.net", p.endpoint)
+}
+
+func testSourceProvider(t *testing.T, p *SourceProvider, blobs func(name string) []byte) {
+	body, err := os.ReadFile("../../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/hyperpic.png", Body: body}))
+
+	if blobs != nil {
+		assert.Equal(t, body, blobs("/products/hyperpic.png"))
+	}
+
+	source, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+	assert.Equal(t, len(body), source.Size)
+	assert.Equal(t, "hyperpic.png", source.Name)
+	assert.Equal(t, "image/png", source.MimeType)
+	assert.False(t, source.ModifiedAt.IsZero())
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/my kayak (1).png", Body: body}))
+
+	_, err = p.Get(&image.Resource{Path: "/products/my kayak (1).png"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Del(&image.Resource{Path: "/products/hyperpic.png"}))
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, ErrInvalidPath, p.Set(&image.Resource{Path: "/../foo.png"}))
+	assert.Equal(t, ErrInvalidPath, p.Del(&image.Resource{Path: "/../foo.png"}))
+}
+
+func TestSourceProviderWithSharedKey(t *testing.T) {
+	k, err := newSharedKey(azuriteAccount, azuriteKey)
+	assert.NoError(t, err)
+
+	fake := newFakeBlobService(k, "")
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Endpoint:  server.URL + "/" + azuriteAccount,
+		Account:   azuriteAccount,
+		Key:       azuriteKey,
+		Container: "images",
+		Prefix:    "sources",
+	})
+	assert.NoError(t, err)
+
+	testSourceProvider(t, p, func(name string) []byte {
+		return fake.blobs["/"+azuriteAccount+"/images/sources"+name].body
+	})
+
+	// the deleted blob is kept by the soft delete
+	assert.True(t, fake.blobs["/"+azuriteAccount+"/images/sources/products/hyperpic.png"].deleted)
+
+	p.sharedKey.key = []byte("other")
+
+	_, err = p.Get(&image.Resource{Path: "/products/my kayak (1).png"})
+	assert.EqualError(t, err, "azure: AuthenticationFailed: AuthenticationFailed")
+}
+
+func TestSourceProviderWithSAS(t *testing.T) {
+	fake := newFakeBlobService(nil, "signature")
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Endpoint:  server.URL + "/" + azuriteAccount,
+		SAS:       "sv=2021-08-06&ss=b&srt=o&sp=rwd&sig=signature",
+		Container: "images",
+	})
+	assert.NoError(t, err)
+
+	testSourceProvider(t, p, nil)
+}
+
+func TestSourceProviderBlockUpload(t *testing.T) {
+	k, err := newSharedKey(azuriteAccount, azuriteKey)
+	assert.NoError(t, err)
+
+	fake := newFakeBlobService(k, "")
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Endpoint:  server.URL + "/" + azuriteAccount,
+		Account:   azuriteAccount,
+		Key:       azuriteKey,
+		Container: "images",
+		BlockSize: 1024,
+	})
+	assert.NoError(t, err)
+
+	body := bytes.Repeat([]byte("0123456789"), 250)
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/large.jpg", Body: body}))
+	assert.Equal(t, []string{"PUT block", "PUT block", "PUT block", "PUT blocklist"}, fake.requests)
+
+	source, err := p.Get(&image.Resource{Path: "/large.jpg"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+	assert.Equal(t, "text/plain; charset=utf-8", source.MimeType)
+}
+
+// TestSourceProviderWithAzurite runs against the Azurite emulator when AZURITE_BLOB_ENDPOINT is set,
+// ex: AZURITE_BLOB_ENDPOINT=http://127.0.0.1:10000/devstoreaccount1
+func TestSourceProviderWithAzurite(t *testing.T) {
+	endpoint := os.Getenv("AZURITE_BLOB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("AZURITE_BLOB_ENDPOINT is not set")
+	}
+
+	container := fmt.Sprintf("hyperpic%d", time.Now().UnixNano())
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Endpoint:  endpoint,
+		Account:   azuriteAccount,
+		Key:       azuriteKey,
+		Container: container,
+		Prefix:    "sources",
+		BlockSize: 1024,
+	})
+	assert.NoError(t, err)
+
+	u, err := url.Parse(endpoint + "/" + container)
+	assert.NoError(t, err)
+
+	resp, err := p.do(http.MethodPut, u, url.Values{"restype": {"container"}}, nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	resp.Body.Close()
+
+	testSourceProvider(t, p, nil)
+
+	body := bytes.Repeat([]byte("0123456789"), 250)
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/large.jpg", Body: body}))
+
+	source, err := p.Get(&image.Resource{Path: "/large.jpg"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+}
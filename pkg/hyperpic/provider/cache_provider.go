@@ -16,4 +16,6 @@ type CacheProvider interface {
 	Set(resource *image.Resource) error
 
 	Del(resource *image.Resource) error
+
+	FindDerivative(resource *image.Resource, criteria *image.DerivativeCriteria) (*image.Resource, error)
 }
@@ -5,6 +5,7 @@
 package filesystem
 
 import (
+	"encoding/json"
 	"errors"
 	"io"
 	"os"
@@ -18,6 +19,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// derivativeExt is the extension of the metadata file stored next to a cached image
+const derivativeExt = ".json"
+
 // CacheProvider struct
 type CacheProvider struct {
 	config *CacheConfiguration
@@ -87,9 +91,14 @@ func (p CacheProvider) Get(resource *image.Resource) (*image.Resource, error) {
 		return nil, ErrInvalidPath
 	}
 
-	path := p.config.Path + "/" + strings.TrimPrefix(resource.Path, "/") + "/" + resource.Options.Hash()
+	dir := p.config.Path + "/" + strings.TrimPrefix(resource.Path, "/")
+	path := dir + "/" + resource.Options.Hash()
 
 	f, err := os.Open(path)
+	if os.IsNotExist(err) && p.migrate(dir, resource.Options) {
+		f, err = os.Open(path)
+	}
+
 	if os.IsNotExist(err) {
 		log.Debug().Msgf("File %s is not found in cache", resource.Path)
 
@@ -129,6 +138,29 @@ func (p CacheProvider) Get(resource *image.Resource) (*image.Resource, error) {
 	}, nil
 }
 
+// migrate renames the entry cached with the legacy key of options to the current key,
+// entries which are never requested again are removed by the cleaner.
+func (p CacheProvider) migrate(dir string, options *image.Options) bool {
+	legacy := options.LegacyHash()
+	if legacy == "" {
+		return false
+	}
+
+	path := dir + "/" + options.Hash()
+
+	if err := os.Rename(dir+"/"+legacy, path); err != nil {
+		return false
+	}
+
+	log.Debug().Msgf("Migrate cache file %s/%s to %s", dir, legacy, path)
+
+	if err := os.Rename(dir+"/"+legacy+derivativeExt, path+derivativeExt); err != nil && !os.IsNotExist(err) {
+		log.Error().Err(err).Msg("Migrate derivative metadata")
+	}
+
+	return true
+}
+
 // Set file to cache
 func (p CacheProvider) Set(resource *image.Resource) error {
 	path := p.config.Path + "/" + strings.TrimPrefix(resource.Path, "/")
@@ -151,5 +183,98 @@ func (p CacheProvider) Set(resource *image.Resource) error {
 
 	log.Debug().Msgf("Write cache file size: %d", n)
 
-	return nil
+	if resource.Derivative == nil {
+		return nil
+	}
+
+	meta, err := json.Marshal(resource.Derivative)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename+derivativeExt, meta, 0666)
+}
+
+// FindDerivative returns the smallest cached derivative of the source matching criteria
+func (p CacheProvider) FindDerivative(resource *image.Resource, criteria *image.DerivativeCriteria) (*image.Resource, error) {
+	if fsutil.ContainsDotDot(resource.Path) {
+		return nil, ErrInvalidPath
+	}
+
+	path := p.config.Path + "/" + strings.TrimPrefix(resource.Path, "/")
+
+	// the path is not a glob pattern, it can contain [, * or ?
+	entries, err := os.ReadDir(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), derivativeExt) {
+			files = append(files, path+"/"+entry.Name())
+		}
+	}
+
+	var (
+		best     *image.Derivative
+		bestFile string
+	)
+
+	for _, file := range files {
+		meta, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		derivative := &image.Derivative{}
+
+		if err := json.Unmarshal(meta, derivative); err != nil {
+			log.Debug().Err(err).Msgf("Invalid derivative metadata %s", file)
+
+			continue
+		}
+
+		if !criteria.Match(derivative) {
+			continue
+		}
+
+		if best == nil || derivative.Pixels() < best.Pixels() {
+			best = derivative
+			bestFile = strings.TrimSuffix(file, derivativeExt)
+		}
+	}
+
+	if best == nil {
+		return nil, ErrCacheNotExist
+	}
+
+	f, err := os.Open(bestFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	_, name := filepath.Split(resource.Path)
+
+	return &image.Resource{
+		Path:       resource.Path,
+		Name:       name,
+		Options:    resource.Options,
+		Body:       body,
+		Size:       len(body),
+		ModifiedAt: d.ModTime(),
+		Derivative: best,
+	}, nil
 }
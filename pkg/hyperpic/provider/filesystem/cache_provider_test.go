@@ -105,3 +105,165 @@ func TestCacheProvider(t *testing.T) {
 
 	time.Sleep(100 * time.Millisecond)
 }
+
+func TestCacheProviderFindDerivative(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-provider-test")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	p := NewCacheProvider(&CacheConfiguration{
+		Path:          dir,
+		LifeTime:      30 * time.Second,
+		CleanInterval: 35 * time.Second,
+	})
+
+	body, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	derivatives := []struct {
+		options    *image.Options
+		derivative *image.Derivative
+	}{
+		{
+			options:    &image.Options{Width: 1600},
+			derivative: &image.Derivative{Width: 1600, Height: 1200, Quality: 90},
+		},
+		{
+			options:    &image.Options{Width: 800},
+			derivative: &image.Derivative{Width: 800, Height: 600, Quality: 90},
+		},
+		{
+			options:    &image.Options{Width: 600, Height: 600, Fit: image.FitCropCenter},
+			derivative: &image.Derivative{Width: 600, Height: 600, Quality: 90, Destructive: true},
+		},
+		{
+			options: &image.Options{Width: 200},
+		},
+	}
+
+	for _, d := range derivatives {
+		err = p.Set(&image.Resource{
+			Path:       "/kayaks.jpg",
+			Body:       body,
+			Size:       len(body),
+			Options:    d.options,
+			Derivative: d.derivative,
+		})
+		assert.NoError(t, err)
+	}
+
+	res, err := p.FindDerivative(&image.Resource{Path: "/.."}, &image.DerivativeCriteria{})
+	assert.Error(t, err)
+	assert.Nil(t, res)
+
+	res, err = p.FindDerivative(&image.Resource{Path: "/kayaks.jpg"}, &image.DerivativeCriteria{
+		Width:         400,
+		Height:        400,
+		MinQuality:    85,
+		MaxGeneration: 1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, body, res.Body)
+	assert.Equal(t, &image.Derivative{Width: 800, Height: 600, Quality: 90}, res.Derivative)
+
+	res, err = p.FindDerivative(&image.Resource{Path: "/kayaks.jpg"}, &image.DerivativeCriteria{
+		Width:         1000,
+		MinQuality:    85,
+		MaxGeneration: 1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &image.Derivative{Width: 1600, Height: 1200, Quality: 90}, res.Derivative)
+
+	res, err = p.FindDerivative(&image.Resource{Path: "/kayaks.jpg"}, &image.DerivativeCriteria{
+		Width:         2000,
+		MinQuality:    85,
+		MaxGeneration: 1,
+	})
+	assert.Error(t, err)
+	assert.Nil(t, res)
+
+	res, err = p.FindDerivative(&image.Resource{Path: "/not-found.jpg"}, &image.DerivativeCriteria{
+		Width:         100,
+		MaxGeneration: 1,
+	})
+	assert.Error(t, err)
+	assert.Nil(t, res)
+
+	// the glob characters of the path are not patterns
+	err = p.Set(&image.Resource{
+		Path:       "/kayaks[1]*?.jpg",
+		Body:       body,
+		Size:       len(body),
+		Options:    &image.Options{Width: 800},
+		Derivative: &image.Derivative{Width: 800, Height: 600, Quality: 90},
+	})
+	assert.NoError(t, err)
+
+	res, err = p.FindDerivative(&image.Resource{Path: "/kayaks[1]*?.jpg"}, &image.DerivativeCriteria{
+		Width:         400,
+		MinQuality:    85,
+		MaxGeneration: 1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &image.Derivative{Width: 800, Height: 600, Quality: 90}, res.Derivative)
+}
+
+func TestCacheProviderMigrateLegacyKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-provider-test")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	p := NewCacheProvider(&CacheConfiguration{
+		Path:          dir,
+		LifeTime:      1 * time.Hour,
+		CleanInterval: 1 * time.Hour,
+	})
+
+	options := &image.Options{
+		Width:  200,
+		Height: 200,
+	}
+
+	assert.NoError(t, os.MkdirAll(dir+"/kayaks.jpg", os.ModePerm))
+	assert.NoError(t, os.WriteFile(dir+"/kayaks.jpg/"+options.LegacyHash(), []byte("legacy"), 0666))
+	assert.NoError(t, os.WriteFile(dir+"/kayaks.jpg/"+options.LegacyHash()+derivativeExt, []byte("{}"), 0666))
+
+	res, err := p.Get(&image.Resource{
+		Path: "/kayaks.jpg",
+		Options: &image.Options{
+			Width:  200,
+			Height: 200,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("legacy"), res.Body)
+
+	// the equivalent options share the migrated entry
+	res, err = p.Get(&image.Resource{
+		Path: "/kayaks.jpg",
+		Options: &image.Options{
+			Width:  100,
+			Height: 100,
+			DPR:    2,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("legacy"), res.Body)
+
+	assert.FileExists(t, dir+"/kayaks.jpg/"+options.Hash())
+	assert.FileExists(t, dir+"/kayaks.jpg/"+options.Hash()+derivativeExt)
+	assert.NoFileExists(t, dir+"/kayaks.jpg/"+options.LegacyHash())
+
+	// legacy keys ignored the crop zone
+	_, err = p.Get(&image.Resource{
+		Path: "/kayaks.jpg",
+		Options: &image.Options{
+			Width:  200,
+			Height: 200,
+			Crop:   image.CropType{Width: 10, Height: 10},
+		},
+	})
+	assert.Equal(t, ErrCacheNotExist, err)
+}
@@ -54,8 +54,8 @@ func (p SourceProvider) Set(resource *image.Resource) error {
 	return nil
 }
 
-// Get resource from file system
-func (p SourceProvider) Get(resource *image.Resource) (*image.Resource, error) {
+// Open resource from file system without reading it
+func (p SourceProvider) Open(resource *image.Resource) (*image.Resource, io.ReadCloser, error) {
 	if fsutil.ContainsDotDot(resource.Path) {
 		// Too many programs use r.URL.Path to construct the argument to
 		// serveFile. Reject the request under the assumption that happened
@@ -63,31 +63,29 @@ func (p SourceProvider) Get(resource *image.Resource) (*image.Resource, error) {
 		// Note that name might not contain "..", for example if code (still
 		// incorrectly) used filepath.Join(myDir, r.URL.Path).
 
-		return nil, ErrInvalidPath
+		return nil, nil, ErrInvalidPath
 	}
 
 	path := p.path + "/" + strings.TrimPrefix(resource.Path, "/")
 
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer f.Close()
 
 	d, err := f.Stat()
 	if err != nil {
-		return nil, err
+		f.Close()
+
+		return nil, nil, err
 	}
 
 	if d.IsDir() {
-		log.Debug().Msgf("%s is not a file", resource.Path)
+		f.Close()
 
-		return nil, ErrNotFile
-	}
+		log.Debug().Msgf("%s is not a file", resource.Path)
 
-	body, err := io.ReadAll(f)
-	if err != nil {
-		return nil, err
+		return nil, nil, ErrNotFile
 	}
 
 	_, name := filepath.Split(resource.Path)
@@ -96,10 +94,28 @@ func (p SourceProvider) Get(resource *image.Resource) (*image.Resource, error) {
 		Path:       resource.Path,
 		Options:    resource.Options,
 		Name:       name,
-		Body:       body,
-		Size:       len(body),
+		Size:       int(d.Size()),
 		ModifiedAt: d.ModTime(),
-	}, nil
+	}, f, nil
+}
+
+// Get resource from file system
+func (p SourceProvider) Get(resource *image.Resource) (*image.Resource, error) {
+	source, f, err := p.Open(resource)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	body, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	source.Body = body
+	source.Size = len(body)
+
+	return source, nil
 }
 
 // Del source files
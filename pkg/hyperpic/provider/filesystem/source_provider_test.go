@@ -75,4 +75,30 @@ func TestSourceProvider(t *testing.T) {
 	assert.Equal(t, "", res.MimeType)
 	assert.Equal(t, "test.jpg", res.Name)
 	assert.Equal(t, "/test.jpg", res.Path)
+
+	source, rc, err := p.Open(&image.Resource{
+		Path: "/test.jpg",
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, rc)
+	assert.Nil(t, source.Body)
+	assert.Equal(t, len(body), source.Size)
+	assert.Equal(t, "test.jpg", source.Name)
+
+	data, err := ioutil.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, body, data)
+	assert.NoError(t, rc.Close())
+
+	_, rc, err = p.Open(&image.Resource{
+		Path: "/",
+	})
+	assert.Equal(t, ErrNotFile, err)
+	assert.Nil(t, rc)
+
+	_, rc, err = p.Open(&image.Resource{
+		Path: "/../../",
+	})
+	assert.Equal(t, ErrInvalidPath, err)
+	assert.Nil(t, rc)
 }
@@ -0,0 +1,23 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import "time"
+
+// SourceConfiguration struct
+type SourceConfiguration struct {
+	// Endpoint of the storage api, https://storage.googleapis.com if empty
+	Endpoint string
+	Bucket   string
+	// Prefix of the object names
+	Prefix string
+	// CredentialsFile is the service account JSON key, defaults to the GOOGLE_APPLICATION_CREDENTIALS env variable
+	CredentialsFile string `mapstructure:"credentials_file"`
+	// AccessToken is used instead of the service account, defaults to the GOOGLE_OAUTH_ACCESS_TOKEN env variable
+	AccessToken string `mapstructure:"access_token"`
+	// ChunkSize of the resumable uploads, a multiple of 256KB
+	ChunkSize int64 `mapstructure:"chunk_size"`
+	Timeout   time.Duration
+}
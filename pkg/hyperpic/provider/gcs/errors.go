@@ -0,0 +1,49 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// gcs errors
+var (
+	ErrInvalidPath = errors.New("invalid URL path")
+	ErrNoSession   = errors.New("gcs: the resumable upload session url is missing")
+)
+
+// Error returned by the storage api
+type Error struct {
+	StatusCode int    `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("gcs: http status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("gcs: %d: %s", e.StatusCode, e.Message)
+}
+
+// readError decodes the error of the response body
+func readError(status int, body io.Reader) error {
+	resp := struct {
+		Error *Error `json:"error"`
+	}{}
+
+	data, _ := io.ReadAll(io.LimitReader(body, 1<<16))
+
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Error == nil {
+		return &Error{StatusCode: status}
+	}
+
+	resp.Error.StatusCode = status
+
+	return resp.Error
+}
@@ -0,0 +1,407 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/fsutil"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultEndpoint of the storage api
+const defaultEndpoint = "https://storage.googleapis.com"
+
+// the chunks of a resumable upload must be a multiple of 256KB, except the last one
+const (
+	chunkAlign       = 256 << 10
+	defaultChunkSize = 8 << 20
+)
+
+// maxUploadRetries is the number of times an upload is resumed after a failed chunk
+const maxUploadRetries = 3
+
+// statusResumeIncomplete is returned by the resumable upload while the object is not complete
+const statusResumeIncomplete = http.StatusPermanentRedirect
+
+// SourceProvider stores the sources as objects in a Google Cloud Storage bucket
+type SourceProvider struct {
+	cfg       *SourceConfiguration
+	endpoint  string
+	chunkSize int64
+	token     tokenSource
+	client    *http.Client
+}
+
+// NewSourceProvider func
+func NewSourceProvider(cfg *SourceConfiguration) (*SourceProvider, error) {
+	p := &SourceProvider{
+		cfg:       cfg,
+		endpoint:  strings.TrimSuffix(cfg.Endpoint, "/"),
+		chunkSize: cfg.ChunkSize,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+
+	if p.endpoint == "" {
+		p.endpoint = defaultEndpoint
+	}
+
+	if p.chunkSize <= 0 {
+		p.chunkSize = defaultChunkSize
+	}
+
+	if rem := p.chunkSize % chunkAlign; rem != 0 {
+		p.chunkSize += chunkAlign - rem
+	}
+
+	accessToken := cfg.AccessToken
+	credentialsFile := cfg.CredentialsFile
+
+	if accessToken == "" && credentialsFile == "" {
+		accessToken = os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+		credentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	switch {
+	case accessToken != "":
+		p.token = staticToken(accessToken)
+	case credentialsFile != "":
+		ts, err := loadServiceAccount(credentialsFile, p.client)
+		if err != nil {
+			return nil, err
+		}
+
+		p.token = ts
+	default:
+		// anonymous requests, for the public buckets and the emulators
+		log.Warn().Msg("gcs: no credentials, the requests are anonymous")
+	}
+
+	return p, nil
+}
+
+// objectName returns the name of the object of the resource path
+func (p SourceProvider) objectName(resourcePath string) (string, error) {
+	if fsutil.ContainsDotDot(resourcePath) {
+		return "", ErrInvalidPath
+	}
+
+	return strings.TrimPrefix(path.Join(p.cfg.Prefix, resourcePath), "/"), nil
+}
+
+// objectURL returns the url of the object in the JSON api, the slashes of the name are escaped
+func (p SourceProvider) objectURL(name string) (*url.URL, error) {
+	u, err := url.Parse(p.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := u.Path + "/storage/v1/b/" + p.cfg.Bucket + "/o/"
+
+	u.Path = prefix + name
+	u.RawPath = prefix + url.PathEscape(name)
+
+	return u, nil
+}
+
+// do authorizes and sends the request, the errors of the api are returned as *Error
+func (p SourceProvider) do(method string, u *url.URL, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	// the request url is parsed again, the escaped path must be kept
+	req.URL = u
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if p.token != nil {
+		token, err := p.token.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+
+		return nil, readError(resp.StatusCode, resp.Body)
+	}
+
+	return resp, nil
+}
+
+// generationTime returns the time of the object generation, the generation is the
+// write time in microseconds: unlike Last-Modified it changes on each overwrite,
+// even in the same second.
+func generationTime(header http.Header) (time.Time, bool) {
+	generation, err := strconv.ParseInt(header.Get("X-Goog-Generation"), 10, 64)
+	if err != nil || generation <= 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, generation*int64(time.Microsecond)).UTC(), true
+}
+
+// Open resource from the bucket without reading it
+func (p SourceProvider) Open(resource *image.Resource) (*image.Resource, io.ReadCloser, error) {
+	name, err := p.objectName(resource.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u, err := p.objectURL(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.RawQuery = url.Values{"alt": {"media"}}.Encode()
+
+	resp, err := p.do(http.MethodGet, u, nil, nil)
+	if err != nil {
+		if e, ok := err.(*Error); ok && e.StatusCode == http.StatusNotFound {
+			return nil, nil, &os.PathError{Op: "open", Path: resource.Path, Err: os.ErrNotExist}
+		}
+
+		return nil, nil, err
+	}
+
+	source := &image.Resource{
+		Path:     resource.Path,
+		Options:  resource.Options,
+		Name:     path.Base(resource.Path),
+		Size:     int(resp.ContentLength),
+		MimeType: resp.Header.Get("Content-Type"),
+	}
+
+	if modifiedAt, ok := generationTime(resp.Header); ok {
+		source.ModifiedAt = modifiedAt
+	} else if modifiedAt, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		source.ModifiedAt = modifiedAt
+	}
+
+	return source, resp.Body, nil
+}
+
+// Get resource from the bucket
+func (p SourceProvider) Get(resource *image.Resource) (*image.Resource, error) {
+	source, body, err := p.Open(resource)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	source.Body = data
+	source.Size = len(data)
+
+	return source, nil
+}
+
+// Set resource to the bucket with a resumable upload, the body is sent in chunks and
+// a failed chunk is resumed from the offset persisted by the service
+func (p SourceProvider) Set(resource *image.Resource) error {
+	name, err := p.objectName(resource.Path)
+	if err != nil {
+		return err
+	}
+
+	body := resource.Body
+	total := int64(len(body))
+
+	session, err := p.startUpload(name, http.DetectContentType(body), total)
+	if err != nil {
+		return err
+	}
+
+	offset := int64(0)
+	chunks := 0
+
+	for retries := 0; ; {
+		end := offset + p.chunkSize
+		if end > total {
+			end = total
+		}
+
+		next, done, err := p.putChunk(session, body[offset:end], offset, total)
+		if err != nil {
+			if retries >= maxUploadRetries || !isRetryable(err) {
+				p.cancelUpload(session)
+
+				return err
+			}
+
+			retries++
+
+			log.Warn().Err(err).Msgf("gcs: resume the upload of %s", name)
+
+			// the chunk is sent again from the offset persisted by the service
+			next, done, err = p.putChunk(session, nil, -1, total)
+			if err != nil {
+				p.cancelUpload(session)
+
+				return err
+			}
+		} else {
+			chunks++
+		}
+
+		if done {
+			break
+		}
+
+		offset = next
+	}
+
+	log.Debug().Msgf("Write source object in %d chunks", chunks)
+
+	return nil
+}
+
+// startUpload initiates a resumable upload and returns the session url
+func (p SourceProvider) startUpload(name string, contentType string, size int64) (*url.URL, error) {
+	u, err := url.Parse(p.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path += "/upload/storage/v1/b/" + p.cfg.Bucket + "/o"
+	u.RawQuery = url.Values{
+		"uploadType": {"resumable"},
+		"name":       {name},
+	}.Encode()
+
+	metadata, err := json.Marshal(map[string]string{
+		"name":        name,
+		"contentType": contentType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=UTF-8")
+	header.Set("X-Upload-Content-Type", contentType)
+	header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+
+	resp, err := p.do(http.MethodPost, u, metadata, header)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return nil, ErrNoSession
+	}
+
+	return u.Parse(location)
+}
+
+// putChunk sends the chunk at offset and returns the offset of the next chunk, done is true
+// when the object is complete. A nil chunk with a negative offset queries the upload status.
+func (p SourceProvider) putChunk(session *url.URL, chunk []byte, offset int64, total int64) (int64, bool, error) {
+	header := http.Header{}
+
+	if len(chunk) == 0 {
+		header.Set("Content-Range", fmt.Sprintf("bytes */%d", total))
+	} else {
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(chunk))-1, total))
+	}
+
+	u := *session
+
+	resp, err := p.do(http.MethodPut, &u, chunk, header)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != statusResumeIncomplete {
+		return total, true, nil
+	}
+
+	// the Range header is missing when no byte has been persisted
+	persisted := resp.Header.Get("Range")
+	if persisted == "" {
+		return 0, false, nil
+	}
+
+	var first, last int64
+
+	if _, err := fmt.Sscanf(persisted, "bytes=%d-%d", &first, &last); err != nil {
+		return 0, false, fmt.Errorf("gcs: invalid upload range %q", persisted)
+	}
+
+	return last + 1, false, nil
+}
+
+// cancelUpload deletes the upload session, the persisted chunks are discarded
+func (p SourceProvider) cancelUpload(session *url.URL) {
+	u := *session
+
+	// the service answers 499 to a cancelled session
+	if resp, err := p.do(http.MethodDelete, &u, nil, nil); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// isRetryable returns true if the chunk can be sent again
+func isRetryable(err error) bool {
+	e, ok := err.(*Error)
+	if !ok {
+		return true
+	}
+
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Del source object, the noncurrent version is kept when versioning is enabled on the bucket
+func (p SourceProvider) Del(resource *image.Resource) error {
+	name, err := p.objectName(resource.Path)
+	if err != nil {
+		return err
+	}
+
+	u, err := p.objectURL(name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.do(http.MethodDelete, u, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
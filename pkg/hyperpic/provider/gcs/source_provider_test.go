@@ -0,0 +1,452 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/stretchr/testify/assert"
+)
+
+type object struct {
+	body        []byte
+	contentType string
+	generation  int64
+}
+
+type session struct {
+	name        string
+	contentType string
+	size        int64
+	body        []byte
+}
+
+// fakeGCS is a stand-in of the JSON api with the resumable uploads,
+// the chunks listed in fail are rejected once with a 503
+type fakeGCS struct {
+	mtx        sync.Mutex
+	url        string
+	token      string
+	generation int64
+	objects    map[string]*object
+	sessions   map[string]*session
+	fail       map[int]bool
+	chunks     int
+	requests   []string
+}
+
+func newFakeGCS(token string) *fakeGCS {
+	return &fakeGCS{
+		token:      token,
+		generation: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).UnixNano() / int64(time.Microsecond),
+		objects:    map[string]*object{},
+		sessions:   map[string]*session{},
+		fail:       map[int]bool{},
+	}
+}
+
+func (f *fakeGCS) error(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	fmt.Fprintf(w, `{"error":{"code":%d,"message":%q}}`, status, message)
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.token != "" && r.Header.Get("Authorization") != "Bearer "+f.token {
+		f.error(w, http.StatusUnauthorized, "Invalid Credentials")
+
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	query := r.URL.Query()
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/bucket/o"):
+		f.serveUpload(w, r, query, body)
+	case strings.HasPrefix(r.URL.EscapedPath(), "/storage/v1/b/bucket/o/"):
+		name, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/storage/v1/b/bucket/o/"))
+		if err != nil || strings.Contains(strings.TrimPrefix(r.URL.RawPath, "/storage/v1/b/bucket/o/"), "/") {
+			f.error(w, http.StatusBadRequest, "Invalid object name")
+
+			return
+		}
+
+		f.requests = append(f.requests, r.Method+" object")
+
+		o, ok := f.objects[name]
+		if !ok {
+			f.error(w, http.StatusNotFound, "No such object: bucket/"+name)
+
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			if query.Get("alt") != "media" {
+				f.error(w, http.StatusBadRequest, "alt=media is expected")
+
+				return
+			}
+
+			w.Header().Set("Content-Type", o.contentType)
+			w.Header().Set("X-Goog-Generation", strconv.FormatInt(o.generation, 10))
+			w.Header().Set("Last-Modified", time.Unix(0, o.generation*int64(time.Microsecond)).UTC().Format(http.TimeFormat))
+			w.Write(o.body)
+		case http.MethodDelete:
+			delete(f.objects, name)
+
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		f.error(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (f *fakeGCS) serveUpload(w http.ResponseWriter, r *http.Request, query url.Values, body []byte) {
+	if r.Method == http.MethodPost {
+		if query.Get("uploadType") != "resumable" {
+			f.error(w, http.StatusBadRequest, "uploadType=resumable is expected")
+
+			return
+		}
+
+		metadata := map[string]string{}
+		json.Unmarshal(body, &metadata)
+
+		size, _ := strconv.ParseInt(r.Header.Get("X-Upload-Content-Length"), 10, 64)
+
+		id := strconv.Itoa(len(f.sessions) + 1)
+
+		f.sessions[id] = &session{
+			name:        query.Get("name"),
+			contentType: metadata["contentType"],
+			size:        size,
+		}
+
+		f.requests = append(f.requests, "POST upload")
+
+		w.Header().Set("Location", f.url+"/upload/storage/v1/b/bucket/o?uploadType=resumable&upload_id="+id)
+
+		return
+	}
+
+	s, ok := f.sessions[query.Get("upload_id")]
+	if !ok {
+		f.error(w, http.StatusNotFound, "No such upload")
+
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		delete(f.sessions, query.Get("upload_id"))
+		f.requests = append(f.requests, "DELETE upload")
+
+		w.WriteHeader(499)
+
+		return
+	}
+
+	contentRange := r.Header.Get("Content-Range")
+	f.requests = append(f.requests, "PUT "+contentRange)
+
+	if !strings.HasPrefix(contentRange, "bytes */") {
+		var first, last, total int64
+
+		if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &first, &last, &total); err != nil || first != int64(len(s.body)) || last-first+1 != int64(len(body)) {
+			f.error(w, http.StatusBadRequest, "Invalid Content-Range")
+
+			return
+		}
+
+		chunk := f.chunks
+		f.chunks++
+
+		if f.fail[chunk] {
+			delete(f.fail, chunk)
+
+			f.error(w, http.StatusServiceUnavailable, "Backend Error")
+
+			return
+		}
+
+		s.body = append(s.body, body...)
+	}
+
+	if int64(len(s.body)) < s.size {
+		if len(s.body) > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(s.body)-1))
+		}
+
+		w.WriteHeader(http.StatusPermanentRedirect)
+
+		return
+	}
+
+	f.generation++
+
+	f.objects[s.name] = &object{
+		body:        s.body,
+		contentType: s.contentType,
+		generation:  f.generation,
+	}
+
+	delete(f.sessions, query.Get("upload_id"))
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"name":%q,"generation":"%d"}`, s.name, f.generation)
+}
+
+func newTestServer(t *testing.T, fake *fakeGCS) *httptest.Server {
+	server := httptest.NewServer(fake)
+
+	fake.url = server.URL
+
+	return server
+}
+
+func TestNewSourceProvider(t *testing.T) {
+	p, err := NewSourceProvider(&SourceConfiguration{Bucket: "images", Prefix: "sources", AccessToken: "token"})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultEndpoint, p.endpoint)
+	assert.Equal(t, int64(defaultChunkSize), p.chunkSize)
+	assert.Equal(t, staticToken("token"), p.token)
+
+	name, err := p.objectName("/products/my kayak.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, "sources/products/my kayak.jpg", name)
+
+	u, err := p.objectURL(name)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://storage.googleapis.com/storage/v1/b/images/o/sources%2Fproducts%2Fmy%20kayak.jpg", u.String())
+
+	_, err = p.objectName("/../kayaks.jpg")
+	assert.Equal(t, ErrInvalidPath, err)
+
+	// the chunk size is rounded to a multiple of 256KB
+	p, err = NewSourceProvider(&SourceConfiguration{ChunkSize: 300 << 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(512<<10), p.chunkSize)
+	assert.Nil(t, p.token)
+
+	_, err = NewSourceProvider(&SourceConfiguration{CredentialsFile: "/path/to/missing.json"})
+	assert.True(t, os.IsNotExist(err))
+
+	os.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "envtoken")
+	defer os.Unsetenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+
+	p, err = NewSourceProvider(&SourceConfiguration{})
+	assert.NoError(t, err)
+	assert.Equal(t, staticToken("envtoken"), p.token)
+}
+
+func testSourceProvider(t *testing.T, p *SourceProvider, objects func(name string) []byte) {
+	body, err := os.ReadFile("../../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/hyperpic.png", Body: body}))
+
+	if objects != nil {
+		assert.Equal(t, body, objects("products/hyperpic.png"))
+	}
+
+	source, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+	assert.Equal(t, len(body), source.Size)
+	assert.Equal(t, "hyperpic.png", source.Name)
+	assert.Equal(t, "image/png", source.MimeType)
+	assert.False(t, source.ModifiedAt.IsZero())
+
+	// an overwrite in the same second is a new generation
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/hyperpic.png", Body: body}))
+
+	overwritten, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.True(t, overwritten.ModifiedAt.After(source.ModifiedAt))
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/my kayak (1).png", Body: body}))
+
+	_, err = p.Get(&image.Resource{Path: "/products/my kayak (1).png"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/empty.png", Body: []byte{}}))
+
+	source, err = p.Get(&image.Resource{Path: "/empty.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, source.Size)
+
+	assert.NoError(t, p.Del(&image.Resource{Path: "/products/hyperpic.png"}))
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, ErrInvalidPath, p.Set(&image.Resource{Path: "/../foo.png"}))
+	assert.Equal(t, ErrInvalidPath, p.Del(&image.Resource{Path: "/../foo.png"}))
+}
+
+func TestSourceProviderWithAccessToken(t *testing.T) {
+	fake := newFakeGCS("token")
+
+	server := newTestServer(t, fake)
+	defer server.Close()
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Endpoint:    server.URL,
+		Bucket:      "bucket",
+		AccessToken: "token",
+	})
+	assert.NoError(t, err)
+
+	testSourceProvider(t, p, func(name string) []byte {
+		return fake.objects[name].body
+	})
+
+	source, err := p.Get(&image.Resource{Path: "/products/my kayak (1).png"})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(0, fake.objects["products/my kayak (1).png"].generation*int64(time.Microsecond)).UTC(), source.ModifiedAt)
+
+	p.token = staticToken("other")
+
+	_, err = p.Get(&image.Resource{Path: "/products/my kayak (1).png"})
+	assert.EqualError(t, err, "gcs: 401: Invalid Credentials")
+}
+
+func TestSourceProviderWithServiceAccount(t *testing.T) {
+	tokens := &fakeTokenService{}
+
+	tokenServer := httptest.NewServer(tokens)
+	defer tokenServer.Close()
+
+	credentials, key := writeCredentials(t, tokenServer.URL+"/token")
+
+	tokens.key = &key.PublicKey
+
+	fake := newFakeGCS("ya29.test")
+
+	server := newTestServer(t, fake)
+	defer server.Close()
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Endpoint:        server.URL,
+		Bucket:          "bucket",
+		Prefix:          "sources",
+		CredentialsFile: credentials,
+	})
+	assert.NoError(t, err)
+
+	testSourceProvider(t, p, func(name string) []byte {
+		return fake.objects["sources/"+name].body
+	})
+
+	assert.Equal(t, 1, tokens.requests)
+}
+
+func TestSourceProviderResumableUpload(t *testing.T) {
+	fake := newFakeGCS("")
+
+	server := newTestServer(t, fake)
+	defer server.Close()
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Endpoint:  server.URL,
+		Bucket:    "bucket",
+		ChunkSize: chunkAlign,
+	})
+	assert.NoError(t, err)
+
+	body := bytes.Repeat([]byte("0123456789"), 60000)
+
+	fake.fail[1] = true
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/large.jpg", Body: body}))
+	assert.Equal(t, []string{
+		"POST upload",
+		"PUT bytes 0-262143/600000",
+		"PUT bytes 262144-524287/600000",
+		"PUT bytes */600000",
+		"PUT bytes 262144-524287/600000",
+		"PUT bytes 524288-599999/600000",
+	}, fake.requests)
+
+	source, err := p.Get(&image.Resource{Path: "/large.jpg"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+	assert.Equal(t, "text/plain; charset=utf-8", source.MimeType)
+
+	// the session is cancelled when the upload can not be resumed
+	fake.requests = nil
+	fake.chunks = 0
+
+	for i := 0; i <= maxUploadRetries; i++ {
+		fake.fail[i] = true
+	}
+
+	assert.EqualError(t, p.Set(&image.Resource{Path: "/failed.jpg", Body: body}), "gcs: 503: Backend Error")
+	assert.Equal(t, "DELETE upload", fake.requests[len(fake.requests)-1])
+	assert.Empty(t, fake.sessions)
+
+	_, err = p.Get(&image.Resource{Path: "/failed.jpg"})
+	assert.True(t, os.IsNotExist(err))
+}
+
+// TestSourceProviderWithFakeGCSServer runs against fake-gcs-server when FAKE_GCS_ENDPOINT is set,
+// ex: FAKE_GCS_ENDPOINT=http://127.0.0.1:4443 with fake-gcs-server -scheme http
+func TestSourceProviderWithFakeGCSServer(t *testing.T) {
+	endpoint := os.Getenv("FAKE_GCS_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("FAKE_GCS_ENDPOINT is not set")
+	}
+
+	bucket := fmt.Sprintf("hyperpic%d", time.Now().UnixNano())
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Endpoint:  endpoint,
+		Bucket:    bucket,
+		Prefix:    "sources",
+		ChunkSize: chunkAlign,
+	})
+	assert.NoError(t, err)
+
+	u, err := url.Parse(endpoint + "/storage/v1/b?project=hyperpic")
+	assert.NoError(t, err)
+
+	resp, err := p.do(http.MethodPost, u, []byte(fmt.Sprintf(`{"name":%q}`, bucket)), http.Header{"Content-Type": {"application/json"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	resp.Body.Close()
+
+	testSourceProvider(t, p, nil)
+
+	body := bytes.Repeat([]byte("0123456789"), 60000)
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/large.jpg", Body: body}))
+
+	source, err := p.Get(&image.Resource{Path: "/large.jpg"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+}
@@ -0,0 +1,188 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scope of the access tokens
+const scopeReadWrite = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// tokenSource returns the access token of the requests
+type tokenSource interface {
+	Token() (string, error)
+}
+
+type staticToken string
+
+func (t staticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+// serviceAccount is the JSON key of a service account
+type serviceAccount struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// serviceAccountToken exchanges a JWT signed with the key of the service account
+// for an access token, the token is cached until it expires
+type serviceAccountToken struct {
+	mtx     sync.Mutex
+	account *serviceAccount
+	key     *rsa.PrivateKey
+	client  *http.Client
+	now     func() time.Time
+	token   string
+	expires time.Time
+}
+
+func loadServiceAccount(path string, client *http.Client) (*serviceAccountToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &serviceAccount{}
+
+	if err := json.Unmarshal(data, account); err != nil {
+		return nil, fmt.Errorf("gcs: invalid credentials file: %w", err)
+	}
+
+	if account.Type != "service_account" {
+		return nil, fmt.Errorf("gcs: credentials type %q is not supported", account.Type)
+	}
+
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, errors.New("gcs: invalid private key")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("gcs: invalid private key: %w", err)
+		}
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("gcs: the private key is not a RSA key")
+	}
+
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	return &serviceAccountToken{
+		account: account,
+		key:     key,
+		client:  client,
+		now:     time.Now,
+	}, nil
+}
+
+// assertion returns the JWT signed with the key of the service account
+func (s *serviceAccountToken) assertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"kid": s.account.PrivateKeyID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   s.account.ClientEmail,
+		"scope": scopeReadWrite,
+		"aud":   s.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(payload))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Token returns the cached access token, a new token is requested a minute before it expires
+func (s *serviceAccountToken) Token() (string, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	now := s.now()
+
+	if s.token != "" && now.Add(time.Minute).Before(s.expires) {
+		return s.token, nil
+	}
+
+	assertion, err := s.assertion(now)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.client.PostForm(s.account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gcs: token request failed with status %d", resp.StatusCode)
+	}
+
+	token := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	if token.AccessToken == "" || !strings.EqualFold(token.TokenType, "Bearer") {
+		return "", errors.New("gcs: invalid token response")
+	}
+
+	s.token = token.AccessToken
+	s.expires = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	return s.token, nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTokenService verifies the JWT assertions signed with the service account key
+type fakeTokenService struct {
+	mtx      sync.Mutex
+	key      *rsa.PublicKey
+	requests int
+	claims   map[string]interface{}
+}
+
+func (f *fakeTokenService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.requests++
+
+	if r.PostFormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	parts := strings.Split(r.PostFormValue("assertion"), ".")
+	if len(parts) != 3 {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	if err := rsa.VerifyPKCS1v15(f.key, crypto.SHA256, hash[:], signature); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+
+	f.claims = map[string]interface{}{}
+	json.Unmarshal(claims, &f.claims)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"access_token":"ya29.test","expires_in":3600,"token_type":"Bearer"}`))
+}
+
+// writeCredentials writes a service account JSON key with a new RSA key
+func writeCredentials(t *testing.T, tokenURI string) (string, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "hyperpic@project.iam.gserviceaccount.com",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":      tokenURI,
+	})
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+
+	assert.NoError(t, os.WriteFile(path, data, 0600))
+
+	return path, key
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	fake := &fakeTokenService{}
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	path, key := writeCredentials(t, server.URL+"/token")
+
+	fake.key = &key.PublicKey
+
+	ts, err := loadServiceAccount(path, http.DefaultClient)
+	assert.NoError(t, err)
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	ts.now = func() time.Time {
+		return now
+	}
+
+	token, err := ts.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "ya29.test", token)
+	assert.Equal(t, "hyperpic@project.iam.gserviceaccount.com", fake.claims["iss"])
+	assert.Equal(t, scopeReadWrite, fake.claims["scope"])
+	assert.Equal(t, server.URL+"/token", fake.claims["aud"])
+	assert.Equal(t, float64(now.Unix()), fake.claims["iat"])
+
+	// the token is cached until a minute before it expires
+	now = now.Add(58 * time.Minute)
+
+	_, err = ts.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.requests)
+
+	now = now.Add(time.Minute)
+
+	_, err = ts.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.requests)
+
+	// signed with another key
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	fake.key = &other.PublicKey
+	now = now.Add(2 * time.Hour)
+
+	_, err = ts.Token()
+	assert.EqualError(t, err, "gcs: token request failed with status 401")
+}
+
+func TestLoadServiceAccountErrors(t *testing.T) {
+	_, err := loadServiceAccount(filepath.Join(t.TempDir(), "missing.json"), http.DefaultClient)
+	assert.True(t, os.IsNotExist(err))
+
+	dir := t.TempDir()
+
+	write := func(content string) string {
+		path := filepath.Join(dir, "credentials.json")
+
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+		return path
+	}
+
+	_, err = loadServiceAccount(write("{"), http.DefaultClient)
+	assert.Error(t, err)
+
+	_, err = loadServiceAccount(write(`{"type":"authorized_user"}`), http.DefaultClient)
+	assert.EqualError(t, err, `gcs: credentials type "authorized_user" is not supported`)
+
+	_, err = loadServiceAccount(write(`{"type":"service_account","private_key":"foo"}`), http.DefaultClient)
+	assert.EqualError(t, err, "gcs: invalid private key")
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(map[string]string{
+		"type":        "service_account",
+		"private_key": string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+	})
+	assert.NoError(t, err)
+
+	ts, err := loadServiceAccount(write(string(data)), http.DefaultClient)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://oauth2.googleapis.com/token", ts.account.TokenURI)
+}
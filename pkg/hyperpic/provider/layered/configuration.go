@@ -0,0 +1,29 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package layered
+
+// Read strategies
+const (
+	// ReadFirstHit returns the source of the first layer having it
+	ReadFirstHit = "first-hit"
+	// ReadThrough copies the source found in a lower layer into the primary layer
+	ReadThrough = "read-through"
+)
+
+// Write strategies
+const (
+	// WritePrimary writes the sources in the primary layer only
+	WritePrimary = "primary"
+	// WriteAll writes the sources in every layer
+	WriteAll = "all"
+)
+
+// SourceConfiguration struct
+type SourceConfiguration struct {
+	// Layers are the names of the source providers, the first one is the primary
+	Layers []string
+	Read   string
+	Write  string
+}
@@ -0,0 +1,14 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package layered
+
+import "errors"
+
+// layered errors
+var (
+	ErrNoLayer       = errors.New("layered: at least one layer is required")
+	ErrReadStrategy  = errors.New("layered: the read strategy must be first-hit or read-through")
+	ErrWriteStrategy = errors.New("layered: the write strategy must be primary or all")
+)
@@ -0,0 +1,223 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package layered
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+	"github.com/rs/zerolog/log"
+)
+
+// SourceProvider reads the sources from an ordered list of providers, the first one is the primary
+type SourceProvider struct {
+	layers []provider.SourceProvider
+	read   string
+	write  string
+}
+
+// NewSourceProvider func
+func NewSourceProvider(cfg *SourceConfiguration, layers ...provider.SourceProvider) (*SourceProvider, error) {
+	if len(layers) == 0 {
+		return nil, ErrNoLayer
+	}
+
+	p := &SourceProvider{
+		layers: layers,
+		read:   cfg.Read,
+		write:  cfg.Write,
+	}
+
+	if p.read == "" {
+		p.read = ReadFirstHit
+	}
+
+	if p.write == "" {
+		p.write = WritePrimary
+	}
+
+	if p.read != ReadFirstHit && p.read != ReadThrough {
+		return nil, ErrReadStrategy
+	}
+
+	if p.write != WritePrimary && p.write != WriteAll {
+		return nil, ErrWriteStrategy
+	}
+
+	return p, nil
+}
+
+// notFound returns the error of a resource missing in every layer
+func notFound(resource *image.Resource) error {
+	return &os.PathError{Op: "open", Path: resource.Path, Err: os.ErrNotExist}
+}
+
+// isReadOnly returns true if the layer can not store the sources
+func isReadOnly(layer provider.SourceProvider) bool {
+	ro, ok := layer.(provider.ReadOnlySourceProvider)
+
+	return ok && ro.ReadOnly()
+}
+
+// copyToPrimary writes the source found in a lower layer into the primary layer,
+// a failed copy is logged and the source is still served
+func (p SourceProvider) copyToPrimary(source *image.Resource) {
+	if isReadOnly(p.layers[0]) {
+		return
+	}
+
+	if err := p.layers[0].Set(&image.Resource{
+		Path:       source.Path,
+		Name:       source.Name,
+		MimeType:   source.MimeType,
+		ModifiedAt: source.ModifiedAt,
+		Body:       source.Body,
+		Size:       source.Size,
+	}); err != nil {
+		log.Error().Err(err).Msgf("Copy source %s to the primary layer", source.Path)
+
+		return
+	}
+
+	log.Debug().Msgf("Copy source %s to the primary layer", source.Path)
+}
+
+// Get resource from the first layer having it, a 404 is only returned when every layer misses
+func (p SourceProvider) Get(resource *image.Resource) (*image.Resource, error) {
+	var failure error
+
+	for i, layer := range p.layers {
+		source, err := layer.Get(resource)
+		if err == nil {
+			if i > 0 && p.read == ReadThrough {
+				p.copyToPrimary(source)
+			}
+
+			return source, nil
+		}
+
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Msgf("Source layer %d", i)
+
+			failure = err
+		}
+	}
+
+	if failure != nil {
+		return nil, failure
+	}
+
+	return nil, notFound(resource)
+}
+
+// Open resource from the first layer having it without reading it, the layers
+// without stream support and the read-through copies are read in memory
+func (p SourceProvider) Open(resource *image.Resource) (*image.Resource, io.ReadCloser, error) {
+	var failure error
+
+	for i, layer := range p.layers {
+		var (
+			source *image.Resource
+			body   io.ReadCloser
+			err    error
+		)
+
+		if sp, ok := layer.(provider.StreamSourceProvider); ok && (i == 0 || p.read == ReadFirstHit) {
+			source, body, err = sp.Open(resource)
+		} else if source, err = layer.Get(resource); err == nil {
+			if i > 0 && p.read == ReadThrough {
+				p.copyToPrimary(source)
+			}
+
+			body = io.NopCloser(bytes.NewReader(source.Body))
+			source.Body = nil
+		}
+
+		if err == nil {
+			return source, body, nil
+		}
+
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Msgf("Source layer %d", i)
+
+			failure = err
+		}
+	}
+
+	if failure != nil {
+		return nil, nil, failure
+	}
+
+	return nil, nil, notFound(resource)
+}
+
+// Set resource to the primary layer, or to every writable layer with the all write strategy
+func (p SourceProvider) Set(resource *image.Resource) error {
+	if p.write == WritePrimary {
+		return p.layers[0].Set(resource)
+	}
+
+	var failure error
+
+	for i, layer := range p.layers {
+		if i > 0 && isReadOnly(layer) {
+			continue
+		}
+
+		if err := layer.Set(resource); err != nil {
+			log.Error().Err(err).Msgf("Write source layer %d", i)
+
+			if failure == nil {
+				failure = err
+			}
+		}
+	}
+
+	return failure
+}
+
+// Del resource from every layer, otherwise a lower layer would serve it again. The
+// read-only layers forget their copies but their error is ignored.
+func (p SourceProvider) Del(resource *image.Resource) error {
+	var failure error
+
+	deleted := false
+
+	for i, layer := range p.layers {
+		err := layer.Del(resource)
+		if isReadOnly(layer) {
+			continue
+		}
+
+		if err == nil {
+			deleted = true
+
+			continue
+		}
+
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		log.Error().Err(err).Msgf("Delete source layer %d", i)
+
+		if failure == nil {
+			failure = err
+		}
+	}
+
+	if failure != nil {
+		return failure
+	}
+
+	if !deleted {
+		return notFound(resource)
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package layered
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/filesystem"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/origin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newLayers returns a primary and a legacy filesystem layers, the legacy one has hyperpic.png
+func newLayers(t *testing.T) (string, string, []byte) {
+	body, err := os.ReadFile("../../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	primary := t.TempDir()
+	legacy := t.TempDir()
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(legacy, "products"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(legacy, "products", "hyperpic.png"), body, 0644))
+
+	return primary, legacy, body
+}
+
+func newFilesystemProvider(path string) provider.SourceProvider {
+	return filesystem.NewSourceProvider(&filesystem.SourceConfiguration{Path: path})
+}
+
+func TestNewSourceProvider(t *testing.T) {
+	_, err := NewSourceProvider(&SourceConfiguration{})
+	assert.Equal(t, ErrNoLayer, err)
+
+	layer := &provider.MockSourceProvider{}
+
+	_, err = NewSourceProvider(&SourceConfiguration{Read: "last-hit"}, layer)
+	assert.Equal(t, ErrReadStrategy, err)
+
+	_, err = NewSourceProvider(&SourceConfiguration{Write: "secondary"}, layer)
+	assert.Equal(t, ErrWriteStrategy, err)
+
+	p, err := NewSourceProvider(&SourceConfiguration{}, layer)
+	assert.NoError(t, err)
+	assert.Equal(t, ReadFirstHit, p.read)
+	assert.Equal(t, WritePrimary, p.write)
+}
+
+func TestSourceProviderFirstHit(t *testing.T) {
+	primary, legacy, body := newLayers(t)
+
+	p, err := NewSourceProvider(&SourceConfiguration{Read: ReadFirstHit}, newFilesystemProvider(primary), newFilesystemProvider(legacy))
+	assert.NoError(t, err)
+
+	source, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+
+	// not copied
+	_, err = os.Stat(filepath.Join(primary, "products", "hyperpic.png"))
+	assert.True(t, os.IsNotExist(err))
+
+	source, stream, err := p.Open(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, len(body), source.Size)
+
+	data, err := io.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Close())
+	assert.Equal(t, body, data)
+
+	_, err = p.Get(&image.Resource{Path: "/missing.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	_, _, err = p.Open(&image.Resource{Path: "/missing.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	// the primary layer has priority
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/hyperpic.png", Body: body[:10]}))
+
+	source, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, body[:10], source.Body)
+
+	legacyBody, err := os.ReadFile(filepath.Join(legacy, "products", "hyperpic.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, body, legacyBody)
+
+	// deleted from every layer
+	assert.NoError(t, p.Del(&image.Resource{Path: "/products/hyperpic.png"}))
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	assert.True(t, os.IsNotExist(p.Del(&image.Resource{Path: "/products/hyperpic.png"})))
+}
+
+func TestSourceProviderReadThrough(t *testing.T) {
+	primary, legacy, body := newLayers(t)
+
+	p, err := NewSourceProvider(&SourceConfiguration{Read: ReadThrough}, newFilesystemProvider(primary), newFilesystemProvider(legacy))
+	assert.NoError(t, err)
+
+	source, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+
+	copied, err := os.ReadFile(filepath.Join(primary, "products", "hyperpic.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, body, copied)
+
+	assert.NoError(t, os.Remove(filepath.Join(primary, "products", "hyperpic.png")))
+
+	source, stream, err := p.Open(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, len(body), source.Size)
+	assert.Nil(t, source.Body)
+
+	data, err := io.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, body, data)
+
+	copied, err = os.ReadFile(filepath.Join(primary, "products", "hyperpic.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, body, copied)
+}
+
+func TestSourceProviderReadThroughCopyFailure(t *testing.T) {
+	_, legacy, body := newLayers(t)
+
+	primary := &provider.MockSourceProvider{}
+	primary.On("Get", mock.Anything).Return(nil, os.ErrNotExist)
+	primary.On("Set", mock.Anything).Return(errors.New("read-only"))
+
+	p, err := NewSourceProvider(&SourceConfiguration{Read: ReadThrough}, primary, newFilesystemProvider(legacy))
+	assert.NoError(t, err)
+
+	// the source is served even if the copy failed
+	source, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+
+	primary.AssertExpectations(t)
+}
+
+func TestSourceProviderLayerFailure(t *testing.T) {
+	_, legacy, body := newLayers(t)
+
+	failure := errors.New("connection refused")
+
+	primary := &provider.MockSourceProvider{}
+	primary.On("Get", mock.Anything).Return(nil, failure)
+
+	p, err := NewSourceProvider(&SourceConfiguration{}, primary, newFilesystemProvider(legacy))
+	assert.NoError(t, err)
+
+	// the lower layer is used when the primary fails
+	source, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+
+	// the failure is returned instead of a 404
+	_, err = p.Get(&image.Resource{Path: "/missing.png"})
+	assert.Equal(t, failure, err)
+
+	_, _, err = p.Open(&image.Resource{Path: "/missing.png"})
+	assert.Equal(t, failure, err)
+}
+
+func TestSourceProviderWriteAll(t *testing.T) {
+	primary, legacy, body := newLayers(t)
+
+	p, err := NewSourceProvider(&SourceConfiguration{Write: WriteAll}, newFilesystemProvider(primary), newFilesystemProvider(legacy))
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/kayak.png", Body: body}))
+
+	for _, dir := range []string{primary, legacy} {
+		data, err := os.ReadFile(filepath.Join(dir, "kayak.png"))
+		assert.NoError(t, err)
+		assert.Equal(t, body, data)
+	}
+
+	failure := errors.New("read-only")
+
+	broken := &provider.MockSourceProvider{}
+	broken.On("Set", mock.Anything).Return(failure)
+	broken.On("Del", mock.Anything).Return(failure)
+
+	p, err = NewSourceProvider(&SourceConfiguration{Write: WriteAll}, newFilesystemProvider(primary), broken)
+	assert.NoError(t, err)
+
+	// written in the other layers
+	assert.Equal(t, failure, p.Set(&image.Resource{Path: "/other.png", Body: body}))
+
+	_, err = os.Stat(filepath.Join(primary, "other.png"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, failure, p.Del(&image.Resource{Path: "/other.png"}))
+
+	_, err = os.Stat(filepath.Join(primary, "other.png"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSourceProviderReadOnlyLayer(t *testing.T) {
+	primary, _, body := newLayers(t)
+
+	remote, err := origin.NewSourceProvider(&origin.SourceConfiguration{
+		Origins: []string{"http://127.0.0.1:1"},
+	})
+	assert.NoError(t, err)
+
+	for _, write := range []string{WritePrimary, WriteAll} {
+		p, err := NewSourceProvider(&SourceConfiguration{Write: write}, newFilesystemProvider(primary), remote)
+		assert.NoError(t, err)
+
+		// the read-only layer is skipped
+		assert.NoError(t, p.Set(&image.Resource{Path: "/kayak.png", Body: body}), write)
+
+		_, err = os.Stat(filepath.Join(primary, "kayak.png"))
+		assert.NoError(t, err, write)
+
+		assert.NoError(t, p.Del(&image.Resource{Path: "/kayak.png"}), write)
+
+		_, err = os.Stat(filepath.Join(primary, "kayak.png"))
+		assert.True(t, os.IsNotExist(err), write)
+
+		err = p.Del(&image.Resource{Path: "/kayak.png"})
+		assert.True(t, os.IsNotExist(err), write)
+	}
+}
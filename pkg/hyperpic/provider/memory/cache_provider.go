@@ -168,6 +168,7 @@ func (p *CacheProvider) Set(resource *image.Resource) error {
 		Body:       resource.Body,
 		Size:       resource.Size,
 		ModifiedAt: time.Now(),
+		Derivative: resource.Derivative,
 	}
 
 	p.container[path][key] = res
@@ -178,3 +179,41 @@ func (p *CacheProvider) Set(resource *image.Resource) error {
 
 	return nil
 }
+
+// FindDerivative returns the smallest cached derivative of the source matching criteria
+func (p *CacheProvider) FindDerivative(resource *image.Resource, criteria *image.DerivativeCriteria) (*image.Resource, error) {
+	if fsutil.ContainsDotDot(resource.Path) {
+		return nil, ErrInvalidPath
+	}
+
+	path := strings.TrimPrefix(resource.Path, "/")
+
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	var best *image.Resource
+
+	for _, file := range p.container[path] {
+		if !criteria.Match(file.Derivative) {
+			continue
+		}
+
+		if best == nil || file.Derivative.Pixels() < best.Derivative.Pixels() {
+			best = file
+		}
+	}
+
+	if best == nil {
+		return nil, ErrCacheNotExist
+	}
+
+	return &image.Resource{
+		Path:       best.Path,
+		Name:       best.Name,
+		Options:    resource.Options,
+		Body:       best.Body,
+		Size:       best.Size,
+		ModifiedAt: best.ModifiedAt,
+		Derivative: best.Derivative,
+	}, nil
+}
@@ -129,6 +129,86 @@ func TestCacheProvider(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 }
 
+func TestCacheProviderFindDerivative(t *testing.T) {
+	p := NewCacheProvider(&CacheConfiguration{
+		LifeTime:      30 * time.Second,
+		CleanInterval: 35 * time.Second,
+		MemoryLimit:   10 << 20,
+	})
+
+	body, err := os.ReadFile("../../../../_resources/demo/kayaks.jpg")
+	assert.NoError(t, err)
+
+	derivatives := []struct {
+		options    *image.Options
+		derivative *image.Derivative
+	}{
+		{
+			options:    &image.Options{Width: 1600},
+			derivative: &image.Derivative{Width: 1600, Height: 1200, Quality: 90},
+		},
+		{
+			options:    &image.Options{Width: 800},
+			derivative: &image.Derivative{Width: 800, Height: 600, Quality: 90},
+		},
+		{
+			options:    &image.Options{Width: 600, Height: 600, Fit: image.FitCropCenter},
+			derivative: &image.Derivative{Width: 600, Height: 600, Quality: 90, Destructive: true},
+		},
+		{
+			options: &image.Options{Width: 200},
+		},
+	}
+
+	for _, d := range derivatives {
+		err = p.Set(&image.Resource{
+			Path:       "/kayaks.jpg",
+			Body:       body,
+			Size:       len(body),
+			Options:    d.options,
+			Derivative: d.derivative,
+		})
+		assert.NoError(t, err)
+	}
+
+	res, err := p.FindDerivative(&image.Resource{Path: "/.."}, &image.DerivativeCriteria{})
+	assert.Error(t, err)
+	assert.Nil(t, res)
+
+	res, err = p.FindDerivative(&image.Resource{Path: "/kayaks.jpg"}, &image.DerivativeCriteria{
+		Width:         400,
+		Height:        400,
+		MinQuality:    85,
+		MaxGeneration: 1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, body, res.Body)
+	assert.Equal(t, &image.Derivative{Width: 800, Height: 600, Quality: 90}, res.Derivative)
+
+	res, err = p.FindDerivative(&image.Resource{Path: "/kayaks.jpg"}, &image.DerivativeCriteria{
+		Width:         1000,
+		MinQuality:    85,
+		MaxGeneration: 1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &image.Derivative{Width: 1600, Height: 1200, Quality: 90}, res.Derivative)
+
+	res, err = p.FindDerivative(&image.Resource{Path: "/kayaks.jpg"}, &image.DerivativeCriteria{
+		Width:         2000,
+		MinQuality:    85,
+		MaxGeneration: 1,
+	})
+	assert.Error(t, err)
+	assert.Nil(t, res)
+
+	res, err = p.FindDerivative(&image.Resource{Path: "/not-found.jpg"}, &image.DerivativeCriteria{
+		Width:         100,
+		MaxGeneration: 1,
+	})
+	assert.Error(t, err)
+	assert.Nil(t, res)
+}
+
 func BenchmarkCacheProviderSet(b *testing.B) {
 	zerolog.SetGlobalLevel(zerolog.Disabled)
 
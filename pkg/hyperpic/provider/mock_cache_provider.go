@@ -24,6 +24,29 @@ func (_m *MockCacheProvider) Del(resource *image.Resource) error {
 	return r0
 }
 
+// FindDerivative provides a mock function with given fields: resource, criteria
+func (_m *MockCacheProvider) FindDerivative(resource *image.Resource, criteria *image.DerivativeCriteria) (*image.Resource, error) {
+	ret := _m.Called(resource, criteria)
+
+	var r0 *image.Resource
+	if rf, ok := ret.Get(0).(func(*image.Resource, *image.DerivativeCriteria) *image.Resource); ok {
+		r0 = rf(resource, criteria)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*image.Resource)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*image.Resource, *image.DerivativeCriteria) error); ok {
+		r1 = rf(resource, criteria)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Get provides a mock function with given fields: resource
 func (_m *MockCacheProvider) Get(resource *image.Resource) (*image.Resource, error) {
 	ret := _m.Called(resource)
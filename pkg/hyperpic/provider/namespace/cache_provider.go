@@ -0,0 +1,71 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package namespace
+
+import (
+	"strings"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider"
+)
+
+// CacheProvider stores the resources under a namespace of another cache provider,
+// the resources of two namespaces never collide
+type CacheProvider struct {
+	namespace string
+	cache     provider.CacheProvider
+}
+
+// NewCacheProvider constructor
+func NewCacheProvider(namespace string, cache provider.CacheProvider) *CacheProvider {
+	return &CacheProvider{
+		namespace: "/" + strings.Trim(namespace, "/"),
+		cache:     cache,
+	}
+}
+
+// scoped returns a copy of the resource with the path in the namespace
+func (p CacheProvider) scoped(resource *image.Resource) *image.Resource {
+	scoped := *resource
+
+	scoped.Path = p.namespace + "/" + strings.TrimPrefix(resource.Path, "/")
+
+	return &scoped
+}
+
+// unscoped restores the path of the resource returned by the cache
+func unscoped(found *image.Resource, resource *image.Resource, err error) (*image.Resource, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	found.Path = resource.Path
+
+	return found, nil
+}
+
+// Get resource from the namespace
+func (p CacheProvider) Get(resource *image.Resource) (*image.Resource, error) {
+	found, err := p.cache.Get(p.scoped(resource))
+
+	return unscoped(found, resource, err)
+}
+
+// Set resource in the namespace
+func (p CacheProvider) Set(resource *image.Resource) error {
+	return p.cache.Set(p.scoped(resource))
+}
+
+// Del resource from the namespace
+func (p CacheProvider) Del(resource *image.Resource) error {
+	return p.cache.Del(p.scoped(resource))
+}
+
+// FindDerivative in the namespace
+func (p CacheProvider) FindDerivative(resource *image.Resource, criteria *image.DerivativeCriteria) (*image.Resource, error) {
+	found, err := p.cache.FindDerivative(p.scoped(resource), criteria)
+
+	return unscoped(found, resource, err)
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package namespace
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/provider/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheProvider(t *testing.T) {
+	cache := memory.NewCacheProvider(&memory.CacheConfiguration{
+		LifeTime:      time.Hour,
+		CleanInterval: time.Hour,
+		MemoryLimit:   1 << 20,
+	})
+
+	brandA := NewCacheProvider("brand-a", cache)
+	brandB := NewCacheProvider("/brand-b/", cache)
+
+	options := &image.Options{Width: 200}
+
+	assert.NoError(t, brandA.Set(&image.Resource{
+		Path:    "/kayaks.jpg",
+		Body:    []byte("brand-a"),
+		Size:    7,
+		Options: options,
+		Derivative: &image.Derivative{
+			Width:   200,
+			Height:  100,
+			Quality: 90,
+		},
+	}))
+
+	resource, err := brandA.Get(&image.Resource{Path: "/kayaks.jpg", Options: options})
+	assert.NoError(t, err)
+	assert.Equal(t, "/kayaks.jpg", resource.Path)
+	assert.Equal(t, []byte("brand-a"), resource.Body)
+
+	// isolated from the other namespaces
+	_, err = brandB.Get(&image.Resource{Path: "/kayaks.jpg", Options: options})
+	assert.Error(t, err)
+
+	_, err = cache.Get(&image.Resource{Path: "/kayaks.jpg", Options: options})
+	assert.Error(t, err)
+
+	resource, err = cache.Get(&image.Resource{Path: "/brand-a/kayaks.jpg", Options: options})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("brand-a"), resource.Body)
+
+	criteria := &image.DerivativeCriteria{Width: 100, MinQuality: 85, MaxGeneration: 1}
+
+	resource, err = brandA.FindDerivative(&image.Resource{Path: "/kayaks.jpg"}, criteria)
+	assert.NoError(t, err)
+	assert.Equal(t, "/kayaks.jpg", resource.Path)
+
+	_, err = brandB.FindDerivative(&image.Resource{Path: "/kayaks.jpg"}, criteria)
+	assert.Error(t, err)
+
+	assert.NoError(t, brandA.Del(&image.Resource{Path: "/kayaks.jpg"}))
+
+	_, err = brandA.Get(&image.Resource{Path: "/kayaks.jpg", Options: options})
+	assert.Error(t, err)
+}
+
+func TestCacheProviderSharedWithTheDefaultNamespace(t *testing.T) {
+	cache := memory.NewCacheProvider(&memory.CacheConfiguration{
+		LifeTime:      time.Hour,
+		CleanInterval: time.Hour,
+		MemoryLimit:   1 << 20,
+	})
+
+	fallback := NewCacheProvider("_default", cache)
+	products := NewCacheProvider("products", cache)
+
+	options := &image.Options{Width: 200}
+
+	// the same cache path without the namespace of the default mount
+	assert.NoError(t, fallback.Set(&image.Resource{Path: "/products/kayaks.jpg", Body: []byte("default"), Size: 7, Options: options}))
+	assert.NoError(t, products.Set(&image.Resource{Path: "/kayaks.jpg", Body: []byte("products"), Size: 8, Options: options}))
+
+	resource, err := fallback.Get(&image.Resource{Path: "/products/kayaks.jpg", Options: options})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("default"), resource.Body)
+
+	resource, err = products.Get(&image.Resource{Path: "/kayaks.jpg", Options: options})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("products"), resource.Body)
+
+	// a default mount without namespace would read the image of the mount
+	resource, err = cache.Get(&image.Resource{Path: "/products/kayaks.jpg", Options: options})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("products"), resource.Body)
+
+	assert.NoError(t, products.Del(&image.Resource{Path: "/kayaks.jpg", Options: options}))
+
+	_, err = fallback.Get(&image.Resource{Path: "/products/kayaks.jpg", Options: options})
+	assert.NoError(t, err)
+}
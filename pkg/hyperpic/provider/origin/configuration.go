@@ -0,0 +1,23 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package origin
+
+import "time"
+
+// SourceConfiguration struct
+type SourceConfiguration struct {
+	// Origins are the base urls of the request paths, tried in order
+	Origins []string
+	// Hosts allowed in the full urls (/https://host/path), *.example.com for the subdomains
+	Hosts []string
+	// AllowedNetworks are the CIDR of the private networks reachable by the provider
+	AllowedNetworks []string `mapstructure:"allowed_networks"`
+	MaxSize         int64    `mapstructure:"max_size"`
+	MaxRedirects    int      `mapstructure:"max_redirects"`
+	Timeout         time.Duration
+	// CacheSize is the memory used by the sources kept for the conditional revalidation, 0 to disable
+	CacheSize int64  `mapstructure:"cache_size"`
+	UserAgent string `mapstructure:"user_agent"`
+}
@@ -0,0 +1,30 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package origin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// origin errors
+var (
+	ErrInvalidPath      = errors.New("invalid URL path")
+	ErrHostNotAllowed   = errors.New("origin: host is not allowed")
+	ErrForbiddenAddress = errors.New("origin: address is not allowed")
+	ErrTooLarge         = errors.New("origin: body is too large")
+	ErrTooManyRedirects = errors.New("origin: too many redirects")
+	ErrReadOnly         = errors.New("origin: the http source is read-only")
+)
+
+// StatusError is returned when the origin answers with an unexpected status
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("origin: %s returned http status %d", e.URL, e.StatusCode)
+}
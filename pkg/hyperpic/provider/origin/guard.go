@@ -0,0 +1,103 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package origin
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// blockedNetworks are the loopback, private, link-local, shared, reserved and
+// multicast ranges, unreachable from a public origin. The IPv4-compatible, NAT64,
+// 6to4 and Teredo ranges embed an IPv4 address, they are blocked as a whole.
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/96",
+	"64:ff9b::/96",
+	"100::/64",
+	"2001::/32",
+	"2002::/16",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func parseCIDRs(cidrs ...string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("origin: invalid network: %w", err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks, err := parseCIDRs(cidrs...)
+	if err != nil {
+		panic(err)
+	}
+
+	return networks
+}
+
+// guard rejects the connections to the blocked networks, the address is checked
+// after the DNS resolution so a public name can not resolve to a private address
+type guard struct {
+	allowed []*net.IPNet
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check returns ErrForbiddenAddress if the ip is in a blocked network not explicitly allowed
+func (g guard) check(ip net.IP) error {
+	if ip == nil {
+		return ErrForbiddenAddress
+	}
+
+	if containsIP(g.allowed, ip) {
+		return nil
+	}
+
+	if containsIP(blockedNetworks, ip) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// control is the net.Dialer hook called before the connection to the resolved address
+func (g guard) control(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	return g.check(net.ParseIP(host))
+}
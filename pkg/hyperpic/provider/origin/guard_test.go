@@ -0,0 +1,68 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package origin
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuardCheck(t *testing.T) {
+	g := guard{}
+
+	for _, ip := range []string{
+		"127.0.0.1",
+		"10.1.2.3",
+		"172.16.0.1",
+		"172.31.255.255",
+		"192.168.1.1",
+		"169.254.169.254",
+		"100.64.0.1",
+		"0.0.0.0",
+		"224.0.0.1",
+		"::1",
+		"::",
+		"fd00::1",
+		"fe80::1",
+		"::ffff:127.0.0.1",
+		"::ffff:10.0.0.1",
+		"::127.0.0.1",
+		"64:ff9b::a9fe:a9fe",
+		"100::1",
+		// 6to4 of 127.0.0.1
+		"2002:7f00:1::1",
+		// teredo of 10.0.0.1
+		"2001:0:4136:e378:8000:63bf:f5ff:fffe",
+	} {
+		assert.Equal(t, ErrForbiddenAddress, g.check(net.ParseIP(ip)), ip)
+	}
+
+	for _, ip := range []string{
+		"8.8.8.8",
+		"172.32.0.1",
+		"2001:4860:4860::8888",
+	} {
+		assert.NoError(t, g.check(net.ParseIP(ip)), ip)
+	}
+
+	assert.Equal(t, ErrForbiddenAddress, g.check(nil))
+
+	allowed, err := parseCIDRs("10.0.0.0/8")
+	assert.NoError(t, err)
+
+	g = guard{allowed: allowed}
+
+	assert.NoError(t, g.check(net.ParseIP("10.1.2.3")))
+	assert.Equal(t, ErrForbiddenAddress, g.check(net.ParseIP("192.168.1.1")))
+
+	assert.Equal(t, ErrForbiddenAddress, g.control("tcp4", "169.254.169.254:80", nil))
+	assert.NoError(t, g.control("tcp4", "10.0.0.1:80", nil))
+	assert.Error(t, g.control("tcp4", "10.0.0.1", nil))
+
+	_, err = parseCIDRs("10.0.0.0")
+	assert.Error(t, err)
+}
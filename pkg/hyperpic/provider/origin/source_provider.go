@@ -0,0 +1,323 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package origin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/fsutil"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultMaxSize of the fetched sources
+const defaultMaxSize = 10 << 20
+
+type contextKey int
+
+// fullURLKey marks the requests of a full url, their redirects must stay on the allowed hosts
+const fullURLKey contextKey = iota
+
+// errNotFound is returned by fetch when the origin does not have the source
+var errNotFound = errors.New("origin: not found")
+
+// SourceProvider fetches the sources from remote http servers, it is read-only
+type SourceProvider struct {
+	cfg     *SourceConfiguration
+	origins []*url.URL
+	maxSize int64
+	client  *http.Client
+	store   *store
+}
+
+// NewSourceProvider func
+func NewSourceProvider(cfg *SourceConfiguration) (*SourceProvider, error) {
+	allowed, err := parseCIDRs(cfg.AllowedNetworks...)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &SourceProvider{
+		cfg:     cfg,
+		maxSize: cfg.MaxSize,
+		store:   newStore(cfg.CacheSize),
+	}
+
+	if p.maxSize <= 0 {
+		p.maxSize = defaultMaxSize
+	}
+
+	for _, origin := range cfg.Origins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("origin: invalid origin url %q", origin)
+		}
+
+		p.origins = append(p.origins, u)
+	}
+
+	g := guard{allowed: allowed}
+
+	dialer := &net.Dialer{
+		Timeout:   cfg.Timeout,
+		KeepAlive: 30 * time.Second,
+		Control:   g.control,
+	}
+
+	p.client = &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			// the proxy would connect on behalf of the guard
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: cfg.Timeout,
+			MaxIdleConnsPerHost:   8,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: p.checkRedirect,
+	}
+
+	return p, nil
+}
+
+// allowHost returns true if the host matches the allowlist of the full urls
+func (p SourceProvider) allowHost(host string) bool {
+	host = strings.ToLower(host)
+
+	for _, allowed := range p.cfg.Hosts {
+		allowed = strings.ToLower(allowed)
+
+		if strings.HasPrefix(allowed, "*.") {
+			if strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+
+			continue
+		}
+
+		if host == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p SourceProvider) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > p.cfg.MaxRedirects {
+		return ErrTooManyRedirects
+	}
+
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("origin: redirect to %s is not allowed", req.URL.Scheme)
+	}
+
+	if full, _ := req.Context().Value(fullURLKey).(bool); full && !p.allowHost(req.URL.Hostname()) {
+		return ErrHostNotAllowed
+	}
+
+	return nil
+}
+
+// fullURL returns the url of a /https://host/path resource path, the path is
+// cleaned by the router so the double slash may be missing
+func fullURL(resourcePath string) (*url.URL, bool, error) {
+	trimmed := strings.TrimPrefix(resourcePath, "/")
+
+	for _, scheme := range []string{"http", "https"} {
+		if !strings.HasPrefix(trimmed, scheme+":/") {
+			continue
+		}
+
+		u, err := url.Parse(scheme + "://" + strings.TrimLeft(trimmed[len(scheme)+1:], "/"))
+		if err != nil || u.Host == "" {
+			return nil, true, ErrInvalidPath
+		}
+
+		return u, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// targets returns the urls of the resource path
+func (p SourceProvider) targets(resourcePath string) ([]*url.URL, bool, error) {
+	if fsutil.ContainsDotDot(resourcePath) {
+		return nil, false, ErrInvalidPath
+	}
+
+	u, full, err := fullURL(resourcePath)
+	if err != nil {
+		return nil, full, err
+	}
+
+	if full {
+		if !p.allowHost(u.Hostname()) {
+			return nil, full, ErrHostNotAllowed
+		}
+
+		return []*url.URL{u}, full, nil
+	}
+
+	targets := make([]*url.URL, 0, len(p.origins))
+
+	for _, origin := range p.origins {
+		target := *origin
+		target.Path = path.Join("/", origin.Path, resourcePath)
+		target.RawPath = ""
+
+		targets = append(targets, &target)
+	}
+
+	return targets, full, nil
+}
+
+// fetch gets the source, a stored source is revalidated with If-None-Match and If-Modified-Since
+func (p SourceProvider) fetch(ctx context.Context, u *url.URL) (*entry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "image/*")
+
+	if p.cfg.UserAgent != "" {
+		req.Header.Set("User-Agent", p.cfg.UserAgent)
+	}
+
+	stored := p.store.get(u.String())
+	if stored != nil {
+		if stored.etag != "" {
+			req.Header.Set("If-None-Match", stored.etag)
+		}
+
+		if stored.lastModified != "" {
+			req.Header.Set("If-Modified-Since", stored.lastModified)
+		}
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && stored != nil:
+		return stored, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		p.store.del(u.String())
+
+		return nil, errNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, &StatusError{URL: u.String(), StatusCode: resp.StatusCode}
+	}
+
+	if resp.ContentLength > p.maxSize {
+		return nil, ErrTooLarge
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, p.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > p.maxSize {
+		return nil, ErrTooLarge
+	}
+
+	e := &entry{
+		url:          u.String(),
+		body:         body,
+		contentType:  resp.Header.Get("Content-Type"),
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		modifiedAt:   time.Now(),
+	}
+
+	if modifiedAt, err := http.ParseTime(e.lastModified); err == nil {
+		e.modifiedAt = modifiedAt
+	}
+
+	p.store.set(e)
+
+	return e, nil
+}
+
+// Get resource from the first origin having it
+func (p SourceProvider) Get(resource *image.Resource) (*image.Resource, error) {
+	targets, full, err := p.targets(resource.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.WithValue(context.Background(), fullURLKey, full)
+
+	var failure error
+
+	for _, u := range targets {
+		e, err := p.fetch(ctx, u)
+		if err == errNotFound {
+			continue
+		}
+
+		if err != nil {
+			log.Warn().Err(err).Msgf("Fetch source %s", u.Redacted())
+
+			failure = err
+
+			continue
+		}
+
+		return &image.Resource{
+			Path:       resource.Path,
+			Options:    resource.Options,
+			Name:       path.Base(resource.Path),
+			Body:       e.body,
+			Size:       len(e.body),
+			MimeType:   e.contentType,
+			ModifiedAt: e.modifiedAt,
+		}, nil
+	}
+
+	// a 404 is only returned when every origin misses
+	if failure != nil {
+		return nil, failure
+	}
+
+	return nil, &os.PathError{Op: "open", Path: resource.Path, Err: os.ErrNotExist}
+}
+
+// ReadOnly returns true, the origins are read-only
+func (p SourceProvider) ReadOnly() bool {
+	return true
+}
+
+// Set is not supported, the origins are read-only
+func (p SourceProvider) Set(resource *image.Resource) error {
+	return ErrReadOnly
+}
+
+// Del forgets the stored copies of the resource, the origins are read-only
+func (p SourceProvider) Del(resource *image.Resource) error {
+	if targets, _, err := p.targets(resource.Path); err == nil {
+		for _, u := range targets {
+			p.store.del(u.String())
+		}
+	}
+
+	return ErrReadOnly
+}
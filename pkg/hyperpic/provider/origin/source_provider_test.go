@@ -0,0 +1,289 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package origin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOrigin serves the files with their validators and counts the full and conditional responses
+type fakeOrigin struct {
+	mtx         sync.Mutex
+	files       map[string][]byte
+	modified    time.Time
+	full        int
+	notModified int
+}
+
+func (f *fakeOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/redirect/") {
+		http.Redirect(w, r, "/"+strings.TrimPrefix(r.URL.Path, "/redirect/"), http.StatusFound)
+
+		return
+	}
+
+	body, ok := f.files[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	etag := `"` + r.URL.Path + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", f.modified.Format(http.TimeFormat))
+
+	if r.Header.Get("If-None-Match") == etag {
+		f.notModified++
+
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	f.full++
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(body)
+}
+
+func newFakeOrigin(t *testing.T) *fakeOrigin {
+	body, err := os.ReadFile("../../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	return &fakeOrigin{
+		files: map[string][]byte{
+			"/legacy/products/hyperpic.png": body,
+			"/legacy/my kayak.png":          body,
+			"/old/archive.png":              body,
+		},
+		modified: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestNewSourceProvider(t *testing.T) {
+	_, err := NewSourceProvider(&SourceConfiguration{AllowedNetworks: []string{"foo"}})
+	assert.Error(t, err)
+
+	_, err = NewSourceProvider(&SourceConfiguration{Origins: []string{"ftp://example.com"}})
+	assert.EqualError(t, err, `origin: invalid origin url "ftp://example.com"`)
+
+	_, err = NewSourceProvider(&SourceConfiguration{Origins: []string{"/images"}})
+	assert.Error(t, err)
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Origins: []string{"https://legacy.example.com/images", "http://old.example.com"},
+		Hosts:   []string{"cdn.example.com", "*.static.example.com"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(defaultMaxSize), p.maxSize)
+
+	targets, full, err := p.targets("/products/my kayak.jpg")
+	assert.NoError(t, err)
+	assert.False(t, full)
+	assert.Len(t, targets, 2)
+	assert.Equal(t, "https://legacy.example.com/images/products/my%20kayak.jpg", targets[0].String())
+	assert.Equal(t, "http://old.example.com/products/my%20kayak.jpg", targets[1].String())
+
+	for _, resourcePath := range []string{
+		"/https://cdn.example.com/products/kayak.jpg",
+		"/https:/cdn.example.com/products/kayak.jpg",
+	} {
+		targets, full, err = p.targets(resourcePath)
+		assert.NoError(t, err)
+		assert.True(t, full)
+		assert.Equal(t, "https://cdn.example.com/products/kayak.jpg", targets[0].String())
+	}
+
+	targets, _, err = p.targets("/http://a.static.example.com/kayak.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://a.static.example.com/kayak.jpg", targets[0].String())
+
+	_, _, err = p.targets("/https://evil.example.com/kayak.jpg")
+	assert.Equal(t, ErrHostNotAllowed, err)
+
+	_, _, err = p.targets("/https://static.example.com.evil.com/kayak.jpg")
+	assert.Equal(t, ErrHostNotAllowed, err)
+
+	_, _, err = p.targets("/https:/")
+	assert.Equal(t, ErrInvalidPath, err)
+
+	_, _, err = p.targets("/../kayak.jpg")
+	assert.Equal(t, ErrInvalidPath, err)
+}
+
+func TestSourceProviderOrigins(t *testing.T) {
+	fake := newFakeOrigin(t)
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Origins:         []string{server.URL + "/legacy", server.URL + "/old"},
+		AllowedNetworks: []string{"127.0.0.0/8"},
+		CacheSize:       1 << 20,
+		Timeout:         time.Second,
+	})
+	assert.NoError(t, err)
+
+	source, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, fake.files["/legacy/products/hyperpic.png"], source.Body)
+	assert.Equal(t, len(source.Body), source.Size)
+	assert.Equal(t, "hyperpic.png", source.Name)
+	assert.Equal(t, "image/png", source.MimeType)
+	assert.Equal(t, fake.modified, source.ModifiedAt.UTC())
+
+	// revalidated with the ETag
+	source, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, fake.files["/legacy/products/hyperpic.png"], source.Body)
+	assert.Equal(t, 1, fake.full)
+	assert.Equal(t, 1, fake.notModified)
+
+	_, err = p.Get(&image.Resource{Path: "/my kayak.png"})
+	assert.NoError(t, err)
+
+	// the second origin has it
+	_, err = p.Get(&image.Resource{Path: "/archive.png"})
+	assert.NoError(t, err)
+
+	_, err = p.Get(&image.Resource{Path: "/missing.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	// a deleted file is not served from the store
+	delete(fake.files, "/legacy/products/hyperpic.png")
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, ErrReadOnly, p.Set(&image.Resource{Path: "/archive.png"}))
+	assert.Equal(t, ErrReadOnly, p.Del(&image.Resource{Path: "/archive.png"}))
+	assert.Nil(t, p.store.get(server.URL+"/old/archive.png"))
+}
+
+func TestSourceProviderSSRF(t *testing.T) {
+	fake := newFakeOrigin(t)
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Origins: []string{server.URL + "/legacy"},
+		Hosts:   []string{"localhost"},
+		Timeout: time.Second,
+	})
+	assert.NoError(t, err)
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, errors.Is(err, ErrForbiddenAddress))
+
+	// the name is checked after the resolution
+	_, err = p.Get(&image.Resource{Path: "/" + strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/legacy/products/hyperpic.png"})
+	assert.True(t, errors.Is(err, ErrForbiddenAddress))
+
+	assert.Equal(t, 0, fake.full)
+}
+
+func TestSourceProviderFullURL(t *testing.T) {
+	fake := newFakeOrigin(t)
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Hosts:           []string{"127.0.0.1"},
+		AllowedNetworks: []string{"127.0.0.0/8"},
+		MaxRedirects:    1,
+		Timeout:         time.Second,
+	})
+	assert.NoError(t, err)
+
+	source, err := p.Get(&image.Resource{Path: "/" + server.URL + "/legacy/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, fake.files["/legacy/products/hyperpic.png"], source.Body)
+
+	// redirect on the same host
+	_, err = p.Get(&image.Resource{Path: "/" + server.URL + "/redirect/legacy/products/hyperpic.png"})
+	assert.NoError(t, err)
+
+	_, err = p.Get(&image.Resource{Path: "/" + server.URL + "/redirect/redirect/legacy/products/hyperpic.png"})
+	assert.True(t, errors.Is(err, ErrTooManyRedirects))
+
+	// redirect to a host out of the allowlist
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)+"/legacy/products/hyperpic.png", http.StatusFound)
+	}))
+	defer redirect.Close()
+
+	_, err = p.Get(&image.Resource{Path: "/" + redirect.URL + "/kayak.png"})
+	assert.True(t, errors.Is(err, ErrHostNotAllowed))
+
+	_, err = p.Get(&image.Resource{Path: "/https://example.com/kayak.png"})
+	assert.Equal(t, ErrHostNotAllowed, err)
+}
+
+func TestSourceProviderLimits(t *testing.T) {
+	fake := newFakeOrigin(t)
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Origins:         []string{server.URL + "/legacy"},
+		AllowedNetworks: []string{"127.0.0.0/8"},
+		MaxSize:         100,
+		Timeout:         time.Second,
+	})
+	assert.NoError(t, err)
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.Equal(t, ErrTooLarge, err)
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	p, err = NewSourceProvider(&SourceConfiguration{
+		Origins:         []string{slow.URL},
+		AllowedNetworks: []string{"127.0.0.0/8"},
+		Timeout:         50 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	_, err = p.Get(&image.Resource{Path: "/kayak.png"})
+	assert.Error(t, err)
+	assert.False(t, os.IsNotExist(err))
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	p, err = NewSourceProvider(&SourceConfiguration{
+		Origins:         []string{failing.URL},
+		AllowedNetworks: []string{"127.0.0.0/8"},
+		Timeout:         time.Second,
+	})
+	assert.NoError(t, err)
+
+	_, err = p.Get(&image.Resource{Path: "/kayak.png"})
+	assert.EqualError(t, err, "origin: "+failing.URL+"/kayak.png returned http status 500")
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package origin
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entry is a source fetched from an origin with its validators
+type entry struct {
+	url          string
+	body         []byte
+	contentType  string
+	etag         string
+	lastModified string
+	modifiedAt   time.Time
+}
+
+// store keeps the last fetched sources up to size bytes, the least recently used are evicted
+type store struct {
+	mtx     sync.Mutex
+	size    int64
+	used    int64
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+func newStore(size int64) *store {
+	return &store{
+		size:    size,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (s *store) get(key string) *entry {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+
+	s.lru.MoveToFront(elem)
+
+	return elem.Value.(*entry)
+}
+
+// set keeps the entry if it has a validator and fits in the store
+func (s *store) set(e *entry) {
+	if s.size <= 0 || int64(len(e.body)) > s.size || (e.etag == "" && e.lastModified == "") {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.remove(e.url)
+
+	s.entries[e.url] = s.lru.PushFront(e)
+	s.used += int64(len(e.body))
+
+	for s.used > s.size {
+		s.remove(s.lru.Back().Value.(*entry).url)
+	}
+}
+
+func (s *store) del(key string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.remove(key)
+}
+
+func (s *store) remove(key string) {
+	elem, ok := s.entries[key]
+	if !ok {
+		return
+	}
+
+	s.lru.Remove(elem)
+	delete(s.entries, key)
+
+	s.used -= int64(len(elem.Value.(*entry).body))
+}
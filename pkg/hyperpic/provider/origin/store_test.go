@@ -0,0 +1,48 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package origin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	s := newStore(10)
+
+	s.set(&entry{url: "a", body: []byte("aaaa"), etag: `"a"`})
+	s.set(&entry{url: "b", body: []byte("bbbb"), lastModified: "Tue, 02 Jan 2024 03:04:05 GMT"})
+
+	// without validator
+	s.set(&entry{url: "c", body: []byte("c")})
+	assert.Nil(t, s.get("c"))
+
+	// larger than the store
+	s.set(&entry{url: "d", body: []byte("ddddddddddd"), etag: `"d"`})
+	assert.Nil(t, s.get("d"))
+
+	// a is the most recently used, b is evicted
+	assert.NotNil(t, s.get("a"))
+
+	s.set(&entry{url: "e", body: []byte("eeee"), etag: `"e"`})
+	assert.Nil(t, s.get("b"))
+	assert.NotNil(t, s.get("a"))
+	assert.NotNil(t, s.get("e"))
+	assert.Equal(t, int64(8), s.used)
+
+	// replaced
+	s.set(&entry{url: "a", body: []byte("aa"), etag: `"a2"`})
+	assert.Equal(t, `"a2"`, s.get("a").etag)
+	assert.Equal(t, int64(6), s.used)
+
+	s.del("a")
+	assert.Nil(t, s.get("a"))
+	assert.Equal(t, int64(4), s.used)
+
+	disabled := newStore(0)
+	disabled.set(&entry{url: "a", body: []byte("aaaa"), etag: `"a"`})
+	assert.Nil(t, disabled.get("a"))
+}
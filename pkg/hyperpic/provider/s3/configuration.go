@@ -0,0 +1,26 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package s3
+
+import "time"
+
+// SourceConfiguration struct
+type SourceConfiguration struct {
+	// Endpoint of the S3 api, https://s3.<region>.amazonaws.com if empty
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix of the object keys
+	Prefix string
+	// AccessKeyID and SecretAccessKey default to the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY env variables
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	SessionToken    string `mapstructure:"session_token"`
+	// PathStyle addresses the bucket in the path instead of the host, required by most S3 compatible servers
+	PathStyle bool `mapstructure:"path_style"`
+	// PartSize of the multipart uploads, the images larger than a part are uploaded in parts
+	PartSize int64 `mapstructure:"part_size"`
+	Timeout  time.Duration
+}
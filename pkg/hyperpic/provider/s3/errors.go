@@ -0,0 +1,46 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// s3 errors
+var (
+	ErrInvalidPath = errors.New("invalid URL path")
+)
+
+// Error returned by the S3 api
+type Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: http status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// readError decodes the error of the response body
+func readError(status int, body io.Reader) error {
+	e := &Error{
+		StatusCode: status,
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(body, 1<<16))
+
+	// the body of the HEAD requests and of some proxies is empty
+	_ = xml.Unmarshal(data, e)
+
+	return e
+}
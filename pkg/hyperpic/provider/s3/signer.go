@@ -0,0 +1,165 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWS signature version 4
+const (
+	signAlgorithm = "AWS4-HMAC-SHA256"
+	signService   = "s3"
+	amzDateFormat = "20060102T150405Z"
+	emptyHash     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+func hashSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// uriEncode encodes the string as required by the canonical request: all the bytes
+// except the unreserved characters, the slash is kept in the object keys
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// canonicalQuery returns the query sorted by key then value
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+
+	params := make([]string, 0, len(query))
+
+	for key, values := range query {
+		for _, value := range values {
+			params = append(params, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// canonicalHeaders returns the signed headers: the host, the range, the content type and md5 and the x-amz-* headers
+func canonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+
+	for key, values := range req.Header {
+		key = strings.ToLower(key)
+
+		if strings.HasPrefix(key, "x-amz-") || key == "range" || key == "content-type" || key == "content-md5" {
+			headers[key] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	keys := make([]string, 0, len(headers))
+
+	for key := range headers {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var b strings.Builder
+
+	for _, key := range keys {
+		b.WriteString(key + ":" + headers[key] + "\n")
+	}
+
+	return b.String(), strings.Join(keys, ";")
+}
+
+type signer struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	region          string
+}
+
+// sign adds the authorization header of the request, the escaped path is the canonical uri
+func (s signer) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+
+	amzDate := now.Format(amzDateFormat)
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/" + signService + "/aws4_request"
+
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		amzDate,
+		scope,
+		hashSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, signService)
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signAlgorithm,
+		s.accessKeyID,
+		scope,
+		signedHeaders,
+		signature,
+	))
+}
@@ -0,0 +1,334 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/fsutil"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/rs/zerolog/log"
+)
+
+// minPartSize is the minimum size of the parts of a multipart upload, except the last one
+const minPartSize = 5 << 20
+
+// SourceProvider stores the sources in a S3 bucket
+type SourceProvider struct {
+	cfg    *SourceConfiguration
+	client *http.Client
+	signer signer
+	now    func() time.Time
+}
+
+// NewSourceProvider func
+func NewSourceProvider(cfg *SourceConfiguration) *SourceProvider {
+	s := signer{
+		accessKeyID:     cfg.AccessKeyID,
+		secretAccessKey: cfg.SecretAccessKey,
+		sessionToken:    cfg.SessionToken,
+		region:          cfg.Region,
+	}
+
+	if s.accessKeyID == "" {
+		s.accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		s.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+
+	return &SourceProvider{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		signer: s,
+		now:    time.Now,
+	}
+}
+
+// objectURL returns the url of the object of the resource path
+func (p SourceProvider) objectURL(resourcePath string) (*url.URL, error) {
+	if fsutil.ContainsDotDot(resourcePath) {
+		return nil, ErrInvalidPath
+	}
+
+	endpoint := p.cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + p.cfg.Region + ".amazonaws.com"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	key := strings.TrimPrefix(path.Join(p.cfg.Prefix, resourcePath), "/")
+
+	if p.cfg.PathStyle {
+		u.Path = "/" + p.cfg.Bucket + "/" + key
+	} else {
+		u.Host = p.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+
+	u.RawPath = uriEncode(u.Path, false)
+
+	return u, nil
+}
+
+// do signs and sends the request, the errors of the api are returned as *Error
+func (p SourceProvider) do(method string, u *url.URL, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	target := *u
+	target.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	// the request url is parsed again, the escaped path must be kept
+	req.URL = &target
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	payloadHash := emptyHash
+	if len(body) > 0 {
+		payloadHash = hashSHA256(body)
+	}
+
+	p.signer.sign(req, payloadHash, p.now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+
+		return nil, readError(resp.StatusCode, resp.Body)
+	}
+
+	return resp, nil
+}
+
+// etagTime returns the last modified date with the ETag folded into its sub-second part:
+// Last-Modified has a one second resolution, the ETag changes on each overwrite with a
+// different content, even in the same second.
+func etagTime(header http.Header) (time.Time, bool) {
+	modifiedAt, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	if etag := header.Get("ETag"); etag != "" {
+		h := fnv.New32a()
+		_, _ = io.WriteString(h, etag)
+
+		modifiedAt = modifiedAt.Add(time.Duration(h.Sum32() % uint32(time.Second)))
+	}
+
+	return modifiedAt, true
+}
+
+// Open resource from the bucket without reading it
+func (p SourceProvider) Open(resource *image.Resource) (*image.Resource, io.ReadCloser, error) {
+	u, err := p.objectURL(resource.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := p.do(http.MethodGet, u, nil, nil, nil)
+	if err != nil {
+		if e, ok := err.(*Error); ok && e.StatusCode == http.StatusNotFound {
+			return nil, nil, &os.PathError{Op: "open", Path: resource.Path, Err: os.ErrNotExist}
+		}
+
+		return nil, nil, err
+	}
+
+	source := &image.Resource{
+		Path:     resource.Path,
+		Options:  resource.Options,
+		Name:     path.Base(resource.Path),
+		Size:     int(resp.ContentLength),
+		MimeType: resp.Header.Get("Content-Type"),
+	}
+
+	// the modified date changes on each write, the cache is invalidated when the source is replaced
+	if modifiedAt, ok := etagTime(resp.Header); ok {
+		source.ModifiedAt = modifiedAt
+	}
+
+	log.Debug().Str("etag", resp.Header.Get("ETag")).Msgf("Open source object %s", u.Path)
+
+	return source, resp.Body, nil
+}
+
+// Get resource from the bucket
+func (p SourceProvider) Get(resource *image.Resource) (*image.Resource, error) {
+	source, body, err := p.Open(resource)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	source.Body = data
+	source.Size = len(data)
+
+	return source, nil
+}
+
+// Set resource to the bucket, the images larger than the part size are uploaded in parts
+func (p SourceProvider) Set(resource *image.Resource) error {
+	u, err := p.objectURL(resource.Path)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", http.DetectContentType(resource.Body))
+
+	partSize := p.cfg.PartSize
+	if partSize < minPartSize {
+		partSize = minPartSize
+	}
+
+	if int64(len(resource.Body)) > partSize {
+		return p.multipartUpload(u, resource.Body, partSize, header)
+	}
+
+	resp, err := p.do(http.MethodPut, u, nil, resource.Body, header)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// multipartUpload uploads the body in parts, the upload is aborted on failure
+func (p SourceProvider) multipartUpload(u *url.URL, body []byte, partSize int64, header http.Header) error {
+	resp, err := p.do(http.MethodPost, u, url.Values{"uploads": {""}}, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	result := initiateMultipartUploadResult{}
+
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	if err := p.uploadParts(u, result.UploadID, body, partSize); err != nil {
+		resp, abortErr := p.do(http.MethodDelete, u, url.Values{"uploadId": {result.UploadID}}, nil, nil)
+		if abortErr != nil {
+			log.Error().Err(abortErr).Msgf("Abort multipart upload %s", result.UploadID)
+		} else {
+			resp.Body.Close()
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (p SourceProvider) uploadParts(u *url.URL, uploadID string, body []byte, partSize int64) error {
+	complete := completeMultipartUpload{}
+
+	for number, offset := 1, int64(0); offset < int64(len(body)); number, offset = number+1, offset+partSize {
+		end := offset + partSize
+		if end > int64(len(body)) {
+			end = int64(len(body))
+		}
+
+		resp, err := p.do(http.MethodPut, u, url.Values{
+			"partNumber": {strconv.Itoa(number)},
+			"uploadId":   {uploadID},
+		}, body[offset:end], nil)
+		if err != nil {
+			return err
+		}
+
+		resp.Body.Close()
+
+		complete.Parts = append(complete.Parts, completedPart{
+			PartNumber: number,
+			ETag:       resp.Header.Get("ETag"),
+		})
+	}
+
+	data, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.do(http.MethodPost, u, url.Values{"uploadId": {uploadID}}, data, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the completion can fail after the 200 status is sent, the error is then in the body
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Contains(result, []byte("<Error>")) {
+		return readError(resp.StatusCode, bytes.NewReader(result))
+	}
+
+	log.Debug().Msgf("Write source object in %d parts", len(complete.Parts))
+
+	return nil
+}
+
+// Del source object
+func (p SourceProvider) Del(resource *image.Resource) error {
+	u, err := p.objectURL(resource.Path)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.do(http.MethodDelete, u, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
@@ -0,0 +1,386 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 is a S3 compatible stand-in with path-style addressing, it verifies the signatures
+type fakeS3 struct {
+	mtx      sync.Mutex
+	signer   signer
+	objects  map[string][]byte
+	modified map[string]time.Time
+	uploads  map[string]map[int][]byte
+	requests []string
+}
+
+func newFakeS3(s signer) *fakeS3 {
+	return &fakeS3{
+		signer:   s,
+		objects:  map[string][]byte{},
+		modified: map[string]time.Time{},
+		uploads:  map[string]map[int][]byte{},
+	}
+}
+
+func has(query url.Values, key string) bool {
+	_, ok := query[key]
+
+	return ok
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func (f *fakeS3) verify(r *http.Request, body []byte) bool {
+	date, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	if r.Header.Get("X-Amz-Content-Sha256") != hashSHA256(body) {
+		return false
+	}
+
+	clone := r.Clone(r.Context())
+	clone.URL.Host = r.Host
+
+	f.signer.sign(clone, hashSHA256(body), date)
+
+	return clone.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+
+	if !f.verify(r, body) {
+		f.error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+
+		return
+	}
+
+	key := r.URL.Path
+	query := r.URL.Query()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.RawQuery)
+
+	switch {
+	case r.Method == http.MethodPost && has(query, "uploads"):
+		id := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[id] = map[int][]byte{}
+
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, key, id)
+	case r.Method == http.MethodPut && has(query, "partNumber"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchUpload")
+
+			return
+		}
+
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, number))
+	case r.Method == http.MethodPost && has(query, "uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchUpload")
+
+			return
+		}
+
+		complete := completeMultipartUpload{}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			f.error(w, http.StatusBadRequest, "MalformedXML")
+
+			return
+		}
+
+		numbers := []int{}
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+
+		sort.Ints(numbers)
+
+		var object []byte
+
+		for i, n := range numbers {
+			if complete.Parts[i].ETag != fmt.Sprintf(`"%d"`, n) {
+				f.error(w, http.StatusBadRequest, "InvalidPart")
+
+				return
+			}
+
+			object = append(object, parts[n]...)
+		}
+
+		f.objects[key] = object
+		f.modified[key] = time.Now()
+
+		delete(f.uploads, query.Get("uploadId"))
+
+		io.WriteString(w, `<CompleteMultipartUploadResult><ETag>"abc-2"</ETag></CompleteMultipartUploadResult>`)
+	case r.Method == http.MethodDelete && has(query, "uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		f.modified[key] = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		w.Header().Set("ETag", `"abc"`)
+	case r.Method == http.MethodGet:
+		object, ok := f.objects[key]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchKey")
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(object)))
+		w.Header().Set("Last-Modified", f.modified[key].Format(http.TimeFormat))
+		w.Write(object)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func TestSourceProviderObjectURL(t *testing.T) {
+	p := NewSourceProvider(&SourceConfiguration{
+		Region: "eu-west-3",
+		Bucket: "images",
+		Prefix: "sources",
+	})
+
+	u, err := p.objectURL("/products/my kayak.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://images.s3.eu-west-3.amazonaws.com/sources/products/my%20kayak.jpg", u.String())
+
+	p = NewSourceProvider(&SourceConfiguration{
+		Endpoint:  "http://localhost:9000",
+		Bucket:    "images",
+		PathStyle: true,
+	})
+
+	u, err = p.objectURL("/kayaks.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:9000/images/kayaks.jpg", u.String())
+
+	_, err = p.objectURL("/../kayaks.jpg")
+	assert.Equal(t, ErrInvalidPath, err)
+}
+
+func TestSourceProviderEnvCredentials(t *testing.T) {
+	os.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+
+	assert.Equal(t, "env-key", NewSourceProvider(&SourceConfiguration{}).signer.accessKeyID)
+	assert.Equal(t, "key", NewSourceProvider(&SourceConfiguration{AccessKeyID: "key"}).signer.accessKeyID)
+}
+
+func TestSourceProvider(t *testing.T) {
+	body, err := os.ReadFile("../../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	cfg := &SourceConfiguration{
+		Region:          "us-east-1",
+		Bucket:          "images",
+		Prefix:          "/sources/",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		PathStyle:       true,
+	}
+
+	p := NewSourceProvider(cfg)
+
+	fake := newFakeS3(p.signer)
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	cfg.Endpoint = server.URL
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/hyperpic.png", Body: body}))
+	assert.Equal(t, body, fake.objects["/images/sources/products/hyperpic.png"])
+
+	source, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+	assert.Equal(t, len(body), source.Size)
+	assert.Equal(t, "hyperpic.png", source.Name)
+	assert.Equal(t, "image/png", source.MimeType)
+	assert.True(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Equal(source.ModifiedAt.Truncate(time.Second)))
+
+	// an overwrite in the same second changes the modified date
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/hyperpic.png", Body: body[:len(body)-1]}))
+
+	overwritten, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.True(t, source.ModifiedAt.Truncate(time.Second).Equal(overwritten.ModifiedAt.Truncate(time.Second)))
+	assert.False(t, source.ModifiedAt.Equal(overwritten.ModifiedAt))
+
+	// the escaped keys are signed
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/my kayak (1).png", Body: body}))
+
+	_, err = p.Get(&image.Resource{Path: "/products/my kayak (1).png"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Del(&image.Resource{Path: "/products/hyperpic.png"}))
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, ErrInvalidPath, p.Set(&image.Resource{Path: "/../foo.png"}))
+	assert.Equal(t, ErrInvalidPath, p.Del(&image.Resource{Path: "/../foo.png"}))
+
+	// wrong credentials
+	p.signer.secretAccessKey = "other"
+
+	_, err = p.Get(&image.Resource{Path: "/products/my kayak (1).png"})
+	assert.EqualError(t, err, "s3: SignatureDoesNotMatch: SignatureDoesNotMatch")
+	assert.False(t, os.IsNotExist(err))
+}
+
+func TestSourceProviderMultipartUpload(t *testing.T) {
+	body := bytes.Repeat([]byte("0123456789"), (minPartSize*2+minPartSize/2)/10)
+
+	cfg := &SourceConfiguration{
+		Region:          "us-east-1",
+		Bucket:          "images",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+		PartSize:        1024,
+	}
+
+	p := NewSourceProvider(cfg)
+
+	fake := newFakeS3(p.signer)
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	cfg.Endpoint = server.URL
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/large.jpg", Body: body}))
+	assert.Equal(t, body, fake.objects["/images/large.jpg"])
+	assert.Equal(t, []string{
+		"POST uploads=",
+		"PUT partNumber=1&uploadId=1",
+		"PUT partNumber=2&uploadId=1",
+		"PUT partNumber=3&uploadId=1",
+		"POST uploadId=1",
+	}, fake.requests)
+	assert.Empty(t, fake.uploads)
+
+	source, err := p.Get(&image.Resource{Path: "/large.jpg"})
+	assert.NoError(t, err)
+	assert.Equal(t, len(body), source.Size)
+}
+
+func TestSourceProviderMultipartUploadAbort(t *testing.T) {
+	body := bytes.Repeat([]byte("0"), minPartSize+1)
+
+	cfg := &SourceConfiguration{
+		Region:          "us-east-1",
+		Bucket:          "images",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}
+
+	p := NewSourceProvider(cfg)
+
+	fake := newFakeS3(p.signer)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("partNumber") == "2" {
+			fake.error(w, http.StatusInternalServerError, "InternalError")
+
+			return
+		}
+
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	cfg.Endpoint = server.URL
+
+	err := p.Set(&image.Resource{Path: "/large.jpg", Body: body})
+	assert.EqualError(t, err, "s3: InternalError: InternalError")
+	assert.Empty(t, fake.uploads)
+	assert.NotContains(t, fake.objects, "/images/large.jpg")
+	assert.Equal(t, "DELETE uploadId=1", fake.requests[len(fake.requests)-1])
+}
+
+func TestSourceProviderCompleteError(t *testing.T) {
+	cfg := &SourceConfiguration{
+		Region:          "us-east-1",
+		Bucket:          "images",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}
+
+	p := NewSourceProvider(cfg)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := url.ParseQuery(r.URL.RawQuery)
+
+		switch {
+		case has(query, "uploads"):
+			io.WriteString(w, `<InitiateMultipartUploadResult><UploadId>1</UploadId></InitiateMultipartUploadResult>`)
+		case has(query, "partNumber"):
+			w.Header().Set("ETag", `"1"`)
+		case r.Method == http.MethodPost:
+			// the error is sent after the 200 status
+			io.WriteString(w, strings.Repeat(" ", 10)+`<Error><Code>InternalError</Code><Message>retry</Message></Error>`)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	cfg.Endpoint = server.URL
+
+	err := p.Set(&image.Resource{Path: "/large.jpg", Body: bytes.Repeat([]byte("0"), minPartSize+1)})
+	assert.EqualError(t, err, "s3: InternalError: retry")
+}
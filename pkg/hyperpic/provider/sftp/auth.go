@@ -0,0 +1,84 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// authMethods returns the private key and password authentications of the configuration
+func authMethods(cfg *SourceConfiguration) ([]ssh.AuthMethod, error) {
+	methods := []ssh.AuthMethod{}
+
+	if cfg.PrivateKey != "" {
+		data, err := os.ReadFile(cfg.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		var signer ssh.Signer
+
+		if cfg.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(cfg.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(data)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("sftp: invalid private key: %w", err)
+		}
+
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if cfg.Password != "" {
+		methods = append(methods, ssh.Password(cfg.Password))
+	}
+
+	if len(methods) == 0 {
+		return nil, ErrNoAuth
+	}
+
+	return methods, nil
+}
+
+// hostKeyCallback accepts only the pinned host key, given as an authorized_keys
+// line or as the SHA256 fingerprint printed by ssh-keygen -l
+func hostKeyCallback(pinned string) (ssh.HostKeyCallback, error) {
+	pinned = strings.TrimSpace(pinned)
+
+	if pinned == "" {
+		return nil, ErrNoHostKey
+	}
+
+	if strings.HasPrefix(pinned, "SHA256:") {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if ssh.FingerprintSHA256(key) != pinned {
+				return ErrHostKeyMismatch
+			}
+
+			return nil
+		}, nil
+	}
+
+	expected, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pinned))
+	if err != nil {
+		return nil, fmt.Errorf("sftp: invalid host key: %w", err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if !bytes.Equal(key.Marshal(), expected.Marshal()) {
+			return ErrHostKeyMismatch
+		}
+
+		return nil
+	}, nil
+}
@@ -0,0 +1,28 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import "time"
+
+// SourceConfiguration struct
+type SourceConfiguration struct {
+	// Address of the server, host:port
+	Address  string
+	User     string
+	Password string
+	// PrivateKey is the path of the PEM private key, decrypted with Passphrase
+	PrivateKey string `mapstructure:"private_key"`
+	Passphrase string
+	// HostKey pins the key of the server, an authorized_keys line or a SHA256: fingerprint
+	HostKey string `mapstructure:"host_key"`
+	// Path of the sources on the server
+	Path string
+	// PoolSize is the maximum number of open connections
+	PoolSize int `mapstructure:"pool_size"`
+	// Timeout of the connection and of the ssh handshake
+	Timeout time.Duration
+	// OperationTimeout bounds each operation on a connection, 0 to disable
+	OperationTimeout time.Duration `mapstructure:"operation_timeout"`
+}
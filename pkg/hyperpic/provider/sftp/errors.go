@@ -0,0 +1,16 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import "errors"
+
+// sftp errors
+var (
+	ErrInvalidPath     = errors.New("invalid URL path")
+	ErrNotFile         = errors.New("the path is not a file")
+	ErrNoAuth          = errors.New("sftp: a password or a private key is required")
+	ErrNoHostKey       = errors.New("sftp: the host key is required")
+	ErrHostKeyMismatch = errors.New("sftp: the host key does not match")
+)
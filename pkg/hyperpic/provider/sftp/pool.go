@@ -0,0 +1,105 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	gosftp "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// conn is a sftp session on its ssh connection
+type conn struct {
+	net  net.Conn
+	ssh  *ssh.Client
+	sftp *gosftp.Client
+}
+
+func (c *conn) Close() error {
+	c.sftp.Close()
+
+	return c.ssh.Close()
+}
+
+// pool keeps the idle connections, at most size connections are open
+type pool struct {
+	dial  func() (*conn, error)
+	idle  chan *conn
+	slots chan struct{}
+}
+
+func newPool(size int, dial func() (*conn, error)) *pool {
+	return &pool{
+		dial:  dial,
+		idle:  make(chan *conn, size),
+		slots: make(chan struct{}, size),
+	}
+}
+
+// get returns an idle connection or opens a new one, it waits when the pool is full
+func (p *pool) get() (*conn, error) {
+	// an idle connection is preferred to a free slot
+	select {
+	case c := <-p.idle:
+		return c, nil
+	default:
+	}
+
+	select {
+	case c := <-p.idle:
+		return c, nil
+	case p.slots <- struct{}{}:
+	}
+
+	c, err := p.dial()
+	if err != nil {
+		<-p.slots
+
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// put releases the connection, it is closed when err is not a file error
+func (p *pool) put(c *conn, err error) {
+	if err != nil && !isFileError(err) {
+		c.Close()
+
+		<-p.slots
+
+		return
+	}
+
+	p.idle <- c
+}
+
+// Close the idle connections
+func (p *pool) Close() error {
+	for {
+		select {
+		case c := <-p.idle:
+			c.Close()
+
+			<-p.slots
+		default:
+			return nil
+		}
+	}
+}
+
+// isFileError returns true if the error is returned by the server for a file,
+// the connection is still usable
+func isFileError(err error) bool {
+	var status *gosftp.StatusError
+
+	return errors.As(err, &status) ||
+		os.IsNotExist(err) ||
+		os.IsPermission(err) ||
+		errors.Is(err, ErrNotFile)
+}
@@ -0,0 +1,277 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net"
+	"os"
+	"path"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/fsutil"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	gosftp "github.com/pkg/sftp"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultPoolSize is the maximum number of open connections
+const defaultPoolSize = 4
+
+// SourceProvider stores the sources on a SFTP server
+type SourceProvider struct {
+	cfg     *SourceConfiguration
+	address string
+	ssh     *ssh.ClientConfig
+	pool    *pool
+}
+
+// NewSourceProvider func
+func NewSourceProvider(cfg *SourceConfiguration) (*SourceProvider, error) {
+	methods, err := authMethods(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	callback, err := hostKeyCallback(cfg.HostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	address := cfg.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "22")
+	}
+
+	p := &SourceProvider{
+		cfg:     cfg,
+		address: address,
+		ssh: &ssh.ClientConfig{
+			User:            cfg.User,
+			Auth:            methods,
+			HostKeyCallback: callback,
+			Timeout:         cfg.Timeout,
+		},
+	}
+
+	size := cfg.PoolSize
+	if size <= 0 {
+		size = defaultPoolSize
+	}
+
+	p.pool = newPool(size, p.dial)
+
+	return p, nil
+}
+
+// dial opens a ssh connection and starts the sftp subsystem, the handshake is bounded by the timeout
+func (p SourceProvider) dial() (*conn, error) {
+	nc, err := net.DialTimeout("tcp", p.address, p.ssh.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.ssh.Timeout > 0 {
+		_ = nc.SetDeadline(time.Now().Add(p.ssh.Timeout))
+	}
+
+	sc, channels, requests, err := ssh.NewClientConn(nc, p.address, p.ssh)
+	if err != nil {
+		nc.Close()
+
+		return nil, err
+	}
+
+	client := ssh.NewClient(sc, channels, requests)
+
+	session, err := gosftp.NewClient(client)
+	if err != nil {
+		client.Close()
+
+		return nil, err
+	}
+
+	_ = nc.SetDeadline(time.Time{})
+
+	log.Debug().Msgf("Open sftp connection to %s", p.address)
+
+	return &conn{net: nc, ssh: client, sftp: session}, nil
+}
+
+// with runs fn with a pooled connection, fn fails when it exceeds the operation timeout
+// and the connection is closed
+func (p SourceProvider) with(fn func(client *gosftp.Client) error) error {
+	c, err := p.pool.get()
+	if err != nil {
+		return err
+	}
+
+	if p.cfg.OperationTimeout > 0 {
+		_ = c.net.SetDeadline(time.Now().Add(p.cfg.OperationTimeout))
+	}
+
+	err = fn(c.sftp)
+
+	if p.cfg.OperationTimeout > 0 {
+		_ = c.net.SetDeadline(time.Time{})
+	}
+
+	p.pool.put(c, err)
+
+	return err
+}
+
+// remotePath returns the path of the resource on the server
+func (p SourceProvider) remotePath(resourcePath string) (string, error) {
+	if fsutil.ContainsDotDot(resourcePath) {
+		return "", ErrInvalidPath
+	}
+
+	return path.Join(p.cfg.Path, resourcePath), nil
+}
+
+// Get resource from the server
+func (p SourceProvider) Get(resource *image.Resource) (*image.Resource, error) {
+	name, err := p.remotePath(resource.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &image.Resource{
+		Path:    resource.Path,
+		Options: resource.Options,
+		Name:    path.Base(resource.Path),
+	}
+
+	err = p.with(func(client *gosftp.Client) error {
+		f, err := client.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return ErrNotFile
+		}
+
+		source.ModifiedAt = info.ModTime()
+
+		source.Body, err = io.ReadAll(f)
+
+		return err
+	})
+
+	if os.IsNotExist(err) {
+		return nil, &os.PathError{Op: "open", Path: resource.Path, Err: os.ErrNotExist}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	source.Size = len(source.Body)
+
+	return source, nil
+}
+
+// tempName returns a hidden name in the directory of the file
+func tempName(name string) (string, error) {
+	suffix := make([]byte, 8)
+
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	return path.Join(path.Dir(name), "."+path.Base(name)+".tmp-"+hex.EncodeToString(suffix)), nil
+}
+
+// writeFile creates the file with the body, the server can report the write errors on close
+func writeFile(client *gosftp.Client, name string, body []byte) error {
+	f, err := client.Create(name)
+	if err != nil {
+		return err
+	}
+
+	n, err := f.Write(body)
+	if err != nil {
+		f.Close()
+
+		return err
+	}
+
+	log.Debug().Msgf("Write source file size: %d", n)
+
+	return f.Close()
+}
+
+// Set resource to the server, the directories are created. The file is written under a
+// temporary name and renamed once closed, a failed write does not replace the source.
+func (p SourceProvider) Set(resource *image.Resource) error {
+	name, err := p.remotePath(resource.Path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := tempName(name)
+	if err != nil {
+		return err
+	}
+
+	return p.with(func(client *gosftp.Client) error {
+		if err := client.MkdirAll(path.Dir(name)); err != nil {
+			return err
+		}
+
+		if err := writeFile(client, tmp, resource.Body); err != nil {
+			_ = client.Remove(tmp)
+
+			return err
+		}
+
+		if err := client.PosixRename(tmp, name); err == nil {
+			return nil
+		}
+
+		// without the posix-rename extension the existing file is not replaced
+		if err := client.Remove(name); err != nil && !os.IsNotExist(err) {
+			_ = client.Remove(tmp)
+
+			return err
+		}
+
+		if err := client.Rename(tmp, name); err != nil {
+			_ = client.Remove(tmp)
+
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Del source file
+func (p SourceProvider) Del(resource *image.Resource) error {
+	name, err := p.remotePath(resource.Path)
+	if err != nil {
+		return err
+	}
+
+	return p.with(func(client *gosftp.Client) error {
+		return client.Remove(name)
+	})
+}
+
+// Close the idle connections
+func (p SourceProvider) Close() error {
+	return p.pool.Close()
+}
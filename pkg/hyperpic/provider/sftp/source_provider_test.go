@@ -0,0 +1,342 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	gosftp "github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an in-process ssh server with the sftp subsystem on a temp dir
+type testServer struct {
+	listener    net.Listener
+	hostKey     ssh.Signer
+	userKey     ssh.PublicKey
+	root        string
+	connections int32
+	wg          sync.WaitGroup
+}
+
+func newSigner(t *testing.T) (ssh.Signer, ed25519.PrivateKey) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.NoError(t, err)
+
+	return signer, key
+}
+
+func newTestServer(t *testing.T, userKey ssh.PublicKey) *testServer {
+	hostKey, _ := newSigner(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &testServer{
+		listener: listener,
+		hostKey:  hostKey,
+		userKey:  userKey,
+		root:     t.TempDir(),
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "partner" && string(password) == "secret" {
+				return nil, nil
+			}
+
+			return nil, ErrNoAuth
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if s.userKey != nil && string(key.Marshal()) == string(s.userKey.Marshal()) {
+				return nil, nil
+			}
+
+			return nil, ErrNoAuth
+		},
+	}
+
+	config.AddHostKey(hostKey)
+
+	go func() {
+		for {
+			nc, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			s.wg.Add(1)
+
+			go s.serve(nc, config)
+		}
+	}()
+
+	return s
+}
+
+func (s *testServer) serve(nc net.Conn, config *ssh.ServerConfig) {
+	defer s.wg.Done()
+
+	_, channels, requests, err := ssh.NewServerConn(nc, config)
+	if err != nil {
+		return
+	}
+
+	atomic.AddInt32(&s.connections, 1)
+
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(in <-chan *ssh.Request) {
+			for req := range in {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+
+				req.Reply(ok, nil)
+			}
+		}(requests)
+
+		server, err := gosftp.NewServer(channel)
+		if err != nil {
+			return
+		}
+
+		go func() {
+			server.Serve()
+			server.Close()
+		}()
+	}
+}
+
+func (s *testServer) Close() {
+	s.listener.Close()
+}
+
+func authorizedKey(key ssh.PublicKey) string {
+	return string(ssh.MarshalAuthorizedKey(key))
+}
+
+func TestNewSourceProvider(t *testing.T) {
+	hostKey, _ := newSigner(t)
+
+	_, err := NewSourceProvider(&SourceConfiguration{HostKey: authorizedKey(hostKey.PublicKey())})
+	assert.Equal(t, ErrNoAuth, err)
+
+	_, err = NewSourceProvider(&SourceConfiguration{Password: "secret"})
+	assert.Equal(t, ErrNoHostKey, err)
+
+	_, err = NewSourceProvider(&SourceConfiguration{Password: "secret", HostKey: "foo"})
+	assert.Error(t, err)
+
+	_, err = NewSourceProvider(&SourceConfiguration{PrivateKey: "/path/to/missing", HostKey: authorizedKey(hostKey.PublicKey())})
+	assert.True(t, os.IsNotExist(err))
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Address:  "sftp.example.com",
+		Password: "secret",
+		HostKey:  ssh.FingerprintSHA256(hostKey.PublicKey()),
+		Path:     "/upload",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "sftp.example.com:22", p.address)
+	assert.Equal(t, defaultPoolSize, cap(p.pool.slots))
+
+	name, err := p.remotePath("/products/kayak.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, "/upload/products/kayak.jpg", name)
+
+	_, err = p.remotePath("/../kayak.jpg")
+	assert.Equal(t, ErrInvalidPath, err)
+}
+
+func testSourceProvider(t *testing.T, p *SourceProvider, root string) {
+	body, err := os.ReadFile("../../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/hyperpic.png", Body: body}))
+
+	stored, err := os.ReadFile(filepath.Join(root, "sources", "products", "hyperpic.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, body, stored)
+
+	source, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+	assert.Equal(t, len(body), source.Size)
+	assert.Equal(t, "hyperpic.png", source.Name)
+	assert.False(t, source.ModifiedAt.IsZero())
+
+	// overwrite with a smaller file
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/hyperpic.png", Body: body[:10]}))
+
+	source, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, body[:10], source.Body)
+
+	// the temporary files are renamed
+	entries, err := os.ReadDir(filepath.Join(root, "sources", "products"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, err = p.Get(&image.Resource{Path: "/products"})
+	assert.Equal(t, ErrNotFile, err)
+
+	assert.NoError(t, p.Del(&image.Resource{Path: "/products/hyperpic.png"}))
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Error(t, p.Del(&image.Resource{Path: "/products/hyperpic.png"}))
+
+	assert.Equal(t, ErrInvalidPath, p.Set(&image.Resource{Path: "/../foo.png"}))
+	assert.Equal(t, ErrInvalidPath, p.Del(&image.Resource{Path: "/../foo.png"}))
+}
+
+func TestSourceProviderWithPassword(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Close()
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Address:  server.listener.Addr().String(),
+		User:     "partner",
+		Password: "secret",
+		HostKey:  authorizedKey(server.hostKey.PublicKey()),
+		Path:     filepath.Join(server.root, "sources"),
+		PoolSize: 2,
+		Timeout:  time.Second,
+	})
+	assert.NoError(t, err)
+	defer p.Close()
+
+	testSourceProvider(t, p, server.root)
+
+	// the file errors keep the connection open
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.connections))
+
+	// the pool is shared by the concurrent requests
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := p.Get(&image.Resource{Path: "/missing.png"})
+			assert.True(t, os.IsNotExist(err))
+		}()
+	}
+
+	wg.Wait()
+
+	assert.LessOrEqual(t, atomic.LoadInt32(&server.connections), int32(2))
+
+	// an operation exceeding its timeout fails and closes the connection
+	p.cfg.OperationTimeout = time.Nanosecond
+
+	_, err = p.Get(&image.Resource{Path: "/missing.png"})
+	assert.Error(t, err)
+	assert.False(t, os.IsNotExist(err))
+
+	p.cfg.OperationTimeout = time.Second
+
+	_, err = p.Get(&image.Resource{Path: "/missing.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	p.ssh.Auth = []ssh.AuthMethod{ssh.Password("other")}
+
+	assert.NoError(t, p.Close())
+
+	_, err = p.Get(&image.Resource{Path: "/missing.png"})
+	assert.Error(t, err)
+	assert.False(t, os.IsNotExist(err))
+}
+
+func TestSourceProviderWithPrivateKey(t *testing.T) {
+	signer, key := newSigner(t)
+
+	server := newTestServer(t, signer.PublicKey())
+	defer server.Close()
+
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte("passphrase"))
+	assert.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Address:    server.listener.Addr().String(),
+		User:       "partner",
+		PrivateKey: keyFile,
+		Passphrase: "passphrase",
+		HostKey:    ssh.FingerprintSHA256(server.hostKey.PublicKey()),
+		Path:       filepath.Join(server.root, "sources"),
+		Timeout:    time.Second,
+	})
+	assert.NoError(t, err)
+	defer p.Close()
+
+	testSourceProvider(t, p, server.root)
+
+	_, err = NewSourceProvider(&SourceConfiguration{
+		PrivateKey: keyFile,
+		Passphrase: "other",
+		HostKey:    ssh.FingerprintSHA256(server.hostKey.PublicKey()),
+	})
+	assert.Error(t, err)
+}
+
+func TestSourceProviderHostKeyPinning(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Close()
+
+	other, _ := newSigner(t)
+
+	for _, hostKey := range []string{
+		authorizedKey(other.PublicKey()),
+		ssh.FingerprintSHA256(other.PublicKey()),
+	} {
+		p, err := NewSourceProvider(&SourceConfiguration{
+			Address:  server.listener.Addr().String(),
+			User:     "partner",
+			Password: "secret",
+			HostKey:  hostKey,
+			Timeout:  time.Second,
+		})
+		assert.NoError(t, err)
+
+		_, err = p.Get(&image.Resource{Path: "/hyperpic.png"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), ErrHostKeyMismatch.Error())
+	}
+}
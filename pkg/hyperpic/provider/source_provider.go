@@ -5,6 +5,8 @@
 package provider
 
 import (
+	"io"
+
 	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
 )
 
@@ -17,3 +19,16 @@ type SourceProvider interface {
 
 	Del(resource *image.Resource) error
 }
+
+// StreamSourceProvider is implemented by source providers able to read the source
+// without loading it in memory
+type StreamSourceProvider interface {
+	// Open returns the resource metadata (without body) and a reader of the source
+	Open(resource *image.Resource) (*image.Resource, io.ReadCloser, error)
+}
+
+// ReadOnlySourceProvider is implemented by source providers unable to store the sources,
+// their Set and Del always fail
+type ReadOnlySourceProvider interface {
+	ReadOnly() bool
+}
@@ -0,0 +1,18 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webdav
+
+import "time"
+
+// SourceConfiguration struct
+type SourceConfiguration struct {
+	// Endpoint is the url of the collection of the sources, it must exist
+	Endpoint string
+	Username string
+	Password string
+	// PoolSize is the maximum number of idle connections
+	PoolSize int `mapstructure:"pool_size"`
+	Timeout  time.Duration
+}
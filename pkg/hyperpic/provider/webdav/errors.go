@@ -0,0 +1,25 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webdav
+
+import (
+	"errors"
+	"fmt"
+)
+
+// webdav errors
+var (
+	ErrInvalidPath = errors.New("invalid URL path")
+)
+
+// StatusError is returned when the server answers with an unexpected status
+type StatusError struct {
+	Method     string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webdav: %s returned http status %d", e.Method, e.StatusCode)
+}
@@ -0,0 +1,248 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webdav
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/fsutil"
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultPoolSize is the maximum number of idle connections
+const defaultPoolSize = 4
+
+// SourceProvider stores the sources on a WebDAV server
+type SourceProvider struct {
+	cfg      *SourceConfiguration
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewSourceProvider func
+func NewSourceProvider(cfg *SourceConfiguration) (*SourceProvider, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	size := cfg.PoolSize
+	if size <= 0 {
+		size = defaultPoolSize
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = size
+	transport.MaxIdleConnsPerHost = size
+
+	return &SourceProvider{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport,
+		},
+	}, nil
+}
+
+// resourceURL returns the url of the resource path
+func (p SourceProvider) resourceURL(resourcePath string) (*url.URL, error) {
+	if fsutil.ContainsDotDot(resourcePath) {
+		return nil, ErrInvalidPath
+	}
+
+	u := *p.endpoint
+	u.Path = path.Join("/", p.endpoint.Path, resourcePath)
+	u.RawPath = ""
+
+	return &u, nil
+}
+
+// do sends the authenticated request
+func (p SourceProvider) do(method string, u *url.URL, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if p.cfg.Username != "" || p.cfg.Password != "" {
+		req.SetBasicAuth(p.cfg.Username, p.cfg.Password)
+	}
+
+	return p.client.Do(req)
+}
+
+// Open resource from the server without reading it
+func (p SourceProvider) Open(resource *image.Resource) (*image.Resource, io.ReadCloser, error) {
+	u, err := p.resourceURL(resource.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := p.do(http.MethodGet, u, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil, &os.PathError{Op: "open", Path: resource.Path, Err: os.ErrNotExist}
+		}
+
+		return nil, nil, &StatusError{Method: http.MethodGet, StatusCode: resp.StatusCode}
+	}
+
+	source := &image.Resource{
+		Path:     resource.Path,
+		Options:  resource.Options,
+		Name:     path.Base(resource.Path),
+		Size:     int(resp.ContentLength),
+		MimeType: resp.Header.Get("Content-Type"),
+	}
+
+	if modifiedAt, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		source.ModifiedAt = modifiedAt
+	}
+
+	return source, resp.Body, nil
+}
+
+// Get resource from the server
+func (p SourceProvider) Get(resource *image.Resource) (*image.Resource, error) {
+	source, body, err := p.Open(resource)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	source.Body = data
+	source.Size = len(data)
+
+	return source, nil
+}
+
+// put uploads the body and returns the status of the server
+func (p SourceProvider) put(u *url.URL, body []byte) (int, error) {
+	header := http.Header{}
+	header.Set("Content-Type", http.DetectContentType(body))
+
+	resp, err := p.do(http.MethodPut, u, body, header)
+	if err != nil {
+		return 0, err
+	}
+
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// mkcol creates the missing collections of the resource path, from the endpoint down
+func (p SourceProvider) mkcol(resourcePath string) error {
+	dir := ""
+
+	for _, segment := range strings.Split(strings.Trim(path.Dir(resourcePath), "/"), "/") {
+		if segment == "" {
+			continue
+		}
+
+		dir += "/" + segment
+
+		u, err := p.resourceURL(dir)
+		if err != nil {
+			return err
+		}
+
+		u.Path += "/"
+
+		resp, err := p.do("MKCOL", u, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		resp.Body.Close()
+
+		// 405 is returned when the collection exists
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+			return &StatusError{Method: "MKCOL", StatusCode: resp.StatusCode}
+		}
+	}
+
+	return nil
+}
+
+// Set resource to the server, the missing collections are created when the server rejects the upload
+func (p SourceProvider) Set(resource *image.Resource) error {
+	u, err := p.resourceURL(resource.Path)
+	if err != nil {
+		return err
+	}
+
+	status, err := p.put(u, resource.Body)
+	if err != nil {
+		return err
+	}
+
+	// RFC 4918 returns 409 when the parent collection is missing, some servers 404
+	if status == http.StatusConflict || status == http.StatusNotFound {
+		if err := p.mkcol(resource.Path); err != nil {
+			return err
+		}
+
+		status, err = p.put(u, resource.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	if status != http.StatusCreated && status != http.StatusNoContent && status != http.StatusOK {
+		return &StatusError{Method: http.MethodPut, StatusCode: status}
+	}
+
+	log.Debug().Msgf("Write source file size: %d", len(resource.Body))
+
+	return nil
+}
+
+// Del source file
+func (p SourceProvider) Del(resource *image.Resource) error {
+	u, err := p.resourceURL(resource.Path)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.do(http.MethodDelete, u, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return &os.PathError{Op: "remove", Path: resource.Path, Err: os.ErrNotExist}
+	default:
+		return &StatusError{Method: http.MethodDelete, StatusCode: resp.StatusCode}
+	}
+}
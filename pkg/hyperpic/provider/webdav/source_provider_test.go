@@ -0,0 +1,131 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webdav
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/stretchr/testify/assert"
+	xwebdav "golang.org/x/net/webdav"
+)
+
+// newTestServer starts an in-process WebDAV server on a temp dir with basic auth,
+// it counts the opened connections
+func newTestServer(t *testing.T) (*httptest.Server, string, *int32) {
+	root := t.TempDir()
+
+	handler := &xwebdav.Handler{
+		FileSystem: xwebdav.Dir(root),
+		LockSystem: xwebdav.NewMemLS(),
+	}
+
+	var connections int32
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "partner" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="hyperpic"`)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	}))
+
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+
+	server.Start()
+
+	return server, root, &connections
+}
+
+func TestNewSourceProvider(t *testing.T) {
+	_, err := NewSourceProvider(&SourceConfiguration{Endpoint: "%zz"})
+	assert.Error(t, err)
+
+	p, err := NewSourceProvider(&SourceConfiguration{Endpoint: "https://dav.example.com/partners/"})
+	assert.NoError(t, err)
+
+	u, err := p.resourceURL("/products/my kayak.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://dav.example.com/partners/products/my%20kayak.jpg", u.String())
+
+	_, err = p.resourceURL("/../kayak.jpg")
+	assert.Equal(t, ErrInvalidPath, err)
+}
+
+func TestSourceProvider(t *testing.T) {
+	server, root, connections := newTestServer(t)
+	defer server.Close()
+
+	// the collection of the endpoint must exist
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "sources"), 0755))
+
+	p, err := NewSourceProvider(&SourceConfiguration{
+		Endpoint: server.URL + "/sources",
+		Username: "partner",
+		Password: "secret",
+		Timeout:  time.Second,
+	})
+	assert.NoError(t, err)
+
+	body, err := os.ReadFile("../../../../_resources/hyperpic.png")
+	assert.NoError(t, err)
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	// the products collection is created
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/hyperpic.png", Body: body}))
+
+	stored, err := os.ReadFile(filepath.Join(root, "sources", "products", "hyperpic.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, body, stored)
+
+	source, err := p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, body, source.Body)
+	assert.Equal(t, len(body), source.Size)
+	assert.Equal(t, "hyperpic.png", source.Name)
+	assert.Equal(t, "image/png", source.MimeType)
+	assert.False(t, source.ModifiedAt.IsZero())
+
+	assert.NoError(t, p.Set(&image.Resource{Path: "/products/my kayak.png", Body: body}))
+
+	_, err = p.Get(&image.Resource{Path: "/products/my kayak.png"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Del(&image.Resource{Path: "/products/hyperpic.png"}))
+
+	_, err = p.Get(&image.Resource{Path: "/products/hyperpic.png"})
+	assert.True(t, os.IsNotExist(err))
+
+	assert.True(t, os.IsNotExist(p.Del(&image.Resource{Path: "/products/hyperpic.png"})))
+
+	assert.Equal(t, ErrInvalidPath, p.Set(&image.Resource{Path: "/../foo.png"}))
+	assert.Equal(t, ErrInvalidPath, p.Del(&image.Resource{Path: "/../foo.png"}))
+
+	// the connections are reused
+	assert.Equal(t, int32(1), atomic.LoadInt32(connections))
+
+	p.cfg.Password = "other"
+
+	_, err = p.Get(&image.Resource{Path: "/products/my kayak.png"})
+	assert.EqualError(t, err, "webdav: GET returned http status 401")
+
+	assert.EqualError(t, p.Set(&image.Resource{Path: "/kayak.png", Body: body}), "webdav: PUT returned http status 401")
+}
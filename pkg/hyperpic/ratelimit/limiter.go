@@ -0,0 +1,151 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the interval between the removals of the idle buckets
+const sweepInterval = time.Minute
+
+// BucketConfiguration of a token bucket, a rate of 0 disables the limit
+type BucketConfiguration struct {
+	// Rate of tokens added per second
+	Rate float64
+	// Burst is the capacity of the bucket
+	Burst int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket per client key
+type Limiter struct {
+	rate    float64
+	burst   float64
+	mtx     sync.Mutex
+	buckets map[string]*bucket
+	sweep   time.Time
+	now     func() time.Time
+}
+
+// NewLimiter constructor
+func NewLimiter(cfg BucketConfiguration) *Limiter {
+	burst := float64(cfg.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:    cfg.Rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token of the client bucket, or returns the delay before the next token
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := l.now()
+
+	if now.Sub(l.sweep) > sweepInterval {
+		l.removeIdle(now)
+
+		l.sweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens: l.burst,
+			last:   now,
+		}
+
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// removeIdle removes the buckets refilled since their last use, they are recreated full
+func (l *Limiter) removeIdle(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Concurrency limits the number of concurrent operations per client key, 0 disables the limit
+type Concurrency struct {
+	max    int
+	mtx    sync.Mutex
+	counts map[string]int
+}
+
+// NewConcurrency constructor
+func NewConcurrency(max int) *Concurrency {
+	return &Concurrency{
+		max:    max,
+		counts: make(map[string]int),
+	}
+}
+
+// Acquire returns false if the client has reached the limit
+func (c *Concurrency) Acquire(key string) bool {
+	if c.max <= 0 {
+		return true
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.counts[key] >= c.max {
+		return false
+	}
+
+	c.counts[key]++
+
+	return true
+}
+
+// Release frees a slot acquired by the client
+func (c *Concurrency) Release(key string) {
+	if c.max <= 0 {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.counts[key] <= 1 {
+		delete(c.counts, key)
+
+		return
+	}
+
+	c.counts[key]--
+}
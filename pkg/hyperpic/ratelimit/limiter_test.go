@@ -0,0 +1,82 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	l := NewLimiter(BucketConfiguration{Rate: 2, Burst: 3})
+	l.now = func() time.Time {
+		return now
+	}
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a")
+		assert.True(t, ok)
+	}
+
+	ok, retryAfter := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	// the buckets are per client
+	ok, _ = l.Allow("b")
+	assert.True(t, ok)
+
+	now = now.Add(500 * time.Millisecond)
+
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+
+	ok, _ = l.Allow("a")
+	assert.False(t, ok)
+
+	// the full buckets are removed
+	now = now.Add(2 * time.Minute)
+
+	ok, _ = l.Allow("c")
+	assert.True(t, ok)
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestLimiterWithoutRate(t *testing.T) {
+	l := NewLimiter(BucketConfiguration{})
+
+	for i := 0; i < 100; i++ {
+		ok, _ := l.Allow("a")
+		assert.True(t, ok)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	c := NewConcurrency(2)
+
+	assert.True(t, c.Acquire("a"))
+	assert.True(t, c.Acquire("a"))
+	assert.False(t, c.Acquire("a"))
+	assert.True(t, c.Acquire("b"))
+
+	c.Release("a")
+
+	assert.True(t, c.Acquire("a"))
+
+	c.Release("a")
+	c.Release("a")
+	c.Release("b")
+
+	assert.Empty(t, c.counts)
+
+	unlimited := NewConcurrency(0)
+
+	assert.True(t, unlimited.Acquire("a"))
+	unlimited.Release("a")
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Budgets of the quotas
+const (
+	BudgetRequests    = "requests"
+	BudgetProcessing  = "processing"
+	BudgetConcurrency = "concurrency"
+)
+
+// Configuration struct
+type Configuration struct {
+	Enable bool
+	// TrustedProxies are the IPs or CIDRs allowed to set X-Forwarded-For
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// Requests is the budget of all the requests, cache hits included
+	Requests BucketConfiguration
+	// Processing is the budget of the images processed on a cache miss
+	Processing BucketConfiguration
+	// Concurrency is the maximum of images processed at the same time per client
+	Concurrency int
+}
+
+// Quotas of the clients
+type Quotas struct {
+	enable      bool
+	trusted     []*net.IPNet
+	requests    *Limiter
+	processing  *Limiter
+	concurrency *Concurrency
+}
+
+// NewQuotas constructor
+func NewQuotas(cfg *Configuration) (*Quotas, error) {
+	q := &Quotas{
+		enable:      cfg.Enable,
+		requests:    NewLimiter(cfg.Requests),
+		processing:  NewLimiter(cfg.Processing),
+		concurrency: NewConcurrency(cfg.Concurrency),
+	}
+
+	for _, proxy := range cfg.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+		}
+
+		q.trusted = append(q.trusted, network)
+	}
+
+	return q, nil
+}
+
+// Enabled returns true if the quotas are enforced
+func (q *Quotas) Enabled() bool {
+	return q != nil && q.enable
+}
+
+// AllowRequest takes a token of the requests budget of the client
+func (q *Quotas) AllowRequest(key string) (bool, time.Duration) {
+	if !q.Enabled() {
+		return true, 0
+	}
+
+	return q.requests.Allow(key)
+}
+
+// AcquireProcessing takes a token of the processing budget and a concurrency slot of the client,
+// release must be called when the image is processed.
+func (q *Quotas) AcquireProcessing(key string) (release func(), budget string, retryAfter time.Duration) {
+	if !q.Enabled() {
+		return func() {}, "", 0
+	}
+
+	if !q.concurrency.Acquire(key) {
+		return nil, BudgetConcurrency, time.Second
+	}
+
+	if ok, retryAfter := q.processing.Allow(key); !ok {
+		q.concurrency.Release(key)
+
+		return nil, BudgetProcessing, retryAfter
+	}
+
+	return func() {
+		q.concurrency.Release(key)
+	}, "", 0
+}
+
+func (q *Quotas) isTrusted(ip net.IP) bool {
+	if q == nil {
+		return false
+	}
+
+	for _, network := range q.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP returns the IP of the client: X-Forwarded-For is read from the right while
+// the addresses are trusted proxies, it is ignored if the peer is not a trusted proxy.
+func (q *Quotas) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !q.isTrusted(ip) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			break
+		}
+
+		host = addr
+
+		if !q.isTrusted(ip) {
+			break
+		}
+	}
+
+	return host
+}
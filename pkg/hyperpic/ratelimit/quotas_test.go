@@ -0,0 +1,101 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotasClientIP(t *testing.T) {
+	q, err := NewQuotas(&Configuration{
+		TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1", "::1"},
+	})
+	assert.NoError(t, err)
+
+	for _, test := range []struct {
+		remote    string
+		forwarded []string
+		expected  string
+	}{
+		{remote: "1.2.3.4:1234", expected: "1.2.3.4"},
+		{remote: "1.2.3.4:1234", forwarded: []string{"5.6.7.8"}, expected: "1.2.3.4"},
+		{remote: "10.0.0.1:1234", forwarded: []string{"5.6.7.8"}, expected: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", forwarded: []string{"9.9.9.9, 5.6.7.8, 192.168.1.1"}, expected: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", forwarded: []string{"9.9.9.9", "5.6.7.8"}, expected: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", forwarded: []string{"foo, 10.0.0.2"}, expected: "10.0.0.2"},
+		{remote: "[::1]:1234", forwarded: []string{"2001:db8::1"}, expected: "2001:db8::1"},
+		{remote: "10.0.0.1:1234", expected: "10.0.0.1"},
+	} {
+		req := httptest.NewRequest("GET", "/foo.jpg", nil)
+		req.RemoteAddr = test.remote
+
+		for _, value := range test.forwarded {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+
+		assert.Equal(t, test.expected, q.ClientIP(req), test.remote)
+	}
+
+	_, err = NewQuotas(&Configuration{
+		TrustedProxies: []string{"foo"},
+	})
+	assert.Error(t, err)
+}
+
+func TestQuotasAcquireProcessing(t *testing.T) {
+	q, err := NewQuotas(&Configuration{
+		Enable:      true,
+		Requests:    BucketConfiguration{Rate: 1, Burst: 1},
+		Processing:  BucketConfiguration{Rate: 1, Burst: 2},
+		Concurrency: 1,
+	})
+	assert.NoError(t, err)
+
+	ok, _ := q.AllowRequest("a")
+	assert.True(t, ok)
+
+	ok, retryAfter := q.AllowRequest("a")
+	assert.False(t, ok)
+	assert.True(t, retryAfter > 0)
+
+	release, _, _ := q.AcquireProcessing("a")
+	assert.NotNil(t, release)
+
+	_, budget, _ := q.AcquireProcessing("a")
+	assert.Equal(t, BudgetConcurrency, budget)
+
+	release()
+
+	release, _, _ = q.AcquireProcessing("a")
+	assert.NotNil(t, release)
+
+	release()
+
+	release, budget, retryAfter = q.AcquireProcessing("a")
+	assert.Nil(t, release)
+	assert.Equal(t, BudgetProcessing, budget)
+	assert.True(t, retryAfter > 0 && retryAfter <= time.Second)
+
+	// the failed acquire does not keep the concurrency slot
+	assert.Empty(t, q.concurrency.counts)
+}
+
+func TestQuotasDisabled(t *testing.T) {
+	var q *Quotas
+
+	assert.False(t, q.Enabled())
+
+	ok, _ := q.AllowRequest("a")
+	assert.True(t, ok)
+
+	release, _, _ := q.AcquireProcessing("a")
+	assert.NotNil(t, release)
+
+	release()
+}
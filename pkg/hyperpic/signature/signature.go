@@ -0,0 +1,197 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Query parameters of the signature
+const (
+	ParamSignature = "s"
+	ParamExpires   = "exp"
+)
+
+// Errors
+var (
+	ErrMissing = errors.New("signature: missing signature")
+	ErrInvalid = errors.New("signature: invalid signature")
+	ErrExpired = errors.New("signature: url has expired")
+	ErrNoKey   = errors.New("signature: no key")
+)
+
+// Configuration struct
+type Configuration struct {
+	// Enable requires a valid signature on every image request
+	Enable bool
+	// Keys verify the signatures, the first key signs the urls built by the server
+	Keys []string
+	// TTL of the urls signed by the server, no expiry if 0
+	TTL time.Duration `mapstructure:"ttl"`
+	// UploadTTL is the maximum expiry of the signed upload urls
+	UploadTTL time.Duration `mapstructure:"upload_ttl"`
+}
+
+// Canonical returns the signed string: the path and the query sorted by key, without the signature
+func Canonical(path string, query url.Values) string {
+	q := url.Values{}
+
+	for key, values := range query {
+		if key == ParamSignature {
+			continue
+		}
+
+		q[key] = values
+	}
+
+	return path + "?" + q.Encode()
+}
+
+// ScopedPath returns the signed path in the scope, a url signed in a scope is invalid in
+// another one. The path is signed as is without scope.
+func ScopedPath(scope string, path string) string {
+	if scope == "" {
+		return path
+	}
+
+	return scope + ":" + path
+}
+
+// Sign returns the HMAC-SHA256 signature of the path and query, encoded in base64 url
+func Sign(key string, path string, query url.Values) string {
+	mac := hmac.New(sha256.New, []byte(key))
+
+	mac.Write([]byte(Canonical(path, query)))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignURL adds the signature to the url, the url expires at expires if not zero
+func SignURL(key string, rawurl string, expires time.Time) (string, error) {
+	return SignScopedURL(key, "", rawurl, expires)
+}
+
+// SignScopedURL adds the signature of the url in the scope, the url expires at expires if not zero
+func SignScopedURL(key string, scope string, rawurl string, expires time.Time) (string, error) {
+	if key == "" {
+		return "", ErrNoKey
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+
+	if !expires.IsZero() {
+		query.Set(ParamExpires, strconv.FormatInt(expires.Unix(), 10))
+	}
+
+	query.Set(ParamSignature, Sign(key, ScopedPath(scope, u.Path), query))
+
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// Signer signs and verifies the urls with a set of keys
+type Signer struct {
+	keys  []string
+	ttl   time.Duration
+	scope string
+	now   func() time.Time
+}
+
+// NewSigner constructor
+func NewSigner(cfg *Configuration) *Signer {
+	return &Signer{
+		keys: cfg.Keys,
+		ttl:  cfg.TTL,
+		now:  time.Now,
+	}
+}
+
+// WithScope returns a signer of the urls in the scope
+func (s *Signer) WithScope(scope string) *Signer {
+	scoped := *s
+
+	scoped.scope = scope
+
+	return &scoped
+}
+
+// Sign returns a copy of the query with the signature of the first key
+func (s *Signer) Sign(path string, query url.Values) url.Values {
+	q := url.Values{}
+
+	for key, values := range query {
+		q[key] = append([]string{}, values...)
+	}
+
+	if len(s.keys) == 0 {
+		return q
+	}
+
+	if s.ttl > 0 {
+		q.Set(ParamExpires, strconv.FormatInt(s.now().Add(s.ttl).Unix(), 10))
+	}
+
+	q.Set(ParamSignature, Sign(s.keys[0], ScopedPath(s.scope, path), q))
+
+	return q
+}
+
+// Verify checks the signature against all the keys, then the expiry
+func (s *Signer) Verify(path string, query url.Values) error {
+	_, err := s.VerifyKey(path, query)
+
+	return err
+}
+
+// VerifyKey verifies the signature and returns the index of the key used to sign
+func (s *Signer) VerifyKey(path string, query url.Values) (int, error) {
+	if len(s.keys) == 0 {
+		return -1, ErrNoKey
+	}
+
+	signature := query.Get(ParamSignature)
+	if signature == "" {
+		return -1, ErrMissing
+	}
+
+	index := -1
+
+	for i, key := range s.keys {
+		if hmac.Equal([]byte(signature), []byte(Sign(key, ScopedPath(s.scope, path), query))) {
+			index = i
+
+			break
+		}
+	}
+
+	if index < 0 {
+		return -1, ErrInvalid
+	}
+
+	if exp := query.Get(ParamExpires); exp != "" {
+		expires, err := strconv.ParseInt(exp, 10, 64)
+		if err != nil {
+			return -1, ErrInvalid
+		}
+
+		if s.now().Unix() > expires {
+			return -1, ErrExpired
+		}
+	}
+
+	return index, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package signature
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonical(t *testing.T) {
+	assert.Equal(t, "/foo.jpg?fm=webp&w=200", Canonical("/foo.jpg", url.Values{
+		"w":  {"200"},
+		"fm": {"webp"},
+		"s":  {"abc"},
+	}))
+
+	assert.Equal(t, "/foo.jpg?", Canonical("/foo.jpg", url.Values{}))
+}
+
+func TestSign(t *testing.T) {
+	s := Sign("secret", "/foo.jpg", url.Values{"w": {"200"}, "fm": {"webp"}})
+
+	assert.Equal(t, "2sgX-E3AXtxFRtVQs68NBOIuSsN-7IC1185o_q_r0uE", s)
+	assert.Equal(t, s, Sign("secret", "/foo.jpg", url.Values{"fm": {"webp"}, "w": {"200"}, "s": {s}}))
+	assert.NotEqual(t, s, Sign("secret", "/bar.jpg", url.Values{"w": {"200"}, "fm": {"webp"}}))
+	assert.NotEqual(t, s, Sign("other", "/foo.jpg", url.Values{"w": {"200"}, "fm": {"webp"}}))
+}
+
+func TestSignURL(t *testing.T) {
+	signed, err := SignURL("secret", "/foo.jpg?w=200&fm=webp", time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/foo.jpg?fm=webp&s=2sgX-E3AXtxFRtVQs68NBOIuSsN-7IC1185o_q_r0uE&w=200", signed)
+
+	signed, err = SignURL("secret", "https://image.your-domain.tld/foo.jpg?w=200", time.Unix(1700000000, 0))
+	assert.NoError(t, err)
+
+	u, err := url.Parse(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "image.your-domain.tld", u.Host)
+	assert.Equal(t, "1700000000", u.Query().Get("exp"))
+
+	_, err = SignURL("", "/foo.jpg", time.Time{})
+	assert.Equal(t, ErrNoKey, err)
+
+	_, err = SignURL("secret", "%zz", time.Time{})
+	assert.Error(t, err)
+}
+
+func TestSignerVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	signer := NewSigner(&Configuration{
+		Keys: []string{"new", "old"},
+	})
+	signer.now = func() time.Time {
+		return now
+	}
+
+	query := url.Values{"w": {"200"}}
+
+	assert.Equal(t, ErrMissing, signer.Verify("/foo.jpg", query))
+
+	signed := signer.Sign("/foo.jpg", query)
+	assert.Equal(t, "", query.Get("s"))
+	assert.Equal(t, Sign("new", "/foo.jpg", query), signed.Get("s"))
+	assert.NoError(t, signer.Verify("/foo.jpg", signed))
+
+	// rotation: the urls signed with the previous key are still valid
+	query.Set("s", Sign("old", "/foo.jpg", query))
+	assert.NoError(t, signer.Verify("/foo.jpg", query))
+
+	index, err := signer.VerifyKey("/foo.jpg", query)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, index)
+
+	query.Set("s", Sign("revoked", "/foo.jpg", query))
+	assert.Equal(t, ErrInvalid, signer.Verify("/foo.jpg", query))
+
+	signed.Set("w", "201")
+	assert.Equal(t, ErrInvalid, signer.Verify("/foo.jpg", signed))
+
+	expired := url.Values{"w": {"200"}, "exp": {"1699999999"}}
+	expired.Set("s", Sign("new", "/foo.jpg", expired))
+	assert.Equal(t, ErrExpired, signer.Verify("/foo.jpg", expired))
+
+	invalid := url.Values{"w": {"200"}, "exp": {"foo"}}
+	invalid.Set("s", Sign("new", "/foo.jpg", invalid))
+	assert.Equal(t, ErrInvalid, signer.Verify("/foo.jpg", invalid))
+
+	assert.Equal(t, ErrNoKey, NewSigner(&Configuration{}).Verify("/foo.jpg", signed))
+}
+
+func TestSignerSignWithTTL(t *testing.T) {
+	signer := NewSigner(&Configuration{
+		Keys: []string{"new"},
+		TTL:  time.Hour,
+	})
+	signer.now = func() time.Time {
+		return time.Unix(1700000000, 0)
+	}
+
+	signed := signer.Sign("/foo.jpg", url.Values{"w": {"200"}})
+
+	assert.Equal(t, "1700003600", signed.Get("exp"))
+	assert.NoError(t, signer.Verify("/foo.jpg", signed))
+
+	assert.Equal(t, url.Values{"w": {"200"}}, NewSigner(&Configuration{}).Sign("/foo.jpg", url.Values{"w": {"200"}}))
+}
+
+func TestSignerWithScope(t *testing.T) {
+	signer := NewSigner(&Configuration{
+		Keys: []string{"new"},
+	})
+
+	brandA := signer.WithScope("brand-a")
+
+	signed := brandA.Sign("/foo.jpg", url.Values{"w": {"200"}})
+	assert.Equal(t, Sign("new", "brand-a:/foo.jpg", url.Values{"w": {"200"}}), signed.Get("s"))
+	assert.NoError(t, brandA.Verify("/foo.jpg", signed))
+
+	// the signature is not valid in another scope
+	assert.Equal(t, ErrInvalid, signer.Verify("/foo.jpg", signed))
+	assert.Equal(t, ErrInvalid, signer.WithScope("brand-b").Verify("/foo.jpg", signed))
+	assert.Equal(t, ErrInvalid, brandA.Verify("/foo.jpg", signer.Sign("/foo.jpg", url.Values{"w": {"200"}})))
+
+	scoped, err := SignScopedURL("new", "brand-a", "/foo.jpg?w=200", time.Time{})
+	assert.NoError(t, err)
+
+	u, err := url.Parse(scoped)
+	assert.NoError(t, err)
+	assert.NoError(t, brandA.Verify(u.Path, u.Query()))
+
+	assert.Equal(t, "/foo.jpg", ScopedPath("", "/foo.jpg"))
+}
@@ -0,0 +1,192 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package signature
+
+import (
+	"crypto/hmac"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query parameters of the signed upload urls
+const (
+	ParamUploadPath   = "path"
+	ParamMaxSize      = "max_size"
+	ParamContentTypes = "ct"
+	ParamMount        = "mount"
+)
+
+// uploadMethod binds the upload signatures to the POST method,
+// an image url signature can not be used to upload and vice versa
+const uploadMethod = "POST"
+
+// Errors of the signed uploads
+var (
+	// ErrPath is returned when the upload path is not allowed by the signed url
+	ErrPath = errors.New("signature: path not allowed")
+	// ErrMount is returned when the upload url is signed for another mount
+	ErrMount = errors.New("signature: mount not allowed")
+)
+
+// UploadPolicy is the set of constraints signed in an upload url
+type UploadPolicy struct {
+	// Path of the image, or prefix of the allowed paths if it ends with a slash
+	Path         string    `json:"path"`
+	MaxSize      int64     `json:"max_size,omitempty"`
+	ContentTypes []string  `json:"content_types,omitempty"`
+	Expires      time.Time `json:"expires_at"`
+	// Mount is the name of the mount of the upload
+	Mount string `json:"mount,omitempty"`
+}
+
+// Values returns the query parameters of the policy, without the signature
+func (p UploadPolicy) Values() url.Values {
+	q := url.Values{}
+
+	q.Set(ParamUploadPath, p.Path)
+	q.Set(ParamExpires, strconv.FormatInt(p.Expires.Unix(), 10))
+
+	if p.MaxSize > 0 {
+		q.Set(ParamMaxSize, strconv.FormatInt(p.MaxSize, 10))
+	}
+
+	if len(p.ContentTypes) > 0 {
+		q.Set(ParamContentTypes, strings.Join(p.ContentTypes, ","))
+	}
+
+	if p.Mount != "" {
+		q.Set(ParamMount, p.Mount)
+	}
+
+	return q
+}
+
+// AllowPath returns true if the image can be uploaded to path
+func (p UploadPolicy) AllowPath(path string) bool {
+	if strings.HasSuffix(p.Path, "/") {
+		return strings.HasPrefix(path, p.Path)
+	}
+
+	return path == p.Path
+}
+
+// AllowContentType returns true if the mime type is allowed, all types are allowed if the list is empty
+func (p UploadPolicy) AllowContentType(contentType string) bool {
+	if len(p.ContentTypes) == 0 {
+		return true
+	}
+
+	for _, ct := range p.ContentTypes {
+		if strings.EqualFold(ct, contentType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseUploadPolicy reads the policy from the query parameters, the expiry is mandatory
+func ParseUploadPolicy(query url.Values) (*UploadPolicy, error) {
+	p := &UploadPolicy{
+		Path:  query.Get(ParamUploadPath),
+		Mount: query.Get(ParamMount),
+	}
+
+	if p.Path == "" {
+		return nil, ErrInvalid
+	}
+
+	expires, err := strconv.ParseInt(query.Get(ParamExpires), 10, 64)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	p.Expires = time.Unix(expires, 0)
+
+	if size := query.Get(ParamMaxSize); size != "" {
+		p.MaxSize, err = strconv.ParseInt(size, 10, 64)
+		if err != nil || p.MaxSize < 0 {
+			return nil, ErrInvalid
+		}
+	}
+
+	if ct := query.Get(ParamContentTypes); ct != "" {
+		p.ContentTypes = strings.Split(ct, ",")
+	}
+
+	return p, nil
+}
+
+// SignUpload returns the signed query parameters of the policy
+func SignUpload(key string, policy UploadPolicy) url.Values {
+	q := policy.Values()
+
+	q.Set(ParamSignature, Sign(key, uploadMethod, q))
+
+	return q
+}
+
+// SignUpload signs the policy with the first key
+func (s *Signer) SignUpload(policy UploadPolicy) (url.Values, error) {
+	if len(s.keys) == 0 {
+		return nil, ErrNoKey
+	}
+
+	return SignUpload(s.keys[0], policy), nil
+}
+
+// VerifyUpload verifies the signature of the upload url, its expiry and the upload path,
+// it returns the signed policy and the index of the key used to sign.
+// Only the policy parameters are signed, the other parameters of the query are ignored.
+func (s *Signer) VerifyUpload(path string, query url.Values) (*UploadPolicy, int, error) {
+	if len(s.keys) == 0 {
+		return nil, -1, ErrNoKey
+	}
+
+	signature := query.Get(ParamSignature)
+	if signature == "" {
+		return nil, -1, ErrMissing
+	}
+
+	policy, err := ParseUploadPolicy(query)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	signed := url.Values{}
+
+	for _, key := range []string{ParamUploadPath, ParamExpires, ParamMaxSize, ParamContentTypes, ParamMount} {
+		if values, ok := query[key]; ok {
+			signed[key] = values
+		}
+	}
+
+	index := -1
+
+	for i, key := range s.keys {
+		if hmac.Equal([]byte(signature), []byte(Sign(key, uploadMethod, signed))) {
+			index = i
+
+			break
+		}
+	}
+
+	if index < 0 {
+		return nil, -1, ErrInvalid
+	}
+
+	if s.now().After(policy.Expires) {
+		return nil, -1, ErrExpired
+	}
+
+	if !policy.AllowPath(path) {
+		return nil, -1, ErrPath
+	}
+
+	return policy, index, nil
+}
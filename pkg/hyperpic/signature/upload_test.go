@@ -0,0 +1,132 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package signature
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadPolicyValues(t *testing.T) {
+	policy := UploadPolicy{
+		Path:         "/products/",
+		MaxSize:      1024,
+		ContentTypes: []string{"image/jpeg", "image/png"},
+		Expires:      time.Unix(1700000000, 0),
+		Mount:        "brand-a",
+	}
+
+	assert.Equal(t, "ct=image%2Fjpeg%2Cimage%2Fpng&exp=1700000000&max_size=1024&mount=brand-a&path=%2Fproducts%2F", policy.Values().Encode())
+
+	parsed, err := ParseUploadPolicy(policy.Values())
+	assert.NoError(t, err)
+	assert.Equal(t, policy.Path, parsed.Path)
+	assert.Equal(t, policy.MaxSize, parsed.MaxSize)
+	assert.Equal(t, policy.ContentTypes, parsed.ContentTypes)
+	assert.Equal(t, policy.Mount, parsed.Mount)
+	assert.True(t, policy.Expires.Equal(parsed.Expires))
+
+	_, err = ParseUploadPolicy(url.Values{"exp": {"1700000000"}})
+	assert.Equal(t, ErrInvalid, err)
+
+	_, err = ParseUploadPolicy(url.Values{"path": {"/foo.jpg"}})
+	assert.Equal(t, ErrInvalid, err)
+
+	_, err = ParseUploadPolicy(url.Values{"path": {"/foo.jpg"}, "exp": {"1700000000"}, "max_size": {"-1"}})
+	assert.Equal(t, ErrInvalid, err)
+}
+
+func TestUploadPolicyAllow(t *testing.T) {
+	prefix := UploadPolicy{Path: "/products/"}
+
+	assert.True(t, prefix.AllowPath("/products/kayaks.jpg"))
+	assert.False(t, prefix.AllowPath("/kayaks.jpg"))
+	assert.True(t, prefix.AllowContentType("image/gif"))
+
+	file := UploadPolicy{Path: "/kayaks.jpg", ContentTypes: []string{"image/jpeg"}}
+
+	assert.True(t, file.AllowPath("/kayaks.jpg"))
+	assert.False(t, file.AllowPath("/kayaks.jpg/foo.jpg"))
+	assert.True(t, file.AllowContentType("IMAGE/JPEG"))
+	assert.False(t, file.AllowContentType("image/png"))
+}
+
+func TestSignerVerifyUpload(t *testing.T) {
+	signer := NewSigner(&Configuration{
+		Keys: []string{"new", "old"},
+	})
+	signer.now = func() time.Time {
+		return time.Unix(1700000000, 0)
+	}
+
+	policy := UploadPolicy{
+		Path:    "/products/",
+		MaxSize: 1024,
+		Expires: time.Unix(1700000900, 0),
+		Mount:   "default",
+	}
+
+	query, err := signer.SignUpload(policy)
+	assert.NoError(t, err)
+	assert.Equal(t, SignUpload("new", policy), query)
+
+	// the unsigned parameters are ignored
+	query.Set("eager", "thumb")
+
+	signed, index, err := signer.VerifyUpload("/products/kayaks.jpg", query)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, int64(1024), signed.MaxSize)
+	assert.Equal(t, "default", signed.Mount)
+
+	_, index, err = signer.VerifyUpload("/products/kayaks.jpg", SignUpload("old", policy))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, index)
+
+	_, _, err = signer.VerifyUpload("/kayaks.jpg", query)
+	assert.Equal(t, ErrPath, err)
+
+	tampered := SignUpload("new", policy)
+	tampered.Set(ParamMaxSize, "2048")
+
+	_, _, err = signer.VerifyUpload("/products/kayaks.jpg", tampered)
+	assert.Equal(t, ErrInvalid, err)
+
+	// the mount is signed
+	tampered = SignUpload("new", policy)
+	tampered.Set(ParamMount, "brand-a")
+
+	_, _, err = signer.VerifyUpload("/products/kayaks.jpg", tampered)
+	assert.Equal(t, ErrInvalid, err)
+
+	tampered.Del(ParamMount)
+
+	_, _, err = signer.VerifyUpload("/products/kayaks.jpg", tampered)
+	assert.Equal(t, ErrInvalid, err)
+
+	// an image url signature can not be used to upload
+	image := policy.Values()
+	image.Set(ParamSignature, Sign("new", "/products/kayaks.jpg", policy.Values()))
+
+	_, _, err = signer.VerifyUpload("/products/kayaks.jpg", image)
+	assert.Equal(t, ErrInvalid, err)
+
+	policy.Expires = time.Unix(1699999999, 0)
+
+	_, _, err = signer.VerifyUpload("/products/kayaks.jpg", SignUpload("new", policy))
+	assert.Equal(t, ErrExpired, err)
+
+	_, _, err = signer.VerifyUpload("/products/kayaks.jpg", policy.Values())
+	assert.Equal(t, ErrMissing, err)
+
+	_, _, err = NewSigner(&Configuration{}).VerifyUpload("/products/kayaks.jpg", query)
+	assert.Equal(t, ErrNoKey, err)
+
+	_, err = NewSigner(&Configuration{}).SignUpload(policy)
+	assert.Equal(t, ErrNoKey, err)
+}
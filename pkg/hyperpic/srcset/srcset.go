@@ -0,0 +1,268 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package srcset
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+)
+
+// maxWidths is the maximum number of widths in a srcset
+const maxWidths = 16
+
+// Errors
+var (
+	ErrEmptyWidths   = errors.New("srcset: no width")
+	ErrTooManyWidths = fmt.Errorf("srcset: too many widths, max %d", maxWidths)
+)
+
+// markupParams are removed from the variant urls, the signature of the request
+// does not sign the variants, they are signed again by the signer
+var markupParams = []string{"srcset", "sizes", "fmt", "w", "h", "dpr", "fm", "s", "exp"}
+
+// Signer adds the signature parameters to a variant url
+type Signer interface {
+	Sign(path string, query url.Values) url.Values
+}
+
+// Checker returns an error when a variant url is not allowed, it is called before signing it
+type Checker func(query url.Values) error
+
+// Variant of the image for a width
+type Variant struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Source is the list of variants encoded in a format
+type Source struct {
+	Format   string    `json:"format"`
+	MimeType string    `json:"type"`
+	Variants []Variant `json:"variants"`
+}
+
+// Srcset returns the srcset attribute of the source
+func (s Source) Srcset() string {
+	parts := make([]string, len(s.Variants))
+
+	for i, variant := range s.Variants {
+		parts[i] = fmt.Sprintf("%s %dw", variant.URL, variant.Width)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Set struct
+type Set struct {
+	Src     string   `json:"src"`
+	Width   int      `json:"width"`
+	Height  int      `json:"height"`
+	Sizes   string   `json:"sizes,omitempty"`
+	Sources []Source `json:"sources"`
+}
+
+// ParseWidths parses a comma separated list of widths
+func ParseWidths(s string) ([]int, error) {
+	widths := []int{}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		width, err := strconv.Atoi(part)
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("srcset: invalid width %q", part)
+		}
+
+		widths = append(widths, width)
+	}
+
+	if len(widths) == 0 {
+		return nil, ErrEmptyWidths
+	}
+
+	if len(widths) > maxWidths {
+		return nil, ErrTooManyWidths
+	}
+
+	sort.Ints(widths)
+
+	return widths, nil
+}
+
+// New builds the variants of the image at path for each width and format, the
+// dimensions are computed from the source size and the requested options.
+// Widths larger than the source are skipped, the variants are checked before signing them.
+func New(
+	path string,
+	query url.Values,
+	options *image.Options,
+	sourceWidth int,
+	sourceHeight int,
+	widths []int,
+	formats []string,
+	signer Signer,
+	check Checker,
+) (*Set, error) {
+	base := url.Values{}
+
+	for key, values := range query {
+		base[key] = values
+	}
+
+	for _, key := range markupParams {
+		base.Del(key)
+	}
+
+	candidates := []int{}
+
+	for _, width := range widths {
+		if width <= sourceWidth {
+			candidates = append(candidates, width)
+		}
+	}
+
+	if len(candidates) == 0 {
+		candidates = append(candidates, sourceWidth)
+	}
+
+	set := &Set{
+		Sizes:   query.Get("sizes"),
+		Sources: make([]Source, 0, len(formats)),
+	}
+
+	for _, format := range formats {
+		source := Source{
+			Format:   format,
+			MimeType: image.GetImageMimeType(image.ExtensionToType(format)),
+			Variants: make([]Variant, 0, len(candidates)),
+		}
+
+		for _, width := range candidates {
+			q := url.Values{}
+
+			for key, values := range base {
+				q[key] = values
+			}
+
+			height := 0
+
+			// keep the requested aspect ratio
+			if options.Width > 0 && options.Height > 0 {
+				height = int(math.Round(float64(width) * float64(options.Height) / float64(options.Width)))
+
+				q.Set("h", strconv.Itoa(height))
+			}
+
+			q.Set("w", strconv.Itoa(width))
+			q.Set("fm", format)
+
+			if check != nil {
+				if err := check(q); err != nil {
+					return nil, err
+				}
+			}
+
+			if signer != nil {
+				q = signer.Sign(path, q)
+			}
+
+			w, h := outputSize(options.Fit, width, height, sourceWidth, sourceHeight)
+
+			source.Variants = append(source.Variants, Variant{
+				URL:    path + "?" + q.Encode(),
+				Width:  w,
+				Height: h,
+			})
+		}
+
+		set.Sources = append(set.Sources, source)
+	}
+
+	if len(set.Sources) > 0 {
+		fallback := set.Sources[len(set.Sources)-1]
+		largest := fallback.Variants[len(fallback.Variants)-1]
+
+		set.Src = largest.URL
+		set.Width = largest.Width
+		set.Height = largest.Height
+	}
+
+	return set, nil
+}
+
+// outputSize returns the size of the processed image
+func outputSize(fit image.FitType, width, height, sourceWidth, sourceHeight int) (int, int) {
+	if height == 0 {
+		return width, int(math.Round(float64(width) * float64(sourceHeight) / float64(sourceWidth)))
+	}
+
+	switch fit {
+	case image.FitContain, image.FitMax:
+		scale := math.Min(float64(width)/float64(sourceWidth), float64(height)/float64(sourceHeight))
+
+		return int(math.Round(float64(sourceWidth) * scale)), int(math.Round(float64(sourceHeight) * scale))
+	default:
+		return width, height
+	}
+}
+
+// HTML returns an img tag when there is one format, a picture tag with a source
+// per format otherwise.
+func (s Set) HTML() string {
+	if len(s.Sources) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	sizes := ""
+	if s.Sizes != "" {
+		sizes = fmt.Sprintf(` sizes="%s"`, html.EscapeString(s.Sizes))
+	}
+
+	fallback := s.Sources[len(s.Sources)-1]
+
+	img := fmt.Sprintf(
+		`<img src="%s" srcset="%s"%s width="%d" height="%d" alt="">`,
+		html.EscapeString(s.Src),
+		html.EscapeString(fallback.Srcset()),
+		sizes,
+		s.Width,
+		s.Height,
+	)
+
+	if len(s.Sources) == 1 {
+		return img
+	}
+
+	sb.WriteString("<picture>\n")
+
+	for _, source := range s.Sources[:len(s.Sources)-1] {
+		fmt.Fprintf(
+			&sb,
+			"  <source type=\"%s\" srcset=\"%s\"%s>\n",
+			source.MimeType,
+			html.EscapeString(source.Srcset()),
+			sizes,
+		)
+	}
+
+	sb.WriteString("  " + img + "\n")
+	sb.WriteString("</picture>")
+
+	return sb.String()
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 Axel Etcheverry. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package srcset
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/hyperscale/hyperpic/pkg/hyperpic/image"
+	"github.com/stretchr/testify/assert"
+)
+
+type signerMock struct{}
+
+func (signerMock) Sign(path string, query url.Values) url.Values {
+	query.Set("s", "sig")
+
+	return query
+}
+
+func TestParseWidths(t *testing.T) {
+	widths, err := ParseWidths("1280, 320,640")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{320, 640, 1280}, widths)
+
+	_, err = ParseWidths("")
+	assert.Equal(t, ErrEmptyWidths, err)
+
+	_, err = ParseWidths("320,abc")
+	assert.EqualError(t, err, `srcset: invalid width "abc"`)
+
+	_, err = ParseWidths("-1")
+	assert.Error(t, err)
+
+	_, err = ParseWidths("1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17")
+	assert.Equal(t, ErrTooManyWidths, err)
+}
+
+func TestNew(t *testing.T) {
+	query := url.Values{
+		"srcset": {"320,640,4000"},
+		"sizes":  {"(max-width: 600px) 100vw, 50vw"},
+		"fmt":    {"json"},
+		"q":      {"80"},
+		"dpr":    {"2"},
+		"s":      {"sig"},
+		"exp":    {"1700000000"},
+	}
+
+	// the signature of the request is not copied to the variants
+	set, err := New("/kayaks.jpg", query, &image.Options{Quality: 80}, 2000, 1000, []int{320, 640, 4000}, []string{"webp", "jpg"}, nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, &Set{
+		Src:    "/kayaks.jpg?fm=jpg&q=80&w=640",
+		Width:  640,
+		Height: 320,
+		Sizes:  "(max-width: 600px) 100vw, 50vw",
+		Sources: []Source{
+			{
+				Format:   "webp",
+				MimeType: "image/webp",
+				Variants: []Variant{
+					{URL: "/kayaks.jpg?fm=webp&q=80&w=320", Width: 320, Height: 160},
+					{URL: "/kayaks.jpg?fm=webp&q=80&w=640", Width: 640, Height: 320},
+				},
+			},
+			{
+				Format:   "jpg",
+				MimeType: "image/jpeg",
+				Variants: []Variant{
+					{URL: "/kayaks.jpg?fm=jpg&q=80&w=320", Width: 320, Height: 160},
+					{URL: "/kayaks.jpg?fm=jpg&q=80&w=640", Width: 640, Height: 320},
+				},
+			},
+		},
+	}, set)
+
+	assert.Equal(t, `<picture>
+  <source type="image/webp" srcset="/kayaks.jpg?fm=webp&amp;q=80&amp;w=320 320w, /kayaks.jpg?fm=webp&amp;q=80&amp;w=640 640w" sizes="(max-width: 600px) 100vw, 50vw">
+  <img src="/kayaks.jpg?fm=jpg&amp;q=80&amp;w=640" srcset="/kayaks.jpg?fm=jpg&amp;q=80&amp;w=320 320w, /kayaks.jpg?fm=jpg&amp;q=80&amp;w=640 640w" sizes="(max-width: 600px) 100vw, 50vw" width="640" height="320" alt="">
+</picture>`, set.HTML())
+}
+
+func TestNewWithCropAndSigner(t *testing.T) {
+	query := url.Values{
+		"w":   {"400"},
+		"h":   {"400"},
+		"fit": {"crop"},
+	}
+
+	set, err := New("/kayaks.jpg", query, &image.Options{Width: 400, Height: 400, Fit: image.FitCropCenter}, 2000, 1000, []int{200}, []string{"webp"}, signerMock{}, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Variant{
+		{URL: "/kayaks.jpg?fit=crop&fm=webp&h=200&s=sig&w=200", Width: 200, Height: 200},
+	}, set.Sources[0].Variants)
+
+	assert.Equal(t, `<img src="/kayaks.jpg?fit=crop&amp;fm=webp&amp;h=200&amp;s=sig&amp;w=200" srcset="/kayaks.jpg?fit=crop&amp;fm=webp&amp;h=200&amp;s=sig&amp;w=200 200w" width="200" height="200" alt="">`, set.HTML())
+
+	// contain fits in the box
+	set, err = New("/kayaks.jpg", url.Values{}, &image.Options{Width: 400, Height: 400}, 2000, 1000, []int{200}, []string{"webp"}, nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 200, set.Width)
+	assert.Equal(t, 100, set.Height)
+
+	// widths larger than the source use the source width
+	set, err = New("/kayaks.jpg", url.Values{}, &image.Options{}, 100, 50, []int{200, 400}, []string{"jpg"}, nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Variant{
+		{URL: "/kayaks.jpg?fm=jpg&w=100", Width: 100, Height: 50},
+	}, set.Sources[0].Variants)
+
+	assert.Equal(t, "", Set{}.HTML())
+}
+
+func TestNewWithChecker(t *testing.T) {
+	errNotAllowed := errors.New("not allowed")
+
+	checked := []string{}
+
+	check := func(query url.Values) error {
+		checked = append(checked, query.Encode())
+
+		if query.Get("w") == "640" {
+			return errNotAllowed
+		}
+
+		return nil
+	}
+
+	set, err := New("/kayaks.jpg", url.Values{"q": {"80"}}, &image.Options{}, 2000, 1000, []int{320, 640}, []string{"jpg"}, signerMock{}, check)
+	assert.Equal(t, errNotAllowed, err)
+	assert.Nil(t, set)
+
+	// the variants are checked before signing them
+	assert.Equal(t, []string{"fm=jpg&q=80&w=320", "fm=jpg&q=80&w=640"}, checked)
+}